@@ -0,0 +1,275 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	adminSearchLimit = 50
+	adminRecentLimit = 20
+)
+
+// recordAudit saves the action of the admin making the request.
+// The reason comes from the hx-prompt of the admin console buttons
+func (s *Server) recordAudit(r *http.Request, action types.AuditAction, targetId int64) error {
+	actorId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.store.SaveAuditEntry(r.Context(), &types.AuditEntry{
+		ActorID:  actorId,
+		Action:   action,
+		TargetID: targetId,
+		Reason:   strings.TrimSpace(r.Header.Get("HX-Prompt")),
+	})
+	if err != nil {
+		s.logger.Printf("ERROR: record audit entry; action=%s, target id=%d: %v\n", action, targetId, err)
+	}
+
+	return err
+}
+
+func (s *Server) handleGetAdminConsole(w http.ResponseWriter, r *http.Request) {
+	auctions, err := s.store.GetRecentAuctions(r.Context(), adminRecentLimit)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	bids, err := s.store.GetRecentBids(r.Context(), adminRecentLimit)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	entries, err := s.store.GetAuditEntries(r.Context(), adminRecentLimit)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAdminPageHandler(&templates.AdminConsole{
+		RecentAuctions: auctions,
+		RecentBids:     bids,
+		AuditEntries:   entries,
+	})
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+
+	users := make([]types.User, 0)
+	if search != "" {
+		var err error
+		users, err = s.store.SearchUsers(r.Context(), search, adminSearchLimit)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+	}
+
+	handler := templates.NewAdminUserResultsHandler(users)
+	handler.ServeHTTP(w, r)
+}
+
+// handleSetUserSuspended suspends or unsuspends the user, a suspended user is logged out everywhere at once
+func (s *Server) handleSetUserSuspended(suspend bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad user id in path: %s", r.PathValue("id")))
+			return
+		}
+
+		adminId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+		if err != nil {
+			s.handleUnauthorized(w, r)
+			return
+		}
+
+		if id == adminId {
+			s.statusConflict(w, r, "You can't suspend your own account")
+			return
+		}
+
+		user, err := s.store.GetUserByID(r.Context(), id)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if user == nil {
+			s.handleNotFound(w, r)
+			return
+		}
+
+		suspendedAt := sql.NullTime{}
+		action := types.AuditUserUnsuspended
+		if suspend {
+			suspendedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			action = types.AuditUserSuspended
+		}
+
+		if err = s.store.SetUserSuspended(r.Context(), id, suspendedAt); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if suspend {
+			if err = s.sessions.RevokeAll(r.Context(), id); err != nil {
+				s.internalError(w, r)
+				return
+			}
+		}
+
+		if err = s.recordAudit(r, action, id); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		user.SuspendedAt = suspendedAt
+		handler := templates.NewAdminUserRowHandler(user)
+		handler.ServeHTTP(w, r)
+	}
+}
+
+func (s *Server) handleGetAdminAuction(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	auction, err := s.store.GetAuctionByID(r.Context(), id)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if auction == nil {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	lots, err := s.store.GetAuctionLotsByAuctionID(r.Context(), id)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAdminAuctionPageHandler(auction, lots)
+	handler.ServeHTTP(w, r)
+}
+
+// handleSetAuctionModerated archives the auction regardless of its owner, or lifts the archive.
+// The owner can't reinstate the auction while an admin keeps it archived
+func (s *Server) handleSetAuctionModerated(moderate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+			return
+		}
+
+		auction, err := s.store.GetAuctionByID(r.Context(), id)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if auction == nil {
+			s.handleNotFound(w, r)
+			return
+		}
+
+		moderatedAt := sql.NullTime{}
+		action := types.AuditAuctionReinstated
+		if moderate {
+			moderatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			action = types.AuditAuctionArchived
+		}
+
+		if err = s.store.SetAuctionModerated(r.Context(), id, moderatedAt); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if err = s.recordAudit(r, action, id); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if !moderate {
+			// the auction may have missed its start while it was archived
+			s.scheduler.Wake()
+		}
+
+		auction.ModeratedAt = moderatedAt
+		auction.IsActive = !moderate
+		handler := templates.NewAdminAuctionRowHandler(auction)
+		handler.ServeHTTP(w, r)
+	}
+}
+
+// handleSetAuctionLotModerated deactivates the lot regardless of its owner, or lifts the deactivation.
+// The owner can't reinstate the lot while an admin keeps it deactivated
+func (s *Server) handleSetAuctionLotModerated(moderate bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+			return
+		}
+
+		lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+			return
+		}
+
+		lot, err := s.store.GetAuctionLotByID(r.Context(), lotId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if lot == nil || lot.AuctionID != auctionId {
+			s.handleNotFound(w, r)
+			return
+		}
+
+		moderatedAt := sql.NullTime{}
+		action := types.AuditAuctionLotReinstated
+		if moderate {
+			moderatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			action = types.AuditAuctionLotDeactivated
+		}
+
+		if err = s.store.SetAuctionLotModerated(r.Context(), lotId, moderatedAt); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if err = s.recordAudit(r, action, lotId); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
+
+		lot.ModeratedAt = moderatedAt
+		lot.IsActive = !moderate
+		handler := templates.NewAdminAuctionLotRowHandler(lot)
+		handler.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"github.com/artemsmotritel/oktion/validation"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiTokenPrefix tells an API token apart from the other secrets, e.g. when it leaks into a repository
+const apiTokenPrefix = "okt_"
+
+// apiTokenTouchInterval keeps the scripts polling the auctions from writing the last use on every request
+const apiTokenTouchInterval = time.Minute
+
+// bearerToken returns the token of the Authorization: Bearer header, ok is false if the request has no such header
+func bearerToken(r *http.Request) (token string, ok bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// apiScopesFromContext returns the scopes of the token the request is authorized with, ok is false for a request made by a browser session
+func apiScopesFromContext(ctx context.Context) (scopes []types.Scope, ok bool) {
+	scopes, err := utils.ExtractValueFromContext[[]types.Scope](ctx, "apiScopes")
+	return scopes, err == nil
+}
+
+// resolveAPIToken returns nil for a token that is unknown, expired or belongs to a suspended user
+func (s *Server) resolveAPIToken(ctx context.Context, token string, now time.Time) (*types.APIToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, nil
+	}
+
+	apiToken, err := s.store.GetAPITokenByHash(ctx, utils.HashToken(token))
+	if err != nil || apiToken == nil {
+		return nil, err
+	}
+
+	if apiToken.IsExpired(now) {
+		return nil, nil
+	}
+
+	user, err := s.store.GetUserByID(ctx, apiToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.IsSuspended() {
+		return nil, nil
+	}
+
+	if !apiToken.LastUsedAt.Valid || now.Sub(apiToken.LastUsedAt.Time) >= apiTokenTouchInterval {
+		if err = s.store.SetAPITokenLastUsed(ctx, apiToken.ID, now); err != nil {
+			return nil, err
+		}
+	}
+
+	return apiToken, nil
+}
+
+// invalidAPIToken answers in plain text, the clients using the tokens are scripts rather than browsers
+func (s *Server) invalidAPIToken(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, "The API token is invalid, expired or revoked", http.StatusUnauthorized)
+}
+
+// requireScope lets through the browser sessions and the API tokens holding the scope
+func (s *Server) requireScope(scope types.Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := apiScopesFromContext(r.Context())
+		if ok && !slices.Contains(scopes, scope) {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+			http.Error(w, fmt.Sprintf("The API token lacks the %s scope", scope), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleCreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	validator := validation.NewCreateAPITokenValidator()
+	if !validator.Validate(r.Form) {
+		tokens, err := s.store.GetAPITokensByUserID(r.Context(), userId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		handler := templates.NewAPITokensErrorBadRequestHandler(tokens, validator.Values(), validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	secret, err := utils.NewRandomToken()
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	token := apiTokenPrefix + secret
+
+	now := time.Now()
+	apiToken := &types.APIToken{
+		UserID:    userId,
+		Name:      validator.Name,
+		TokenHash: utils.HashToken(token),
+		Scopes:    validator.Scopes,
+		CreatedAt: now,
+	}
+	if validator.Lifetime > 0 {
+		apiToken.ExpiresAt = sql.NullTime{Time: now.Add(validator.Lifetime), Valid: true}
+	}
+
+	if _, err = s.store.SaveAPIToken(r.Context(), apiToken); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	tokens, err := s.store.GetAPITokensByUserID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAPITokensHandler(tokens, token)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleDeleteAPIToken(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad API token id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	if err = s.store.DeleteAPIToken(r.Context(), userId, id); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	tokens, err := s.store.GetAPITokensByUserID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAPITokensHandler(tokens, "")
+	handler.ServeHTTP(w, r)
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/artemsmotritel/oktion/storage"
 	"github.com/artemsmotritel/oktion/templates"
 	"github.com/artemsmotritel/oktion/types"
 	"github.com/artemsmotritel/oktion/utils"
@@ -23,7 +25,7 @@ func (s *Server) handleEditAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	auction, err := s.store.GetAuctionByID(id)
+	auction, err := s.store.GetAuctionByID(r.Context(), id)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -33,7 +35,7 @@ func (s *Server) handleEditAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	auctionLots, err := s.store.GetAuctionLotsByAuctionID(auction.ID)
+	auctionLots, err := s.store.GetAuctionLotsByAuctionID(r.Context(), auction.ID)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -44,20 +46,13 @@ func (s *Server) handleEditAuction(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetMyAuctions(w http.ResponseWriter, r *http.Request) {
-	_, err := extractUserIDFromCookie(r)
-
-	if err != nil {
-		s.badRequestError(w, r, "not authorized")
-		return
-	}
-
 	ownerId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
 	if err != nil {
 		s.handleUnauthorized(w, r)
 		return
 	}
 
-	auctions, err := s.store.GetAuctionsByOwnerId(ownerId)
+	auctions, err := s.store.GetAuctionsByOwnerId(r.Context(), ownerId)
 
 	if err != nil {
 		s.internalError(w, r)
@@ -69,7 +64,7 @@ func (s *Server) handleGetMyAuctions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetAuctions(w http.ResponseWriter, r *http.Request) {
-	auctions, err := s.store.GetAuctions()
+	auctions, err := s.store.GetAuctions(r.Context())
 
 	if err != nil {
 		// TODO introduce logging to error responses
@@ -92,7 +87,7 @@ func (s *Server) handleGetAuctionByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	auction, err := s.store.GetAuctionByID(id)
+	auction, err := s.store.GetAuctionByID(r.Context(), id)
 
 	if err != nil {
 		s.internalError(w, r)
@@ -117,10 +112,11 @@ func (s *Server) handleCreateAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ownerID, err := extractUserIDFromCookie(r)
+	ownerID, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
 
 	if err != nil {
-		s.badRequestError(w, r, "Invalid cookie")
+		s.handleUnauthorized(w, r)
+		return
 	}
 
 	auction, err := types.MapAuctionCreateRequest(r.Form, ownerID)
@@ -130,11 +126,12 @@ func (s *Server) handleCreateAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	savedAuction, err := s.store.SaveAuction(auction)
+	savedAuction, err := s.store.SaveAuction(r.Context(), auction)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
+	s.scheduler.Wake()
 
 	w.Header().Add("HX-Push-Url", fmt.Sprintf("/my-auctions/%d/edit", savedAuction.ID))
 	handler := templates.NewEditAuctionPageHandler(savedAuction, []types.AuctionLot{})
@@ -150,7 +147,7 @@ func (s *Server) handleDeleteAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.store.DeleteAuction(id); err != nil {
+	if err = s.store.DeleteAuction(r.Context(), id); err != nil {
 		s.internalError(w, r)
 		return
 	}
@@ -186,6 +183,8 @@ func (s *Server) handleUpdateAuction(w http.ResponseWriter, r *http.Request) {
 			Description: updateRequest.Description,
 			IsActive:    true,
 			IsPrivate:   updateRequest.IsPrivate,
+			StartsAt:    updateRequest.StartsAt,
+			EndsAt:      updateRequest.EndsAt,
 		}
 		w.Header().Set("HX-Retarget", "#create-auction-form-1")
 		w.Header().Set("HX-Reswap", "outerHTML")
@@ -195,13 +194,14 @@ func (s *Server) handleUpdateAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updatedAuction, err := s.store.UpdateAuction(updateRequest)
+	updatedAuction, err := s.store.UpdateAuction(r.Context(), updateRequest)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
+	s.scheduler.Wake()
 
-	auctionLots, err := s.store.GetAuctionLotsByAuctionID(updatedAuction.ID)
+	auctionLots, err := s.store.GetAuctionLotsByAuctionID(r.Context(), updatedAuction.ID)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -228,12 +228,12 @@ func (s *Server) handleArchiveAuction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.store.SetAuctionActiveStatus(id, false); err != nil {
+	if err = s.store.SetAuctionActiveStatus(r.Context(), id, false); err != nil {
 		s.internalError(w, r)
 		return
 	}
 
-	auctions, err := s.store.GetAuctionsByOwnerId(userId)
+	auctions, err := s.store.GetAuctionsByOwnerId(r.Context(), userId)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -260,12 +260,18 @@ func (s *Server) handleReinstateAuction(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err = s.store.SetAuctionActiveStatus(id, true); err != nil {
+	if err = s.store.SetAuctionActiveStatus(r.Context(), id, true); err != nil {
+		if errors.Is(err, storage.ErrModerated) {
+			s.statusConflict(w, r, "An admin has archived this auction, only an admin can reinstate it")
+			return
+		}
 		s.internalError(w, r)
 		return
 	}
+	// the auction may have missed its start while it was archived
+	s.scheduler.Wake()
 
-	auctions, err := s.store.GetAuctionsByOwnerId(userId)
+	auctions, err := s.store.GetAuctionsByOwnerId(r.Context(), userId)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -276,3 +282,138 @@ func (s *Server) handleReinstateAuction(w http.ResponseWriter, r *http.Request)
 	handler := templates.NewMyAuctionsPageHandler(auctions)
 	handler.ServeHTTP(w, r)
 }
+
+func (s *Server) handleUpdateBidIncrements(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	updateRequest := types.NewBidIncrementsUpdateRequest(r.Form, id)
+	validator := validation.NewBidIncrementsUpdateValidator(updateRequest)
+	ok, err := validator.Validate()
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		auction, err := s.store.GetAuctionByID(r.Context(), id)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+		if auction == nil {
+			s.handleNotFound(w, r)
+			return
+		}
+
+		handler := templates.NewBidIncrementsFormErrorBadRequestHandler(id, auction.BidIncrements, validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	increments, err := s.store.SetAuctionBidIncrements(r.Context(), id, updateRequest.Increments)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewBidIncrementsFormHandler(id, increments)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleUpdateSoftClose(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	updateRequest := types.NewSoftCloseUpdateRequest(r.Form, id)
+	validator := validation.NewSoftCloseUpdateValidator(updateRequest)
+	ok, err := validator.Validate()
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		auction, err := s.store.GetAuctionByID(r.Context(), id)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+		if auction == nil {
+			s.handleNotFound(w, r)
+			return
+		}
+
+		values := map[string]string{"softCloseMinutes": updateRequest.MinutesStr}
+		handler := templates.NewSoftCloseFormErrorBadRequestHandler(id, auction.SoftCloseWindow, values, validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if err = s.store.SetAuctionSoftCloseWindow(r.Context(), id, updateRequest.Window); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewSoftCloseFormHandler(id, updateRequest.Window)
+	handler.ServeHTTP(w, r)
+}
+
+// handleUpdateManagerTwoFactor is left to the owner, the setting is what keeps the other managers in check
+func (s *Server) handleUpdateManagerTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	ownerId, err := s.store.GetOwnerIDByAuctionID(r.Context(), id)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if ownerId != userId {
+		s.handleForbidden(w, r)
+		return
+	}
+
+	required := r.Form.Get("requireManagerTwoFactor") == "on"
+	if err = s.store.SetAuctionManagerTwoFactor(r.Context(), id, required); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewManagerTwoFactorFormHandler(id, required)
+	handler.ServeHTTP(w, r)
+}
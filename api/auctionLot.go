@@ -3,10 +3,10 @@ package api
 import (
 	"errors"
 	"fmt"
+	"github.com/artemsmotritel/oktion/storage"
 	"github.com/artemsmotritel/oktion/templates"
 	"github.com/artemsmotritel/oktion/types"
 	"github.com/artemsmotritel/oktion/validation"
-	"github.com/jackc/pgx/v5"
 	"net/http"
 	"strconv"
 )
@@ -18,7 +18,7 @@ func (s *Server) handleCreateAuctionLot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	auction, err := s.store.GetAuctionByID(id)
+	auction, err := s.store.GetAuctionByID(r.Context(), id)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -28,14 +28,14 @@ func (s *Server) handleCreateAuctionLot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	auctionLotCount, err := s.store.GetAuctionLotCount(auction.ID)
+	auctionLotCount, err := s.store.GetAuctionLotCount(r.Context(), auction.ID)
 	auctionLotCount++
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
 
-	savedAuctionLot, err := s.store.SaveAuctionLot(&types.AuctionLot{
+	savedAuctionLot, err := s.store.SaveAuctionLot(r.Context(), &types.AuctionLot{
 		AuctionID: auction.ID,
 		Name:      fmt.Sprintf("Lot %d", auctionLotCount),
 	})
@@ -61,17 +61,17 @@ func (s *Server) handleEditAuctionLot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	auctionLot, err := s.store.GetAuctionLotByID(lotId)
+	auctionLot, err := s.store.GetAuctionLotByID(r.Context(), lotId)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			s.handleNotFound(w, r)
-			return
-		}
 		s.internalError(w, r)
 		return
 	}
+	if auctionLot == nil {
+		s.handleNotFound(w, r)
+		return
+	}
 
-	categories, err := s.store.GetCategories()
+	categories, err := s.store.GetCategories(r.Context())
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -113,7 +113,7 @@ func (s *Server) handleUpdateAuctionLot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	categories, err := s.store.GetCategories()
+	categories, err := s.store.GetCategories(r.Context())
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -137,11 +137,17 @@ func (s *Server) handleUpdateAuctionLot(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	auctionLot, err := s.store.UpdateAuctionLot(lotId, updateRequest)
+	auctionLot, err := s.store.UpdateAuctionLot(r.Context(), lotId, updateRequest)
 	if err != nil {
+		if errors.Is(err, storage.ErrLotPricesLocked) {
+			s.statusConflict(w, r, "The prices can't change once the lot has bids or has closed")
+			return
+		}
 		s.internalError(w, r)
 		return
 	}
+	s.scheduler.Wake()
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
 
 	w.WriteHeader(http.StatusCreated)
 	handler := templates.NewAuctionLotEditFormHandler(auctionLot, categories)
@@ -162,22 +168,30 @@ func (s *Server) handleSetAuctionLotActiveStatus(isActive bool) http.HandlerFunc
 			return
 		}
 
-		if err = s.store.SetAuctionLotActiveStatus(lotId, isActive); err != nil {
+		if err = s.store.SetAuctionLotActiveStatus(r.Context(), lotId, isActive); err != nil {
+			if errors.Is(err, storage.ErrModerated) {
+				s.statusConflict(w, r, "An admin has deactivated this lot, only an admin can reinstate it")
+				return
+			}
 			s.internalError(w, r)
 			return
 		}
 
-		lots, err := s.store.GetAuctionLotsByAuctionID(auctionId)
+		lots, err := s.store.GetAuctionLotsByAuctionID(r.Context(), auctionId)
 		if err != nil {
 			s.internalError(w, r)
 			return
 		}
 
-		auction, err := s.store.GetAuctionByID(auctionId)
+		auction, err := s.store.GetAuctionByID(r.Context(), auctionId)
 		if err != nil {
 			s.internalError(w, r)
 			return
 		}
+		if auction == nil {
+			s.handleNotFound(w, r)
+			return
+		}
 
 		handler := templates.NewAuctionLotsListHandler(lots, auction)
 		handler.ServeHTTP(w, r)
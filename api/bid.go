@@ -0,0 +1,544 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"github.com/artemsmotritel/oktion/validation"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"strconv"
+)
+
+var errLotNotInAuction = errors.New("the auction lot doesn't belong to the auction")
+
+// getAuctionLotBidding collects the lot, its auction and bids.
+// It returns pgx.ErrNoRows or errLotNotInAuction when there is nothing to show
+func (s *Server) getAuctionLotBidding(ctx context.Context, auctionId, lotId, viewerId int64) (*templates.AuctionLotBidding, error) {
+	lot, err := s.store.GetAuctionLotByID(ctx, lotId)
+	if err != nil {
+		return nil, err
+	}
+	if lot == nil {
+		return nil, pgx.ErrNoRows
+	}
+	if lot.AuctionID != auctionId {
+		return nil, errLotNotInAuction
+	}
+
+	auction, err := s.store.GetAuctionByID(ctx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+	if auction == nil {
+		return nil, pgx.ErrNoRows
+	}
+
+	bids, err := s.store.GetBidsByLotID(ctx, lotId)
+	if err != nil {
+		return nil, err
+	}
+
+	highestBid, err := s.store.GetHighestBid(ctx, lotId)
+	if err != nil {
+		return nil, err
+	}
+
+	var viewerMaxBid *types.MaxBid
+	if viewerId != 0 {
+		if viewerMaxBid, err = s.store.GetMaxBid(ctx, lotId, viewerId); err != nil {
+			return nil, err
+		}
+	}
+
+	var winner *types.AuctionLotWinner
+	if lot.IsClosed {
+		if winner, err = s.store.GetAuctionLotWinner(ctx, lotId); err != nil {
+			return nil, err
+		}
+	}
+
+	return &templates.AuctionLotBidding{
+		Auction:      auction,
+		Lot:          lot,
+		HighestBid:   highestBid,
+		Bids:         bids,
+		ViewerID:     viewerId,
+		ViewerMaxBid: viewerMaxBid,
+		Winner:       winner,
+	}, nil
+}
+
+func (s *Server) handleGetAuctionLot(w http.ResponseWriter, r *http.Request) {
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	// the page is public, so the viewer may not be logged in
+	viewerId, _ := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, viewerId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAuctionLotPageHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handlePlaceBid(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	if bidding.IsOwnLot() {
+		s.handleForbidden(w, r)
+		return
+	}
+
+	if !bidding.AcceptsBids() {
+		s.statusConflict(w, r, "This lot doesn't accept bids")
+		return
+	}
+
+	request := types.NewBidCreateRequest(r.Form, lotId, userId)
+	validator := validation.NewBidValidator(request)
+
+	ok, err := validator.Validate(bidding.Lot, bidding.HighestBid, bidding.Auction.BidIncrements)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		handler := templates.NewAuctionLotBidSectionErrorBadRequestHandler(bidding, map[string]string{"value": request.ValueStr}, validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	_, err = s.store.PlaceBid(r.Context(), &types.Bid{
+		AuctionLotID: lotId,
+		UserID:       userId,
+		Value:        request.Value,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBidTooLow):
+			// someone has outbid the user in the meantime, so show them the fresh price
+			if bidding, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId); err != nil {
+				s.internalError(w, r)
+				return
+			}
+			errs := map[string]string{"value": "Someone has already placed a higher bid"}
+			handler := templates.NewAuctionLotBidSectionErrorBadRequestHandler(bidding, map[string]string{"value": request.ValueStr}, errs)
+			handler.ServeHTTP(w, r)
+		case errors.Is(err, storage.ErrOwnLotBid):
+			s.handleForbidden(w, r)
+		case errors.Is(err, storage.ErrLotNotAcceptingBids):
+			s.statusConflict(w, r, "This lot doesn't accept bids")
+		default:
+			s.internalError(w, r)
+		}
+		return
+	}
+
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, false)
+
+	bidding, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handlePlaceMaxBid(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	if bidding.IsOwnLot() {
+		s.handleForbidden(w, r)
+		return
+	}
+
+	if !bidding.AcceptsBids() {
+		s.statusConflict(w, r, "This lot doesn't accept bids")
+		return
+	}
+
+	request := types.NewMaxBidCreateRequest(r.Form, lotId, userId)
+	validator := validation.NewMaxBidValidator(request)
+
+	ok, err := validator.Validate(bidding.Lot, bidding.HighestBid, bidding.Auction.BidIncrements, bidding.ViewerMaxBid)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		handler := templates.NewAuctionLotBidSectionErrorBadRequestHandler(bidding, map[string]string{"maxAmount": request.AmountStr}, validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	_, err = s.store.PlaceMaxBid(r.Context(), &types.MaxBid{
+		AuctionLotID: lotId,
+		UserID:       userId,
+		Amount:       request.Amount,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBidTooLow):
+			if bidding, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId); err != nil {
+				s.internalError(w, r)
+				return
+			}
+			errs := map[string]string{"maxAmount": "The price has changed, your maximum bid is not enough anymore"}
+			handler := templates.NewAuctionLotBidSectionErrorBadRequestHandler(bidding, map[string]string{"maxAmount": request.AmountStr}, errs)
+			handler.ServeHTTP(w, r)
+		case errors.Is(err, storage.ErrOwnLotBid):
+			s.handleForbidden(w, r)
+		case errors.Is(err, storage.ErrLotNotAcceptingBids):
+			s.statusConflict(w, r, "This lot doesn't accept bids")
+		default:
+			s.internalError(w, r)
+		}
+		return
+	}
+
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, false)
+
+	bidding, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleBuyNow(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	if bidding.IsOwnLot() {
+		s.handleForbidden(w, r)
+		return
+	}
+
+	if !bidding.IsBuyNowAvailable() {
+		s.statusConflict(w, r, "This lot can't be bought now")
+		return
+	}
+
+	if _, err = s.store.BuyNow(r.Context(), lotId, userId); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrOwnLotBid):
+			s.handleForbidden(w, r)
+		case errors.Is(err, storage.ErrBuyNowUnavailable), errors.Is(err, storage.ErrLotNotAcceptingBids):
+			s.statusConflict(w, r, "This lot can't be bought now")
+		default:
+			s.internalError(w, r)
+		}
+		return
+	}
+
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
+
+	bidding, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleCloseAuctionLot(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	if _, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	if _, err = s.store.CloseAuctionLot(r.Context(), lotId); err != nil {
+		if errors.Is(err, storage.ErrUnexpectedLotOutcome) {
+			s.statusConflict(w, r, "This lot is already closed")
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleOfferAuctionLot(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	if _, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	if err = s.store.OfferAuctionLotToHighestBidder(r.Context(), lotId); err != nil {
+		if errors.Is(err, storage.ErrUnexpectedLotOutcome) {
+			s.statusConflict(w, r, "This lot can't be offered to the highest bidder")
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleRespondToAuctionLotOffer(accept bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+		if err != nil {
+			s.handleUnauthorized(w, r)
+			return
+		}
+
+		auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+			return
+		}
+
+		lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+		if err != nil {
+			s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+			return
+		}
+
+		if _, err = s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+				s.handleNotFound(w, r)
+				return
+			}
+			s.internalError(w, r)
+			return
+		}
+
+		if _, err = s.store.RespondToAuctionLotOffer(r.Context(), lotId, userId, accept); err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotHighestBidder):
+				s.handleForbidden(w, r)
+			case errors.Is(err, storage.ErrUnexpectedLotOutcome):
+				s.statusConflict(w, r, "This lot isn't offered to you")
+			default:
+				s.internalError(w, r)
+			}
+			return
+		}
+
+		s.publishAuctionLotUpdate(r.Context(), auctionId, lotId, true)
+
+		bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, userId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		handler := templates.NewAuctionLotBidSectionHandler(bidding)
+		handler.ServeHTTP(w, r)
+	}
+}
+
+func (s *Server) handleGetAuctionLotBidSection(w http.ResponseWriter, r *http.Request) {
+	auctionId, err := strconv.ParseInt(r.PathValue("auctionId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("auctionId")))
+		return
+	}
+
+	lotId, err := strconv.ParseInt(r.PathValue("lotId"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction lot id in path: %s", r.PathValue("lotId")))
+		return
+	}
+
+	viewerId, _ := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+
+	bidding, err := s.getAuctionLotBidding(r.Context(), auctionId, lotId, viewerId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, errLotNotInAuction) {
+			s.handleNotFound(w, r)
+			return
+		}
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewAuctionLotBidSectionHandler(bidding)
+	handler.ServeHTTP(w, r)
+}
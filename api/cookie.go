@@ -2,9 +2,85 @@ package api
 
 import (
 	"net/http"
+	"time"
 )
 
+const sessionCookieName = "session"
+
 func getCookie(r *http.Request) (*http.Cookie, error) {
-	cookie, err := r.Cookie("userId")
+	cookie, err := r.Cookie(sessionCookieName)
 	return cookie, err
 }
+
+// getSessionToken returns "" if the request has no session cookie
+func getSessionToken(r *http.Request) string {
+	cookie, err := getCookie(r)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func setSessionCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	cookie := http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	cookie := http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// loginChallengeCookieName holds the token of a login waiting for the second factor
+const loginChallengeCookieName = "login-challenge"
+
+// getLoginChallengeToken returns "" if the request has no login challenge cookie
+func getLoginChallengeToken(r *http.Request) string {
+	cookie, err := r.Cookie(loginChallengeCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func setLoginChallengeCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	cookie := http.Cookie{
+		Name:     loginChallengeCookieName,
+		Value:    token,
+		Path:     "/login",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+func clearLoginChallengeCookie(w http.ResponseWriter) {
+	cookie := http.Cookie{
+		Name:     loginChallengeCookieName,
+		Value:    "",
+		Path:     "/login",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+	http.SetCookie(w, &cookie)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"github.com/artemsmotritel/oktion/session"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+)
+
+// csrfHeaderName is sent by htmx with every request, see the hx-headers of the page body
+const csrfHeaderName = "X-CSRF-Token"
+
+// csrfCookieName keeps the CSRF secret of a visitor without a session, so that the login and sign-up forms are protected as well
+const csrfCookieName = "csrf"
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func withCSRFToken(r *http.Request, secret string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), "csrfToken", session.CSRFToken(secret)))
+}
+
+// csrfSecret returns the session token of an authorized user and the anonymous secret otherwise,
+// issuing the latter if the visitor doesn't have one yet
+func (s *Server) csrfSecret(w http.ResponseWriter, r *http.Request) (string, error) {
+	isAuth, err := utils.ExtractValueFromContext[bool](r.Context(), "isAuthorized")
+	if err == nil && isAuth {
+		return getSessionToken(r), nil
+	}
+
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	secret, err := session.NewCSRFSecret()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    secret,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return secret, nil
+}
+
+// csrfMiddleware leaves out the requests authorized with an API token, a browser never attaches the Authorization header on its own
+func (s *Server) csrfMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := apiScopesFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret, err := s.csrfSecret(w, r)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		r = withCSRFToken(r, secret)
+
+		if !isSafeMethod(r.Method) {
+			expected, _ := utils.ExtractValueFromContext[string](r.Context(), "csrfToken")
+			if !session.ValidCSRFToken(expected, r.Header.Get(csrfHeaderName)) {
+				s.logger.Printf("WARN: rejected a %s %s request with a missing or invalid CSRF token\n", r.Method, r.URL.Path)
+				w.WriteHeader(http.StatusForbidden)
+				handler := templates.NewErrorPageHandler(templates.InvalidCSRFToken)
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
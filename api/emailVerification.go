@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/artemsmotritel/oktion/mail"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	emailVerificationTokenTTL = 48 * time.Hour
+	// a user may ask for one link a minute and for emailVerificationResendLimit links an hour
+	emailVerificationResendInterval = time.Minute
+	emailVerificationResendLimit    = 5
+)
+
+func (s *Server) sendEmailVerificationLink(ctx context.Context, user *types.User) error {
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.store.SaveEmailVerificationToken(ctx, &types.EmailVerificationToken{
+		ID:        utils.HashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailVerificationTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Oktion email",
+		Body: fmt.Sprintf("Hi %s,\n\nFollow the link to verify your email: %s/verify-email/%s\n\n"+
+			"The link works for two days.\n", user.FullName, s.publicURL, token),
+	}
+
+	go func() {
+		if err := s.mailer.Send(message); err != nil {
+			s.logger.Printf("ERROR: send email verification email; user id=%d: %v\n", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	_, err := s.store.VerifyEmail(r.Context(), utils.HashToken(r.PathValue("token")), time.Now())
+	if err != nil && !errors.Is(err, storage.ErrInvalidEmailVerificationToken) {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewVerifyEmailPageHandler(err == nil)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if user.IsEmailVerified() {
+		handler := templates.NewEmailVerificationNoticeHandler("Your email is already verified.")
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	now := time.Now()
+
+	recent, err := s.store.CountEmailVerificationTokensSince(r.Context(), userId, now.Add(-emailVerificationResendInterval))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	hourly, err := s.store.CountEmailVerificationTokensSince(r.Context(), userId, now.Add(-time.Hour))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if recent > 0 || hourly >= emailVerificationResendLimit {
+		w.WriteHeader(http.StatusTooManyRequests)
+		handler := templates.NewEmailVerificationNoticeHandler("We have sent you a link just now. Check your inbox or try again later.")
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if err = s.sendEmailVerificationLink(r.Context(), user); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewEmailVerificationNoticeHandler(fmt.Sprintf("We have sent a new link to %s.", user.Email))
+	handler.ServeHTTP(w, r)
+}
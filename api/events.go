@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/artemsmotritel/oktion/templates"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sseKeepAliveInterval keeps idle event streams from being closed by proxies
+const sseKeepAliveInterval = 30 * time.Second
+
+// event is a server-sent event, its data may span several lines
+type event struct {
+	name string
+	data string
+}
+
+// eventHub is an in-process pub/sub of the auction events, the subscribers are grouped by auction id
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[int64]map[chan event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[int64]map[chan event]struct{}),
+	}
+}
+
+// subscribe returns the channel of the auction events and the function that stops receiving them
+func (h *eventHub) subscribe(auctionId int64) (<-chan event, func()) {
+	ch := make(chan event, 16)
+
+	h.mu.Lock()
+	if h.subscribers[auctionId] == nil {
+		h.subscribers[auctionId] = make(map[chan event]struct{})
+	}
+	h.subscribers[auctionId][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subscribers[auctionId], ch)
+		if len(h.subscribers[auctionId]) == 0 {
+			delete(h.subscribers, auctionId)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// publish never blocks, a subscriber that is too slow to take the event misses it
+func (h *eventHub) publish(auctionId int64, e event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers[auctionId] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func writeEvent(w io.Writer, e event) error {
+	var b strings.Builder
+
+	b.WriteString("event: " + e.name + "\n")
+	for _, line := range strings.Split(e.data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (s *Server) handleAuctionEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue("id")))
+		return
+	}
+
+	auction, err := s.store.GetAuctionByID(r.Context(), id)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if auction == nil {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	events, unsubscribe := s.events.subscribe(id)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	if err = rc.Flush(); err != nil {
+		s.logger.Println("ERROR: ", err.Error())
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			err = writeEvent(w, e)
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			// the client has gone away
+			return
+		}
+	}
+}
+
+// publishAuctionLotUpdate sends the fresh lot price to the lot page viewers,
+// and makes their pages reload the bid section if the lot status has changed as well.
+// The update is owed to the viewers even if the client of the request that made it has gone away
+func (s *Server) publishAuctionLotUpdate(ctx context.Context, auctionId, lotId int64, statusChanged bool) {
+	ctx = context.WithoutCancel(ctx)
+
+	lot, err := s.store.GetAuctionLotByID(ctx, lotId)
+	if err != nil || lot == nil {
+		s.logger.Printf("ERROR: publish auction lot update; lot id=%d: %v\n", lotId, err)
+		return
+	}
+
+	auction, err := s.store.GetAuctionByID(ctx, auctionId)
+	if err != nil || auction == nil {
+		s.logger.Printf("ERROR: publish auction lot update; auction id=%d: %v\n", auctionId, err)
+		return
+	}
+
+	highestBid, err := s.store.GetHighestBid(ctx, lotId)
+	if err != nil {
+		s.logger.Printf("ERROR: publish auction lot update; highest bid: %v\n", err)
+		return
+	}
+
+	var price bytes.Buffer
+	if err = templates.RenderAuctionLotPrice(ctx, &price, auction, lot, highestBid); err != nil {
+		s.logger.Printf("ERROR: publish auction lot update; render: %v\n", err)
+		return
+	}
+
+	s.events.publish(auctionId, event{name: templates.AuctionLotPriceEvent(lotId), data: price.String()})
+
+	if statusChanged {
+		s.events.publish(auctionId, event{name: templates.AuctionLotStatusEvent(lotId)})
+	}
+}
+
+// AuctionOpened implements scheduler.Listener
+func (s *Server) AuctionOpened(ctx context.Context, auctionId int64) {
+	lots, err := s.store.GetAuctionLotsByAuctionID(ctx, auctionId)
+	if err != nil {
+		return
+	}
+
+	for _, lot := range lots {
+		s.publishAuctionLotUpdate(ctx, auctionId, lot.ID, true)
+	}
+}
+
+// AuctionLotClosed implements scheduler.Listener
+func (s *Server) AuctionLotClosed(ctx context.Context, auctionLotId int64) {
+	lot, err := s.store.GetAuctionLotByID(ctx, auctionLotId)
+	if err != nil || lot == nil {
+		return
+	}
+
+	s.publishAuctionLotUpdate(ctx, lot.AuctionID, auctionLotId, true)
+}
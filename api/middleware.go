@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
 	"github.com/artemsmotritel/oktion/utils"
 	"log"
 	"net/http"
 	"slices"
 	"strconv"
+	"time"
 )
 
 func (s *Server) onlyAuthorizedMiddleware(next http.Handler) http.Handler {
@@ -30,6 +32,8 @@ func (s *Server) onlyAuthorizedMiddleware(next http.Handler) http.Handler {
 }
 
 func (s *Server) protectAuctionsMiddleware(next http.Handler, auctionIdWildcard string) http.Handler {
+	next = s.requireManagerTwoFactor(next, auctionIdWildcard)
+
 	return s.onlyAuthorizedMiddleware(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
@@ -46,11 +50,187 @@ func (s *Server) protectAuctionsMiddleware(next http.Handler, auctionIdWildcard
 				return
 			}
 
-			actualOwnerId, err := s.store.GetOwnerIDByAuctionID(auctionId)
+			actualOwnerId, err := s.store.GetOwnerIDByAuctionID(r.Context(), auctionId)
+			if err != nil {
+				s.internalError(w, r)
+				return
+			}
 
+			// admins moderate every auction
 			if userId != actualOwnerId {
+				isAdmin, err := s.userHasRole(r.Context(), userId, types.AdminRole)
+				if err != nil {
+					s.internalError(w, r)
+					return
+				}
+
+				if !isAdmin {
+					w.WriteHeader(http.StatusForbidden)
+					handler := templates.NewErrorPageHandler(templates.Forbidden)
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+}
+
+// requireManagerTwoFactor holds every manager of the auction but its owner to the two-factor requirement of the auction.
+// It guards the admin routes changing an auction as well as protectAuctionsMiddleware, so the requirement can't be bypassed
+func (s *Server) requireManagerTwoFactor(next http.Handler, auctionIdWildcard string) http.Handler {
+	return s.onlyAuthorizedMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+			if err != nil {
+				s.handleUnauthorized(w, r)
+				return
+			}
+
+			auctionId, err := strconv.ParseInt(r.PathValue(auctionIdWildcard), 10, 64)
+			if err != nil {
+				s.badRequestError(w, r, fmt.Sprintf("Bad auction id in path: %s", r.PathValue(auctionIdWildcard)))
+				return
+			}
+
+			ownerId, err := s.store.GetOwnerIDByAuctionID(r.Context(), auctionId)
+			if err != nil {
+				s.internalError(w, r)
+				return
+			}
+
+			if userId != ownerId {
+				allowed, err := s.managerMeetsTwoFactorRequirement(r.Context(), auctionId, userId)
+				if err != nil {
+					s.internalError(w, r)
+					return
+				}
+
+				if !allowed {
+					w.WriteHeader(http.StatusForbidden)
+					handler := templates.NewErrorPageHandler(templates.TwoFactorRequired)
+					handler.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+}
+
+// managerMeetsTwoFactorRequirement checks the two-factor authentication the auction owner may require from the other managers
+func (s *Server) managerMeetsTwoFactorRequirement(ctx context.Context, auctionId, userId int64) (bool, error) {
+	auction, err := s.store.GetAuctionByID(ctx, auctionId)
+	if err != nil {
+		return false, err
+	}
+	if auction == nil {
+		// the handler tells that the auction doesn't exist
+		return true, nil
+	}
+
+	if !auction.RequireManagerTwoFactor {
+		return true, nil
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	return twoFactor != nil && twoFactor.IsEnabled(), nil
+}
+
+func (s *Server) userHasRole(ctx context.Context, userId int64, role types.Role) (bool, error) {
+	user, err := s.store.GetUserByID(ctx, userId)
+	if err != nil || user == nil {
+		return false, err
+	}
+
+	return user.HasRole(role), nil
+}
+
+// protectUserMiddleware lets only the owner of the account or an admin through
+func (s *Server) protectUserMiddleware(next http.Handler, userIdWildcard string) http.Handler {
+	return s.onlyAuthorizedMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+			if err != nil {
+				s.handleUnauthorized(w, r)
+				return
+			}
+
+			targetUserId, err := strconv.ParseInt(r.PathValue(userIdWildcard), 10, 64)
+			if err != nil {
+				s.badRequestError(w, r, fmt.Sprintf("Bad user id in path: %s", r.PathValue(userIdWildcard)))
+				return
+			}
+
+			if userId != targetUserId {
+				isAdmin, err := s.userHasRole(r.Context(), userId, types.AdminRole)
+				if err != nil {
+					s.internalError(w, r)
+					return
+				}
+
+				if !isAdmin {
+					s.handleForbidden(w, r)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+}
+
+// requireRole lets through only the users holding the role, admins hold every role
+func (s *Server) requireRole(role types.Role, next http.Handler) http.Handler {
+	return s.onlyAuthorizedMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+			if err != nil {
+				s.handleUnauthorized(w, r)
+				return
+			}
+
+			hasRole, err := s.userHasRole(r.Context(), userId, role)
+			if err != nil {
+				s.internalError(w, r)
+				return
+			}
+
+			if !hasRole {
+				s.handleForbidden(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+}
+
+// requireVerifiedEmail keeps the users who haven't verified their email from acting on auctions, they may still browse
+func (s *Server) requireVerifiedEmail(next http.Handler) http.Handler {
+	return s.onlyAuthorizedMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+			if err != nil {
+				s.handleUnauthorized(w, r)
+				return
+			}
+
+			user, err := s.store.GetUserByID(r.Context(), userId)
+			if err != nil {
+				s.internalError(w, r)
+				return
+			}
+			if user == nil {
+				s.handleUnauthorized(w, r)
+				return
+			}
+
+			if !user.IsEmailVerified() {
 				w.WriteHeader(http.StatusForbidden)
-				handler := templates.NewErrorPageHandler(templates.Forbidden)
+				handler := templates.NewErrorPageHandler(templates.EmailNotVerified)
 				handler.ServeHTTP(w, r)
 				return
 			}
@@ -86,9 +266,32 @@ func redirectUserMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func setUserInfoToContextMiddleware(next http.Handler) http.Handler {
+// setUserInfoToContextMiddleware authorizes the request with the API token of the Authorization: Bearer header if there is one,
+// and with the session cookie otherwise. A request with a bad token is refused rather than treated as anonymous
+func (s *Server) setUserInfoToContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		id, err := extractUserIDFromCookie(r)
+		if token, ok := bearerToken(r); ok {
+			apiToken, err := s.resolveAPIToken(r.Context(), token, time.Now())
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if apiToken == nil {
+				s.invalidAPIToken(w, r)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), "userId", apiToken.UserID))
+			r = r.WithContext(context.WithValue(r.Context(), "isAuthorized", true))
+			r = r.WithContext(context.WithValue(r.Context(), "apiScopes", apiToken.Scopes))
+			r = r.WithContext(context.WithValue(r.Context(), "hxBoosted", false))
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := s.extractUserIDFromCookie(w, r)
 
 		if err != nil {
 			r = r.WithContext(context.WithValue(r.Context(), "isAuthorized", false))
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/artemsmotritel/oktion/mail"
+	"github.com/artemsmotritel/oktion/scheduler"
+	"github.com/artemsmotritel/oktion/session"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/shopspring/decimal"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestManagerTwoFactorRequirement checks that an admin managing someone else's auction is held to the two-factor
+// requirement of the auction on the admin console routes as well as on the owner ones
+func TestManagerTwoFactorRequirement(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+	store := storage.NewInMemoryStore()
+	sessions := session.NewManager(store, time.Hour)
+	server := NewServer(":0", "http://oktion.test", store, sessions, nil, nil, scheduler.NewScheduler(store, logger, time.Minute), mail.NewLogMailer(logger), logger)
+	router := server.newConfiguredRouter()
+
+	owner, err := store.SaveUser(ctx, &types.User{FullName: "Owner", Email: "owner@example.com", Roles: types.DefaultRoles})
+	if err != nil {
+		t.Fatalf("save owner: %v", err)
+	}
+	admin, err := store.SaveUser(ctx, &types.User{FullName: "Admin", Email: "admin@example.com", Roles: types.AllRoles})
+	if err != nil {
+		t.Fatalf("save admin: %v", err)
+	}
+	auction, err := store.SaveAuction(ctx, &types.Auction{OwnerId: owner.ID, Name: "Auction", IsActive: true})
+	if err != nil {
+		t.Fatalf("save auction: %v", err)
+	}
+	lot, err := store.SaveAuctionLot(ctx, &types.AuctionLot{AuctionID: auction.ID, Name: "Lot", IsActive: true, MinimalBid: decimal.NewFromInt(10)})
+	if err != nil {
+		t.Fatalf("save auction lot: %v", err)
+	}
+	if err = store.SetAuctionManagerTwoFactor(ctx, auction.ID, true); err != nil {
+		t.Fatalf("set auction manager two factor: %v", err)
+	}
+
+	token, err := sessions.Create(ctx, admin.ID, "")
+	if err != nil {
+		t.Fatalf("create admin session: %v", err)
+	}
+
+	post := func(path string) int {
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: token})
+		r.Header.Set(csrfHeaderName, session.CSRFToken(token))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	paths := []string{
+		fmt.Sprintf("/admin/auctions/%d/archive", auction.ID),
+		fmt.Sprintf("/admin/auctions/%d/lots/%d/deactivate", auction.ID, lot.ID),
+		fmt.Sprintf("/auctions/%d/archive", auction.ID),
+	}
+
+	for _, path := range paths {
+		if code := post(path); code != http.StatusForbidden {
+			t.Errorf("POST %s by an admin without two-factor authentication: got %d, want %d", path, code, http.StatusForbidden)
+		}
+	}
+
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); !got.IsActive {
+		t.Errorf("the auction was archived by an admin without two-factor authentication")
+	}
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); !got.IsActive {
+		t.Errorf("the lot was deactivated by an admin without two-factor authentication")
+	}
+
+	if err = store.SaveTwoFactorSecret(ctx, admin.ID, "secret"); err != nil {
+		t.Fatalf("save two factor secret: %v", err)
+	}
+	if err = store.EnableTwoFactor(ctx, admin.ID, 1, time.Now(), nil); err != nil {
+		t.Fatalf("enable two factor: %v", err)
+	}
+
+	for _, path := range paths {
+		if code := post(path); code != http.StatusOK {
+			t.Errorf("POST %s by an admin with two-factor authentication: got %d, want %d", path, code, http.StatusOK)
+		}
+	}
+
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); got.IsActive {
+		t.Errorf("the auction is still active after the admin with two-factor authentication archived it")
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	identityProviderLoginCookieName = "oidc-login"
+	// identityProviderLoginTTL is how long the user may take to log in at the provider
+	identityProviderLoginTTL = 10 * time.Minute
+)
+
+// setIdentityProviderLoginCookie keeps the state, the nonce and the PKCE verifier of the login until the callback.
+// It is Lax so that the browser sends it along when the provider redirects the user back
+func setIdentityProviderLoginCookie(w http.ResponseWriter, value string, ttl time.Duration) {
+	cookie := http.Cookie{
+		Name:     identityProviderLoginCookieName,
+		Value:    value,
+		Path:     "/login/oidc",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// getIdentityProviderLogin returns false if the login has expired or was never started in this browser
+func getIdentityProviderLogin(r *http.Request) (state, nonce, verifier string, ok bool) {
+	cookie, err := r.Cookie(identityProviderLoginCookieName)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+
+	return parts[0], parts[1], parts[2], true
+}
+
+func (s *Server) handleIdentityProviderLogin(w http.ResponseWriter, r *http.Request) {
+	if s.identityProvider == nil {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		token, err := utils.NewRandomToken()
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+		values[i] = token
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	setIdentityProviderLoginCookie(w, strings.Join(values, "."), identityProviderLoginTTL)
+	http.Redirect(w, r, s.identityProvider.AuthCodeURL(state, nonce, verifier), http.StatusSeeOther)
+}
+
+func (s *Server) handleIdentityProviderCallback(w http.ResponseWriter, r *http.Request) {
+	if s.identityProvider == nil {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	state, nonce, verifier, ok := getIdentityProviderLogin(r)
+	setIdentityProviderLoginCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if !ok || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		s.identityProviderLoginFailed(w, r, http.StatusBadRequest, "Your login has expired, start it over.")
+		return
+	}
+
+	if query.Get("error") != "" {
+		s.identityProviderLoginFailed(w, r, http.StatusUnauthorized, s.identityProvider.Name()+" didn't log you in.")
+		return
+	}
+
+	claims, err := s.identityProvider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		s.logger.Printf("ERROR: exchange the OpenID code: %v\n", err)
+		s.identityProviderLoginFailed(w, r, http.StatusBadGateway, "We couldn't confirm your login with "+s.identityProvider.Name()+".")
+		return
+	}
+
+	user, refusal, err := s.linkExternalIdentity(r.Context(), claims.Issuer, claims.Subject, claims.Email, claims.EmailVerified, claims.Name)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if refusal != "" {
+		s.identityProviderLoginFailed(w, r, http.StatusForbidden, refusal)
+		return
+	}
+
+	if user.IsSuspended() {
+		s.identityProviderLoginFailed(w, r, http.StatusForbidden, "This account has been suspended.")
+		return
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(r.Context(), user.ID)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if twoFactor != nil && twoFactor.IsEnabled() {
+		if err = s.createLoginChallenge(r.Context(), w, user.ID); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		handler := templates.NewTwoFactorLoginPageHandler()
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	token, err := s.sessions.Create(r.Context(), user.ID, getSessionToken(r))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	setSessionCookie(w, token, s.sessions.TTL())
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) identityProviderLoginFailed(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.WriteHeader(status)
+	handler := templates.NewIdentityProviderLoginFailedHandler(message)
+	handler.ServeHTTP(w, r)
+}
+
+// linkExternalIdentity returns the user linked to the identity, linking it on the first login by the email the provider has verified.
+// A new user is created for an unknown email. The refusal explains why the identity can't be linked
+func (s *Server) linkExternalIdentity(ctx context.Context, issuer, subject, email string, emailVerified bool, name string) (user *types.User, refusal string, err error) {
+	user, err = s.store.GetUserByExternalIdentity(ctx, issuer, subject)
+	if err != nil || user != nil {
+		return user, "", err
+	}
+
+	if email == "" || !emailVerified {
+		return nil, "Your account at " + s.identityProvider.Name() + " has no verified email.", nil
+	}
+
+	user, err = s.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+
+	if user == nil {
+		// the password of the new user is unknown to anyone, they may set one with the password reset
+		password, err := utils.NewRandomToken()
+		if err != nil {
+			return nil, "", err
+		}
+
+		hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+		if err != nil {
+			return nil, "", err
+		}
+
+		user, err = s.store.SaveUser(ctx, &types.User{
+			Email:           email,
+			FullName:        name,
+			Password:        hash,
+			Roles:           types.DefaultRoles,
+			EmailVerifiedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	} else if !user.IsEmailVerified() {
+		// whoever signed up with an email they don't own must not get the identity of its owner
+		return nil, "There is an account with your email, but the email isn't verified. Log in with the password and verify it first.", nil
+	}
+
+	err = s.store.SaveExternalIdentity(ctx, &types.ExternalIdentity{
+		Issuer:    issuer,
+		Subject:   subject,
+		UserID:    user.ID,
+		Email:     email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, "", nil
+}
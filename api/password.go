@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/mail"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"github.com/artemsmotritel/oktion/validation"
+	"net/http"
+	"time"
+)
+
+const passwordResetTokenTTL = time.Hour
+
+// handleForgotPassword answers the same whether the account exists or not, so the form can't be used to look up emails
+func (s *Server) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	validator := validation.NewForgotPasswordValidator()
+	if ok := validator.Validate(r.Form); !ok {
+		handler := templates.NewForgotPasswordErrorBadRequestHandler(validator.Values(), validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(r.Context(), validator.Email)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if user != nil && !user.IsSuspended() {
+		if err = s.sendPasswordResetLink(r.Context(), user); err != nil {
+			s.internalError(w, r)
+			return
+		}
+	}
+
+	handler := templates.NewForgotPasswordSentHandler(validator.Email)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) sendPasswordResetLink(ctx context.Context, user *types.User) error {
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.store.SavePasswordResetToken(ctx, &types.PasswordResetToken{
+		ID:        utils.HashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(passwordResetTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Oktion password",
+		Body: fmt.Sprintf("Hi %s,\n\nFollow the link to set a new password: %s/reset-password/%s\n\n"+
+			"The link works once within an hour. If you didn't ask for it, ignore this email.\n", user.FullName, s.publicURL, token),
+	}
+
+	// sending in the background keeps the response time from telling whether the account exists
+	go func() {
+		if err := s.mailer.Send(message); err != nil {
+			s.logger.Printf("ERROR: send password reset email; user id=%d: %v\n", user.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) handleGetResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	resetToken, err := s.store.GetPasswordResetToken(r.Context(), utils.HashToken(token))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewResetPasswordPageHandler(token, resetToken != nil && resetToken.IsUsable(time.Now()))
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+
+	if err := r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	validator := validation.NewPasswordResetValidator()
+	if ok := validator.Validate(r.Form); !ok {
+		handler := templates.NewResetPasswordErrorBadRequestHandler(token, validator.Errors)
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	hash, err := argon2id.CreateHash(validator.Password, argon2id.DefaultParams)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	userId, err := s.store.ResetPassword(r.Context(), utils.HashToken(token), hash, time.Now())
+	if errors.Is(err, storage.ErrInvalidPasswordResetToken) {
+		w.Header().Set("HX-Retarget", "#main")
+		w.Header().Set("HX-Reswap", "outerHTML")
+		handler := templates.NewResetPasswordInvalidHandler()
+		handler.ServeHTTP(w, r)
+		return
+	}
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	// whoever knew the old password must not stay logged in
+	if err = s.sessions.RevokeAll(r.Context(), userId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	// the owner of the email may log in with the new password even if someone has locked the account out
+	user, err := s.store.GetUserByID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user != nil {
+		if err = s.loginLimiter.Unlock(r.Context(), user.Email); err != nil {
+			s.internalError(w, r)
+			return
+		}
+	}
+	clearSessionCookie(w)
+
+	handler := templates.NewResetPasswordDoneHandler()
+	handler.ServeHTTP(w, r)
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"fmt"
+	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/mail"
 	"github.com/artemsmotritel/oktion/templates"
 	"github.com/artemsmotritel/oktion/utils"
+	"github.com/artemsmotritel/oktion/validation"
 	"net/http"
 )
 
@@ -13,12 +17,159 @@ func (s *Server) handleGetProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.store.GetUserByID(userId)
+	user, err := s.store.GetUserByID(r.Context(), userId)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	recoveryCodesLeft := 0
+	if twoFactor != nil && twoFactor.IsEnabled() {
+		recoveryCodesLeft, err = s.store.CountRecoveryCodes(r.Context(), userId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+	}
+
+	apiTokens, err := s.store.GetAPITokensByUserID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewProfilePageHandler(user, twoFactor, recoveryCodesLeft, apiTokens)
+	handler.ServeHTTP(w, r)
+}
+
+// handleChangePassword keeps the user logged in only on the device the password was changed from
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	validator := validation.NewChangePasswordValidator()
+	ok, err := validator.Validate(r.Form, user)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		handler := templates.NewChangePasswordFormHandler(validator.Errors, "")
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	hash, err := argon2id.CreateHash(validator.Password, argon2id.DefaultParams)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if err = s.store.SetUserPassword(r.Context(), userId, hash); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if err = s.sessions.RevokeOthers(r.Context(), userId, getSessionToken(r)); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewChangePasswordFormHandler(nil, "Your password has been changed and your other devices have been logged out.")
+	handler.ServeHTTP(w, r)
+}
+
+// handleChangeEmail marks the new email unverified and sends it a verification link,
+// the old email is told about the change in case it wasn't the user who made it
+func (s *Server) handleChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	validator := validation.NewChangeEmailValidator()
+	ok, err := validator.Validate(r.Context(), r.Form, user, s.store)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		handler := templates.NewChangeEmailFormHandler(validator.Values(), validator.Errors, "")
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	oldEmail := user.Email
+	if err = s.store.SetUserEmail(r.Context(), userId, validator.Email); err != nil {
+		s.internalError(w, r)
+		return
+	}
+	user.Email = validator.Email
+
+	if err = s.sendEmailVerificationLink(r.Context(), user); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	message := mail.Message{
+		To:      oldEmail,
+		Subject: "Your Oktion email has been changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email of your Oktion account has been changed to %s.\n\n"+
+			"If you didn't change it, reset your password right away: %s/forgot-password\n", user.FullName, user.Email, s.publicURL),
+	}
+
+	go func() {
+		if err := s.mailer.Send(message); err != nil {
+			s.logger.Printf("ERROR: send email change notice; user id=%d: %v\n", user.ID, err)
+		}
+	}()
 
-	handler := templates.NewProfilePageHandler(user)
+	handler := templates.NewChangeEmailFormHandler(nil, nil, fmt.Sprintf("Your email is now %s. Follow the link we have sent there to verify it.", user.Email))
 	handler.ServeHTTP(w, r)
 }
@@ -1,27 +1,58 @@
 package api
 
 import (
+	"context"
+	"github.com/artemsmotritel/oktion/mail"
+	"github.com/artemsmotritel/oktion/oidc"
+	"github.com/artemsmotritel/oktion/scheduler"
+	"github.com/artemsmotritel/oktion/session"
 	"github.com/artemsmotritel/oktion/storage"
 	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/throttle"
+	"github.com/artemsmotritel/oktion/types"
 	"log"
 	"net/http"
 	"slices"
-	"strconv"
 	"strings"
+	"time"
 )
 
+// healthCheckTimeout is how long the health check waits for the storage before reporting it unavailable
+const healthCheckTimeout = 2 * time.Second
+
 type Server struct {
 	listenAddress string
-	store         storage.Storage
-	logger        *log.Logger
+	// publicURL is the scheme and host the emailed links point to
+	publicURL string
+	store     storage.Storage
+	sessions  *session.Manager
+	// loginLimiter slows down the logins that keep failing
+	loginLimiter *throttle.LoginLimiter
+	// identityProvider is nil unless the users may log in with an OpenID provider
+	identityProvider *oidc.Provider
+	scheduler        *scheduler.Scheduler
+	mailer           mail.Mailer
+	events           *eventHub
+	logger           *log.Logger
 }
 
-func NewServer(listenAddress string, store storage.Storage, logger *log.Logger) *Server {
-	return &Server{
-		listenAddress: listenAddress,
-		store:         store,
-		logger:        logger,
+// NewServer subscribes the server to the scheduler transitions, so the scheduler must not be running yet
+func NewServer(listenAddress, publicURL string, store storage.Storage, sessions *session.Manager, loginLimiter *throttle.LoginLimiter, identityProvider *oidc.Provider, scheduler *scheduler.Scheduler, mailer mail.Mailer, logger *log.Logger) *Server {
+	s := &Server{
+		listenAddress:    listenAddress,
+		publicURL:        strings.TrimSuffix(publicURL, "/"),
+		store:            store,
+		sessions:         sessions,
+		loginLimiter:     loginLimiter,
+		identityProvider: identityProvider,
+		scheduler:        scheduler,
+		mailer:           mailer,
+		events:           newEventHub(),
+		logger:           logger,
 	}
+	scheduler.SetListener(s)
+
+	return s
 }
 
 func (s *Server) Start() error {
@@ -30,11 +61,23 @@ func (s *Server) Start() error {
 
 func (s *Server) newConfiguredRouter() http.Handler {
 	mux := http.NewServeMux()
+	// tokenMux holds the only routes the API tokens reach, the rest is left to the browser sessions
+	tokenMux := http.NewServeMux()
+
+	// handleReadable registers a route any API token may use, handleScoped one that needs the scope as well
+	handleReadable := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, handler)
+		tokenMux.Handle(pattern, handler)
+	}
+	handleScoped := func(pattern string, scope types.Scope, handler http.Handler) {
+		mux.Handle(pattern, handler)
+		tokenMux.Handle(pattern, s.requireScope(scope, handler))
+	}
 
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
 	homePaths := []string{"/", "/home"}
-	categories, _ := s.store.GetCategories()
+	categories, _ := s.store.GetCategories(context.Background())
 	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimSpace(r.URL.Path)
 
@@ -46,46 +89,120 @@ func (s *Server) newConfiguredRouter() http.Handler {
 
 		s.handleNotFound(w, r)
 	})
+	mux.HandleFunc("GET /healthz", s.handleHealthCheck)
 	mux.HandleFunc("GET /profile", s.handleGetProfile)
-	mux.Handle("GET /my-auctions", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleGetMyAuctions)))
+	mux.Handle("POST /profile/password", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleChangePassword)))
+	mux.Handle("POST /profile/email", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleChangeEmail)))
+	mux.Handle("POST /profile/two-factor", s.requireRole(types.SellerRole, http.HandlerFunc(s.handleSetUpTwoFactor)))
+	mux.Handle("POST /profile/two-factor/enable", s.requireRole(types.SellerRole, http.HandlerFunc(s.handleEnableTwoFactor)))
+	mux.Handle("POST /profile/two-factor/disable", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleDisableTwoFactor)))
+	mux.Handle("POST /profile/api-tokens", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleCreateAPIToken)))
+	mux.Handle("DELETE /profile/api-tokens/{id}", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleDeleteAPIToken)))
+	handleReadable("GET /my-auctions", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleGetMyAuctions)))
 	mux.Handle("GET /my-auctions/{id}/edit", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleEditAuction), "id"))
-	mux.Handle("POST /my-auctions/{id}/lots", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleCreateAuctionLot), "id"))
+	handleScoped("POST /my-auctions/{id}/lots", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleCreateAuctionLot), "id"))
 	mux.Handle("GET /my-auctions/{auctionId}/lots/{lotId}/edit", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleEditAuctionLot), "auctionId"))
 
-	mux.Handle("GET /login", templates.NewLoginPageHandler())
+	identityProviderName := ""
+	if s.identityProvider != nil {
+		identityProviderName = s.identityProvider.Name()
+	}
+	mux.Handle("GET /login", templates.NewLoginPageHandler(identityProviderName))
+	mux.HandleFunc("GET /login/oidc", s.handleIdentityProviderLogin)
+	mux.HandleFunc("GET /login/oidc/callback", s.handleIdentityProviderCallback)
 	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("POST /login/two-factor", s.handleLoginTwoFactor)
 	mux.Handle("GET /sign-up", templates.NewSignUpPageHandler())
 	mux.HandleFunc("POST /sign-up", s.handleSignUp)
 	mux.HandleFunc("POST /logout", s.handleLogout)
+	mux.Handle("GET /forgot-password", templates.NewForgotPasswordPageHandler())
+	mux.HandleFunc("POST /forgot-password", s.handleForgotPassword)
+	mux.HandleFunc("GET /reset-password/{token}", s.handleGetResetPassword)
+	mux.HandleFunc("POST /reset-password/{token}", s.handleResetPassword)
+	mux.HandleFunc("GET /verify-email/{token}", s.handleVerifyEmail)
+	mux.Handle("POST /verify-email/resend", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleResendEmailVerification)))
+	mux.Handle("POST /logout-everywhere", s.onlyAuthorizedMiddleware(http.HandlerFunc(s.handleLogoutEverywhere)))
+
+	mux.Handle("GET /admin", s.requireRole(types.AdminRole, http.HandlerFunc(s.handleGetAdminConsole)))
+	mux.Handle("GET /admin/users", s.requireRole(types.AdminRole, http.HandlerFunc(s.handleSearchUsers)))
+	mux.Handle("POST /admin/users/{id}/suspend", s.requireRole(types.AdminRole, s.handleSetUserSuspended(true)))
+	mux.Handle("POST /admin/users/{id}/unsuspend", s.requireRole(types.AdminRole, s.handleSetUserSuspended(false)))
+	mux.Handle("GET /admin/auctions/{id}", s.requireRole(types.AdminRole, http.HandlerFunc(s.handleGetAdminAuction)))
+	mux.Handle("POST /admin/auctions/{id}/archive", s.requireRole(types.AdminRole, s.requireManagerTwoFactor(s.handleSetAuctionModerated(true), "id")))
+	mux.Handle("POST /admin/auctions/{id}/reinstate", s.requireRole(types.AdminRole, s.requireManagerTwoFactor(s.handleSetAuctionModerated(false), "id")))
+	mux.Handle("POST /admin/auctions/{auctionId}/lots/{lotId}/deactivate", s.requireRole(types.AdminRole, s.requireManagerTwoFactor(s.handleSetAuctionLotModerated(true), "auctionId")))
+	mux.Handle("POST /admin/auctions/{auctionId}/lots/{lotId}/reinstate", s.requireRole(types.AdminRole, s.requireManagerTwoFactor(s.handleSetAuctionLotModerated(false), "auctionId")))
+
+	mux.Handle("GET /users", s.requireRole(types.AdminRole, http.HandlerFunc(s.handleGetUsers)))
+	mux.Handle("GET /users/{id}", http.HandlerFunc(s.handleGetUserByID))
+	mux.Handle("PUT /users/{id}", s.protectUserMiddleware(http.HandlerFunc(s.handleUpdateUser), "id"))
+	mux.Handle("DELETE /users/{id}", s.protectUserMiddleware(http.HandlerFunc(s.handleDeleteUser), "id"))
+
+	handleReadable("GET /auctions", http.HandlerFunc(s.handleGetAuctions))
+	mux.Handle("GET /auctions/new", s.requireRole(types.SellerRole, http.HandlerFunc(s.handleNewAuction)))
+	handleReadable("GET /auctions/{id}", http.HandlerFunc(s.handleGetAuctionByID))
+
+	handleScoped("PUT /auctions/{id}", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateAuction), "id"))
+	handleScoped("PUT /auctions/{id}/bid-increments", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateBidIncrements), "id"))
+	mux.Handle("PUT /auctions/{id}/manager-two-factor", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateManagerTwoFactor), "id"))
+	handleScoped("PUT /auctions/{id}/soft-close", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateSoftClose), "id"))
+	handleScoped("POST /auctions/{id}/archive", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleArchiveAuction), "id"))
+	handleScoped("POST /auctions/{id}/reinstate", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleReinstateAuction), "id"))
+	handleReadable("GET /auctions/{auctionId}/lots/{lotId}", http.HandlerFunc(s.handleGetAuctionLot))
+	handleReadable("GET /auctions/{auctionId}/lots/{lotId}/bid-section", http.HandlerFunc(s.handleGetAuctionLotBidSection))
+	mux.HandleFunc("GET /auctions/{id}/events", s.handleAuctionEvents)
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/bids", types.BidsWriteScope, s.requireRole(types.BidderRole, s.requireVerifiedEmail(http.HandlerFunc(s.handlePlaceBid))))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/max-bids", types.BidsWriteScope, s.requireRole(types.BidderRole, s.requireVerifiedEmail(http.HandlerFunc(s.handlePlaceMaxBid))))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/buy-now", types.BidsWriteScope, s.requireRole(types.BidderRole, s.requireVerifiedEmail(http.HandlerFunc(s.handleBuyNow))))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/close", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleCloseAuctionLot), "auctionId"))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/offer", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleOfferAuctionLot), "auctionId"))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/offer/accept", types.BidsWriteScope, s.requireRole(types.BidderRole, s.requireVerifiedEmail(s.handleRespondToAuctionLotOffer(true))))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/offer/decline", types.BidsWriteScope, s.requireRole(types.BidderRole, s.requireVerifiedEmail(s.handleRespondToAuctionLotOffer(false))))
+	handleScoped("PUT /auctions/{auctionId}/lots/{lotId}", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateAuctionLot), "auctionId"))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/archive", types.AuctionsWriteScope, s.protectAuctionsMiddleware(s.handleSetAuctionLotActiveStatus(false), "auctionId"))
+	handleScoped("POST /auctions/{auctionId}/lots/{lotId}/reinstate", types.AuctionsWriteScope, s.protectAuctionsMiddleware(s.handleSetAuctionLotActiveStatus(true), "auctionId"))
+
+	handleScoped("POST /auctions", types.AuctionsWriteScope, s.requireRole(types.SellerRole, s.requireVerifiedEmail(http.HandlerFunc(s.handleCreateAuction))))
+	handleScoped("DELETE /auctions/{id}", types.AuctionsWriteScope, s.protectAuctionsMiddleware(http.HandlerFunc(s.handleDeleteAuction), "id"))
+
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := apiScopesFromContext(r.Context()); ok {
+			tokenMux.ServeHTTP(w, r)
+			return
+		}
 
-	mux.HandleFunc("GET /users", s.handleGetUsers)
-	mux.HandleFunc("GET /users/{id}", s.handleGetUserByID)
-	mux.HandleFunc("PUT /users/{id}", s.handleUpdateUser)
-	mux.HandleFunc("DELETE /users/{id}", s.handleDeleteUser)
+		mux.ServeHTTP(w, r)
+	})
 
-	mux.HandleFunc("GET /auctions", s.handleGetAuctions)
-	mux.HandleFunc("GET /auctions/new", s.handleNewAuction)
-	mux.HandleFunc("GET /auctions/{id}", s.handleGetAuctionByID)
+	return s.setUserInfoToContextMiddleware(loggingMiddleware(s.csrfMiddleware(redirectUserMiddleware(router)), s.logger))
+}
 
-	mux.Handle("PUT /auctions/{id}", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateAuction), "id"))
-	mux.Handle("POST /auctions/{id}/archive", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleArchiveAuction), "id"))
-	mux.Handle("POST /auctions/{id}/reinstate", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleReinstateAuction), "id"))
-	mux.Handle("PUT /auctions/{auctionId}/lots/{lotId}", s.protectAuctionsMiddleware(http.HandlerFunc(s.handleUpdateAuctionLot), "auctionId"))
-	mux.Handle("POST /auctions/{auctionId}/lots/{lotId}/archive", s.protectAuctionsMiddleware(s.handleSetAuctionLotActiveStatus(false), "auctionId"))
-	mux.Handle("POST /auctions/{auctionId}/lots/{lotId}/reinstate", s.protectAuctionsMiddleware(s.handleSetAuctionLotActiveStatus(true), "auctionId"))
+// extractUserIDFromCookie resolves the session cookie through the session manager.
+// The cookie is renewed along with the session, so that it doesn't expire before the session does
+func (s *Server) extractUserIDFromCookie(w http.ResponseWriter, r *http.Request) (int64, error) {
+	sess, renewed, err := s.sessions.Resolve(r.Context(), getSessionToken(r))
+	if err != nil {
+		return 0, err
+	}
 
-	mux.HandleFunc("POST /auctions", s.handleCreateAuction)
-	mux.HandleFunc("DELETE /auctions/{id}", s.handleDeleteAuction)
+	if renewed {
+		setSessionCookie(w, getSessionToken(r), s.sessions.TTL())
+	}
 
-	return setUserInfoToContextMiddleware(loggingMiddleware(redirectUserMiddleware(mux), s.logger))
+	return sess.UserID, nil
 }
 
-func extractUserIDFromCookie(r *http.Request) (int64, error) {
-	cookie, err := getCookie(r)
+// handleHealthCheck tells a load balancer whether the server can reach its storage
+func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
 
-	if err != nil {
-		return 0, err
+	if err := s.store.Ping(ctx); err != nil {
+		s.logger.Printf("ERROR: health check: %v\n", err)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
 	}
 
-	return strconv.ParseInt(cookie.Value, 10, 64)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
 }
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/templates"
+	"github.com/artemsmotritel/oktion/totp"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	loginChallengeTTL = 5 * time.Minute
+	// loginChallengeAttempts is how many codes a login may try before the password has to be entered again
+	loginChallengeAttempts = 5
+	twoFactorIssuer        = "Oktion"
+)
+
+// createLoginChallenge holds back the login of the user until the second factor, see handleLoginTwoFactor
+func (s *Server) createLoginChallenge(ctx context.Context, w http.ResponseWriter, userId int64) error {
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.store.SaveLoginChallenge(ctx, &types.LoginChallenge{
+		ID:        utils.HashToken(token),
+		UserID:    userId,
+		ExpiresAt: time.Now().Add(loginChallengeTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	setLoginChallengeCookie(w, token, loginChallengeTTL)
+
+	return nil
+}
+
+// startLoginChallenge asks for the second factor of a login whose password is correct.
+// The session is created only once the code is, see handleLoginTwoFactor
+func (s *Server) startLoginChallenge(w http.ResponseWriter, r *http.Request, userId int64) {
+	if err := s.createLoginChallenge(r.Context(), w, userId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	w.Header().Set("HX-Retarget", "#login-form")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	handler := templates.NewTwoFactorLoginFormHandler(nil)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	challengeId := utils.HashToken(getLoginChallengeToken(r))
+
+	challenge, err := s.store.GetLoginChallenge(r.Context(), challengeId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if challenge == nil || challenge.IsExpired(time.Now()) || challenge.Attempts >= loginChallengeAttempts {
+		s.restartLogin(w, r, challengeId)
+		return
+	}
+
+	user, err := s.store.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	now := time.Now()
+
+	retryAfter, err := s.loginLimiter.RetryAfter(r.Context(), r.RemoteAddr, user.Email, now)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if retryAfter > 0 {
+		if err = s.store.DeleteLoginChallenge(r.Context(), challengeId); err != nil {
+			s.internalError(w, r)
+			return
+		}
+		clearLoginChallengeCookie(w)
+		s.tooManyLogins(w, r, retryAfter)
+		return
+	}
+
+	ok, err := s.verifySecondFactor(r.Context(), challenge.UserID, r.Form.Get("code"))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		if err = s.loginLimiter.RecordFailure(r.Context(), r.RemoteAddr, user.Email, now); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		attempts, err := s.store.RecordLoginChallengeAttempt(r.Context(), challengeId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		if attempts >= loginChallengeAttempts {
+			s.restartLogin(w, r, challengeId)
+			return
+		}
+
+		w.Header().Set("HX-Retarget", "#login-form")
+		w.Header().Set("HX-Reswap", "outerHTML")
+		handler := templates.NewTwoFactorLoginFormHandler(map[string]string{"code": "Invalid code"})
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if err = s.store.DeleteLoginChallenge(r.Context(), challengeId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+	clearLoginChallengeCookie(w)
+
+	if err = s.loginLimiter.RecordSuccess(r.Context(), user.Email, now); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	s.logIn(w, r, challenge.UserID)
+}
+
+// restartLogin drops the challenge and shows the login form again
+func (s *Server) restartLogin(w http.ResponseWriter, r *http.Request, challengeId string) {
+	if err := s.store.DeleteLoginChallenge(r.Context(), challengeId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+	clearLoginChallengeCookie(w)
+
+	w.Header().Set("HX-Retarget", "#login-form")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	handler := templates.NewLoginErrorBadRequestHandler(nil, map[string]string{"email": "Your login has expired, log in again"})
+	handler.ServeHTTP(w, r)
+}
+
+// verifySecondFactor accepts either a TOTP code or a recovery code, each of them only once
+func (s *Server) verifySecondFactor(ctx context.Context, userId int64, code string) (bool, error) {
+	twoFactor, err := s.store.GetTwoFactor(ctx, userId)
+	if err != nil || twoFactor == nil || !twoFactor.IsEnabled() {
+		return false, err
+	}
+
+	if step, ok := totp.Validate(twoFactor.Secret, code, time.Now()); ok {
+		err = s.store.UseTwoFactorStep(ctx, userId, step)
+		if errors.Is(err, storage.ErrTwoFactorCodeReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err = s.store.UseRecoveryCode(ctx, userId, utils.HashToken(totp.NormalizeRecoveryCode(code)), time.Now())
+	if errors.Is(err, storage.ErrInvalidRecoveryCode) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// handleSetUpTwoFactor starts the enrolment over with a new secret, which takes effect once a code confirms it
+func (s *Server) handleSetUpTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if twoFactor != nil && twoFactor.IsEnabled() {
+		s.statusConflict(w, r, "Two-factor authentication is already on")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if err = s.store.SaveTwoFactorSecret(r.Context(), userId, secret); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	s.renderTwoFactorSetup(w, r, userId, secret, nil)
+}
+
+func (s *Server) renderTwoFactorSetup(w http.ResponseWriter, r *http.Request, userId int64, secret string, errors map[string]string) {
+	user, err := s.store.GetUserByID(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+	if user == nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	qrCode, err := totp.QRCodeSVG(totp.ProvisioningURI(twoFactorIssuer, user.Email, secret))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewTwoFactorSetupHandler(qrCode, secret, errors)
+	handler.ServeHTTP(w, r)
+}
+
+func (s *Server) handleEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(r.Context(), userId)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if twoFactor == nil || twoFactor.IsEnabled() {
+		s.statusConflict(w, r, "There is no two-factor authentication set up to turn on")
+		return
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret, r.Form.Get("code"), time.Now())
+	if !ok {
+		s.renderTwoFactorSetup(w, r, userId, twoFactor.Secret, map[string]string{"code": "Invalid code, check the time on your phone"})
+		return
+	}
+
+	recoveryCodes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = utils.HashToken(code)
+	}
+
+	err = s.store.EnableTwoFactor(r.Context(), userId, step, time.Now(), hashes)
+	if errors.Is(err, storage.ErrTwoFactorCodeReused) {
+		s.renderTwoFactorSetup(w, r, userId, twoFactor.Secret, map[string]string{"code": "Wait for the next code"})
+		return
+	}
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewTwoFactorEnabledHandler(recoveryCodes, len(recoveryCodes), nil)
+	handler.ServeHTTP(w, r)
+}
+
+// handleDisableTwoFactor asks for a code, so that a session left open on a shared computer can't turn the protection off
+func (s *Server) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = r.ParseForm(); err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+
+	ok, err := s.verifySecondFactor(r.Context(), userId, r.Form.Get("code"))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !ok {
+		left, err := s.store.CountRecoveryCodes(r.Context(), userId)
+		if err != nil {
+			s.internalError(w, r)
+			return
+		}
+
+		handler := templates.NewTwoFactorEnabledHandler(nil, left, map[string]string{"code": "Invalid code"})
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	if err = s.store.DisableTwoFactor(r.Context(), userId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	handler := templates.NewTwoFactorDisabledHandler()
+	handler.ServeHTTP(w, r)
+}
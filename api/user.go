@@ -8,10 +8,13 @@ import (
 	"github.com/alexedwards/argon2id"
 	"github.com/artemsmotritel/oktion/templates"
 	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
 	"github.com/artemsmotritel/oktion/validation"
 	"github.com/jackc/pgx/v5"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func (s *Server) handleSignUp(w http.ResponseWriter, r *http.Request) {
@@ -22,7 +25,7 @@ func (s *Server) handleSignUp(w http.ResponseWriter, r *http.Request) {
 
 	// TODO refactor validation, user creation to the example of auction lot
 	signUpValidator := validation.NewSignUpValidator()
-	ok, err := signUpValidator.Validate(r.Form, s.store)
+	ok, err := signUpValidator.Validate(r.Context(), r.Form, s.store)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		s.internalError(w, r)
 		return
@@ -46,26 +49,28 @@ func (s *Server) handleSignUp(w http.ResponseWriter, r *http.Request) {
 
 	user.Password = hash
 
-	savedUser, err := s.store.SaveUser(user)
+	savedUser, err := s.store.SaveUser(r.Context(), user)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
 
-	cookie := http.Cookie{
-		Name:     "userId",
-		Value:    strconv.FormatInt(savedUser.ID, 10),
-		Path:     "/",
-		MaxAge:   3600,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
+	// the account is usable without the email, so a failed email only leaves the user to resend it
+	if err = s.sendEmailVerificationLink(r.Context(), savedUser); err != nil {
+		s.logger.Printf("ERROR: start email verification; user id=%d: %v\n", savedUser.ID, err)
 	}
 
-	http.SetCookie(w, &cookie)
+	token, err := s.sessions.Create(r.Context(), savedUser.ID, getSessionToken(r))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	setSessionCookie(w, token, s.sessions.TTL())
 	w.Header().Set("HX-Push-Url", "/")
-	c, _ := s.store.GetCategories()
+	c, _ := s.store.GetCategories(r.Context())
 	r = r.WithContext(context.WithValue(r.Context(), "isAuthorized", true))
+	r = withCSRFToken(r, token)
 	handler := templates.NewIndexBodyHandler(c)
 	handler.ServeHTTP(w, r)
 }
@@ -76,14 +81,33 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	now := time.Now()
+	email := r.Form.Get("email")
+
+	retryAfter, err := s.loginLimiter.RetryAfter(r.Context(), r.RemoteAddr, email, now)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if retryAfter > 0 {
+		s.tooManyLogins(w, r, retryAfter)
+		return
+	}
+
 	loginValidator := validation.NewLoginValidator()
-	ok, err := loginValidator.Validate(r.Form, s.store)
+	ok, err := loginValidator.Validate(r.Context(), r.Form, s.store)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
 
 	if !ok {
+		if err = s.loginLimiter.RecordFailure(r.Context(), r.RemoteAddr, email, now); err != nil {
+			s.internalError(w, r)
+			return
+		}
+
 		w.Header().Set("HX-Retarget", "#login-form")
 		w.Header().Set("HX-Reswap", "outerHTML")
 		handler := templates.NewLoginErrorBadRequestHandler(loginValidator.Values(), loginValidator.Errors)
@@ -91,26 +115,61 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.store.GetUserByEmail(loginValidator.Email)
+	user, err := s.store.GetUserByEmail(r.Context(), loginValidator.Email)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	twoFactor, err := s.store.GetTwoFactor(r.Context(), user.ID)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
 
-	cookie := http.Cookie{
-		Name:     "userId",
-		Value:    strconv.FormatInt(user.ID, 10),
-		Path:     "/",
-		MaxAge:   3600,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
+	// the account starts over only after the second factor, so that guessing the code is throttled as well
+	if twoFactor != nil && twoFactor.IsEnabled() {
+		s.startLoginChallenge(w, r, user.ID)
+		return
 	}
 
-	http.SetCookie(w, &cookie)
+	if err = s.loginLimiter.RecordSuccess(r.Context(), email, now); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	s.logIn(w, r, user.ID)
+}
+
+// tooManyLogins answers the same whether the email has an account or not
+func (s *Server) tooManyLogins(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	message := fmt.Sprintf("Too many failed logins, try again in %d seconds", seconds)
+	if seconds > 60 {
+		message = fmt.Sprintf("Too many failed logins, try again in %d minutes", int(math.Ceil(retryAfter.Minutes())))
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("HX-Retarget", "#login-form")
+	w.Header().Set("HX-Reswap", "outerHTML")
+	w.WriteHeader(http.StatusTooManyRequests)
+	handler := templates.NewLoginErrorBadRequestHandler(map[string]string{"email": r.Form.Get("email")}, map[string]string{"email": message})
+	handler.ServeHTTP(w, r)
+}
+
+// logIn starts the session of the user and shows them the home page
+func (s *Server) logIn(w http.ResponseWriter, r *http.Request, userId int64) {
+	token, err := s.sessions.Create(r.Context(), userId, getSessionToken(r))
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	setSessionCookie(w, token, s.sessions.TTL())
 	w.Header().Set("HX-Push-Url", "/")
-	c, _ := s.store.GetCategories()
+	c, _ := s.store.GetCategories(r.Context())
 	r = r.WithContext(context.WithValue(r.Context(), "isAuthorized", true))
+	r = withCSRFToken(r, token)
 	handler := templates.NewIndexBodyHandler(c)
 	handler.ServeHTTP(w, r)
 }
@@ -123,7 +182,7 @@ func (s *Server) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.store.GetUserByID(id)
+	user, err := s.store.GetUserByID(r.Context(), id)
 
 	if err != nil {
 		s.internalError(w, r)
@@ -135,6 +194,17 @@ func (s *Server) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	canSeeContacts, err := s.canSeeUserContacts(r.Context(), id)
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if !canSeeContacts {
+		redacted := user.Redacted()
+		user = &redacted
+	}
+
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err = json.NewEncoder(w).Encode(user); err != nil {
@@ -142,17 +212,78 @@ func (s *Server) handleGetUserByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// canSeeUserContacts reports whether the logged-in user is the user with the id or an admin,
+// everyone else only gets to see the redacted contact details
+func (s *Server) canSeeUserContacts(ctx context.Context, userId int64) (bool, error) {
+	viewerId, err := utils.ExtractValueFromContext[int64](ctx, "userId")
+	if err != nil {
+		return false, nil
+	}
+
+	if viewerId == userId {
+		return true, nil
+	}
+
+	return s.userHasRole(ctx, viewerId, types.AdminRole)
+}
+
+const (
+	defaultUsersPageSize = 20
+	maxUsersPageSize     = 100
+)
+
+// parsePositiveQueryInt returns the fallback if the query parameter is absent
+func parsePositiveQueryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+
+	return n, nil
+}
+
 func (s *Server) handleGetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := s.store.GetUsers()
+	page, err := parsePositiveQueryInt(r, "page", 1)
+	if err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
 
+	pageSize, err := parsePositiveQueryInt(r, "pageSize", defaultUsersPageSize)
+	if err != nil {
+		s.badRequestError(w, r, err.Error())
+		return
+	}
+	pageSize = min(pageSize, maxUsersPageSize)
+
+	users, err := s.store.GetUsers(r.Context(), pageSize, (page-1)*pageSize)
 	if err != nil {
 		s.internalError(w, r)
 		return
 	}
 
+	total, err := s.store.CountUsers(r.Context())
+	if err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	// only admins list the users, and they can see everyone's contacts
+	usersPage := types.UsersPage{
+		Users:    users,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}
+
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if err = json.NewEncoder(w).Encode(users); err != nil {
+	if err = json.NewEncoder(w).Encode(usersPage); err != nil {
 		s.logger.Println("ERROR: ", err.Error())
 	}
 }
@@ -194,7 +325,7 @@ func (s *Server) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := s.store.UpdateUser(id, userUpdate)
+	user, err := s.store.UpdateUser(r.Context(), id, userUpdate)
 	if err != nil {
 		s.internalError(w, r)
 		return
@@ -216,7 +347,13 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = s.store.DeleteUser(id); err != nil {
+	// the deleted account is logged out on every device
+	if err = s.sessions.RevokeAll(r.Context(), id); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	if err = s.store.DeleteUser(r.Context(), id); err != nil {
 		s.internalError(w, r)
 		return
 	}
@@ -224,13 +361,30 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (s *Server) handleLogout(w http.ResponseWriter, _ *http.Request) {
-	cookie := http.Cookie{
-		Name:     "userId",
-		Value:    "",
-		MaxAge:   -1,
-		HttpOnly: true,
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if token := getSessionToken(r); token != "" {
+		if err := s.sessions.Revoke(r.Context(), token); err != nil {
+			s.internalError(w, r)
+			return
+		}
 	}
-	http.SetCookie(w, &cookie)
+
+	clearSessionCookie(w)
+	w.Header().Set("HX-Redirect", "/")
+}
+
+func (s *Server) handleLogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	userId, err := utils.ExtractValueFromContext[int64](r.Context(), "userId")
+	if err != nil {
+		s.handleUnauthorized(w, r)
+		return
+	}
+
+	if err = s.sessions.RevokeAll(r.Context(), userId); err != nil {
+		s.internalError(w, r)
+		return
+	}
+
+	clearSessionCookie(w)
 	w.Header().Set("HX-Redirect", "/")
 }
@@ -0,0 +1,22 @@
+package mail
+
+import (
+	"log"
+)
+
+// LogMailer writes the messages to the logger instead of sending them, for local development.
+// Give it a logger writing to a file to keep the messages around
+type LogMailer struct {
+	logger *log.Logger
+}
+
+func NewLogMailer(logger *log.Logger) *LogMailer {
+	return &LogMailer{
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) Send(message Message) error {
+	m.logger.Printf("MAIL to %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Body)
+	return nil
+}
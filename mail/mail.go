@@ -0,0 +1,12 @@
+// Package mail sends the transactional emails of the site, such as password reset links.
+package mail
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPMailer struct {
+	// address is the host:port of the SMTP server
+	address string
+	from    string
+	auth    smtp.Auth
+}
+
+// NewSMTPMailer authenticates with PLAIN auth when the username is set, which net/smtp allows only over TLS or to localhost
+func NewSMTPMailer(address, username, password, from string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPMailer{
+		address: address,
+		from:    from,
+		auth:    auth,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(message Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return fmt.Errorf("mail header contains a line break")
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + m.from + "\r\n")
+	b.WriteString("To: " + message.To + "\r\n")
+	b.WriteString("Subject: " + message.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.address, m.auth, m.from, []string{message.To}, []byte(b.String()))
+}
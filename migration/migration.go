@@ -0,0 +1,139 @@
+// Package migration keeps the database schema in ordered SQL files embedded into the binary.
+// Every version has an up file that applies it and a down file that reverts it,
+// e.g. 000002_create_auctions.up.sql and 000002_create_auctions.down.sql
+package migration
+
+import (
+	"cmp"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dir is where the migrations are kept in the source tree, new ones are created there
+const Dir = "migration/sql"
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// Embedded returns the migrations built into the binary
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(sub)
+}
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%06d_%s", m.Version, m.Name)
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// namePattern is what create accepts, the name becomes a part of the file names
+var namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Load reads the migrations of the directory sorted by version.
+// A version missing either of its files, or two names sharing a version, is an error
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s doesn't match VERSION_NAME.up.sql or VERSION_NAME.down.sql", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s has a bad version", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %s needs both a non-empty up and down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	return migrations, nil
+}
+
+// Create writes the empty up and down files of a migration numbered after the last one in dir and returns their paths
+func Create(dir, name string) (up, down string, err error) {
+	if !namePattern.MatchString(name) {
+		return "", "", errors.New("the migration name may only have lowercase letters, digits and underscores")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	m := Migration{Version: version, Name: name}
+	up = filepath.Join(dir, m.String()+".up.sql")
+	down = filepath.Join(dir, m.String()+".down.sql")
+
+	if err = os.WriteFile(up, []byte("-- "+m.String()+" up\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(down, []byte("-- "+m.String()+" down\n"), 0o644); err != nil {
+		return "", "", err
+	}
+
+	return up, down, nil
+}
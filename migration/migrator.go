@@ -0,0 +1,217 @@
+package migration
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log"
+	"slices"
+	"time"
+)
+
+// advisoryLockKey keeps two migrators, e.g. of two servers deployed at once, from migrating the same database together
+const advisoryLockKey int64 = 0x6f6b74696f6e // "oktion"
+
+const createTableQuery = "CREATE TABLE IF NOT EXISTS schema_migrations (" +
+	"version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now())"
+
+// Status is a migration along with when it was applied, AppliedAt is zero for a pending one
+type Status struct {
+	Migration
+	AppliedAt time.Time
+	// Unknown marks a version applied to the database that this binary doesn't have, e.g. after a rollback of the deployment
+	Unknown bool
+}
+
+func (s Status) IsApplied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	logger     *log.Logger
+}
+
+func NewMigrator(pool *pgxpool.Pool, migrations []Migration, logger *log.Logger) *Migrator {
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+		logger:     logger,
+	}
+}
+
+// withLock runs f on a single connection holding the advisory lock. The lock belongs to the
+// connection session, which is why every statement of the migrator has to go through conn
+func (m *Migrator) withLock(ctx context.Context, f func(conn *pgx.Conn) error) error {
+	poolConn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer poolConn.Release()
+	conn := poolConn.Conn()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey); err != nil {
+		return fmt.Errorf("acquire the migration lock: %w", err)
+	}
+	defer func() {
+		// the lock goes away with the session anyway if the unlock fails, e.g. because ctx is done
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", advisoryLockKey); err != nil {
+			m.logger.Printf("WARN: release the migration lock: %v\n", err)
+		}
+	}()
+
+	if _, err = conn.Exec(ctx, createTableQuery); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return f(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgx.Conn) (map[int64]Status, error) {
+	rows, err := conn.Query(ctx, "SELECT version, name, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]Status)
+	for rows.Next() {
+		var s Status
+		if err = rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[s.Version] = s
+	}
+
+	return applied, rows.Err()
+}
+
+// run applies the SQL and records the result in one transaction, so a failed migration leaves nothing behind
+func (m *Migrator) run(ctx context.Context, conn *pgx.Conn, sql string, record func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// without arguments pgx sends the SQL as a simple query, which may hold many statements
+	if _, err = tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+
+	if err = record(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Up applies the pending migrations in the version order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := make([]Migration, 0)
+
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err = m.run(ctx, conn, migration.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("apply migration %s: %w", migration, err)
+			}
+
+			m.logger.Printf("INFO: applied migration %s\n", migration)
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, latest first, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, errors.New("the number of migrations to revert must be positive")
+	}
+
+	reverted := make([]Migration, 0, steps)
+
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+
+			err = m.run(ctx, conn, migration.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("revert migration %s: %w", migration, err)
+			}
+
+			m.logger.Printf("INFO: reverted migration %s\n", migration)
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status lists every migration of the binary and every version applied to the database, in the version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *pgx.Conn) error {
+		versions, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]Status, 0, len(m.migrations)+len(versions))
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if applied, ok := versions[migration.Version]; ok {
+				status.AppliedAt = applied.AppliedAt
+				delete(versions, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+
+		for _, unknown := range versions {
+			unknown.Unknown = true
+			statuses = append(statuses, unknown)
+		}
+
+		slices.SortFunc(statuses, func(a, b Status) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+
+		return nil
+	})
+
+	return statuses, err
+}
@@ -0,0 +1,22 @@
+CREATE TABLE users (
+    id                bigserial PRIMARY KEY,
+    email             text        NOT NULL UNIQUE,
+    phone             text        NOT NULL DEFAULT '',
+    fullname          text        NOT NULL DEFAULT '',
+    password          text        NOT NULL,
+    roles             text[]      NOT NULL DEFAULT '{bidder,seller}',
+    suspended_at      timestamptz,
+    email_verified_at timestamptz
+);
+
+-- audit entries outlive the users they name, so neither id is a foreign key
+CREATE TABLE audit_entry (
+    id         bigserial PRIMARY KEY,
+    actor_id   bigint      NOT NULL,
+    action     text        NOT NULL,
+    target_id  bigint      NOT NULL,
+    reason     text        NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_entry_created_at_idx ON audit_entry (created_at DESC);
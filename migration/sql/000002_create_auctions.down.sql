@@ -0,0 +1,6 @@
+DROP TABLE saved_auction_lots;
+DROP TABLE auction_lot_categories;
+DROP TABLE auction_lot;
+DROP TABLE auction_bid_increment;
+DROP TABLE auction;
+DROP TABLE category;
@@ -0,0 +1,64 @@
+CREATE TABLE category (
+    id   bigserial PRIMARY KEY,
+    name text NOT NULL UNIQUE
+);
+
+CREATE TABLE auction (
+    id                         bigserial PRIMARY KEY,
+    owner_id                   bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name                       text        NOT NULL,
+    description                text        NOT NULL DEFAULT '',
+    is_active                  boolean     NOT NULL DEFAULT false,
+    is_private                 boolean     NOT NULL DEFAULT false,
+    created_at                 timestamptz NOT NULL DEFAULT now(),
+    updated_at                 timestamptz NOT NULL DEFAULT now(),
+    deleted_at                 timestamptz,
+    starts_at                  timestamptz,
+    ends_at                    timestamptz,
+    is_started                 boolean     NOT NULL DEFAULT false,
+    is_closed                  boolean     NOT NULL DEFAULT false,
+    soft_close_seconds         bigint      NOT NULL DEFAULT 0,
+    require_manager_two_factor boolean     NOT NULL DEFAULT false
+);
+
+CREATE INDEX auction_owner_id_idx ON auction (owner_id);
+CREATE INDEX auction_created_at_idx ON auction (created_at DESC);
+
+CREATE TABLE auction_bid_increment (
+    auction_id bigint  NOT NULL REFERENCES auction (id) ON DELETE CASCADE,
+    price_from numeric NOT NULL,
+    amount     numeric NOT NULL,
+    PRIMARY KEY (auction_id, price_from)
+);
+
+CREATE TABLE auction_lot (
+    id            bigserial PRIMARY KEY,
+    auction_id    bigint      NOT NULL REFERENCES auction (id) ON DELETE CASCADE,
+    name          text        NOT NULL,
+    description   text        NOT NULL DEFAULT '',
+    is_active     boolean     NOT NULL DEFAULT false,
+    is_closed     boolean     NOT NULL DEFAULT false,
+    outcome       text        NOT NULL DEFAULT '',
+    minimal_bid   numeric     NOT NULL DEFAULT 0,
+    reserve_price numeric     NOT NULL DEFAULT 0,
+    bin_price     numeric     NOT NULL DEFAULT 0,
+    starts_at     timestamptz,
+    ends_at       timestamptz,
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+    deleted_at    timestamptz
+);
+
+CREATE INDEX auction_lot_auction_id_idx ON auction_lot (auction_id);
+
+CREATE TABLE auction_lot_categories (
+    auction_lot_id bigint PRIMARY KEY REFERENCES auction_lot (id) ON DELETE CASCADE,
+    category_id    bigint NOT NULL REFERENCES category (id) ON DELETE CASCADE
+);
+
+CREATE TABLE saved_auction_lots (
+    user_id        bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    auction_lot_id bigint      NOT NULL REFERENCES auction_lot (id) ON DELETE CASCADE,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, auction_lot_id)
+);
@@ -0,0 +1,3 @@
+DROP TABLE auction_lot_winner;
+DROP TABLE max_bid;
+DROP TABLE bid;
@@ -0,0 +1,26 @@
+CREATE TABLE bid (
+    id             bigserial PRIMARY KEY,
+    auction_lot_id bigint      NOT NULL REFERENCES auction_lot (id) ON DELETE CASCADE,
+    user_id        bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    value          numeric     NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX bid_auction_lot_id_value_idx ON bid (auction_lot_id, value DESC);
+CREATE INDEX bid_created_at_idx ON bid (created_at DESC);
+
+CREATE TABLE max_bid (
+    id             bigserial PRIMARY KEY,
+    auction_lot_id bigint      NOT NULL REFERENCES auction_lot (id) ON DELETE CASCADE,
+    user_id        bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    amount         numeric     NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (auction_lot_id, user_id)
+);
+
+CREATE TABLE auction_lot_winner (
+    auction_lot_id bigint PRIMARY KEY REFERENCES auction_lot (id) ON DELETE CASCADE,
+    user_id        bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    bid_id         bigint      NOT NULL REFERENCES bid (id) ON DELETE CASCADE,
+    won_at         timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,9 @@
+DROP TABLE api_token;
+DROP TABLE external_identity;
+DROP TABLE login_throttle;
+DROP TABLE login_challenge;
+DROP TABLE two_factor_recovery_code;
+DROP TABLE two_factor;
+DROP TABLE email_verification_token;
+DROP TABLE password_reset_token;
+DROP TABLE session;
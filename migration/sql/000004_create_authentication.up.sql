@@ -0,0 +1,83 @@
+-- the ids of the sessions and the emailed tokens are the SHA-256 hashes of the tokens
+CREATE TABLE session (
+    id           text PRIMARY KEY,
+    user_id      bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at   timestamptz NOT NULL,
+    expires_at   timestamptz NOT NULL,
+    last_seen_at timestamptz NOT NULL
+);
+
+CREATE INDEX session_user_id_idx ON session (user_id);
+CREATE INDEX session_expires_at_idx ON session (expires_at);
+
+CREATE TABLE password_reset_token (
+    id         text PRIMARY KEY,
+    user_id    bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz
+);
+
+CREATE INDEX password_reset_token_user_id_idx ON password_reset_token (user_id);
+
+CREATE TABLE email_verification_token (
+    id         text PRIMARY KEY,
+    user_id    bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      text        NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX email_verification_token_user_id_idx ON email_verification_token (user_id, created_at);
+
+CREATE TABLE two_factor (
+    user_id        bigint PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         text        NOT NULL,
+    enabled_at     timestamptz,
+    last_used_step bigint      NOT NULL DEFAULT 0
+);
+
+CREATE TABLE two_factor_recovery_code (
+    user_id   bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash text   NOT NULL,
+    used_at   timestamptz,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+CREATE TABLE login_challenge (
+    id         text PRIMARY KEY,
+    user_id    bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL,
+    attempts   integer     NOT NULL DEFAULT 0
+);
+
+-- the keys are made of the emails as typed, so they don't reference the users
+CREATE TABLE login_throttle (
+    key             text PRIMARY KEY,
+    failures        integer     NOT NULL,
+    last_failure_at timestamptz NOT NULL
+);
+
+CREATE INDEX login_throttle_last_failure_at_idx ON login_throttle (last_failure_at);
+
+CREATE TABLE external_identity (
+    issuer     text        NOT NULL,
+    subject    text        NOT NULL,
+    user_id    bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      text        NOT NULL,
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE TABLE api_token (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         text        NOT NULL,
+    token_hash   text        NOT NULL UNIQUE,
+    scopes       text[]      NOT NULL DEFAULT '{}',
+    created_at   timestamptz NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz
+);
+
+CREATE INDEX api_token_user_id_idx ON api_token (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE auction_lot DROP COLUMN moderated_at;
+
+ALTER TABLE auction DROP COLUMN moderated_at;
@@ -0,0 +1,3 @@
+ALTER TABLE auction ADD COLUMN moderated_at timestamptz;
+
+ALTER TABLE auction_lot ADD COLUMN moderated_at timestamptz;
@@ -0,0 +1,183 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE for logging in
+// through an external identity provider. Only the RS256 ID tokens every provider supports are accepted.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("the ID token is invalid")
+	// ErrUnknownKey is returned for an ID token signed with a key the provider doesn't publish
+	ErrUnknownKey = errors.New("the ID token is signed with an unknown key")
+)
+
+// clockSkew is how far the clocks of the provider and ours may drift apart
+const clockSkew = time.Minute
+
+type Config struct {
+	// Issuer is the URL the provider publishes its configuration under, see Discover
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the user back with the code
+	RedirectURL string
+	// Name is what the login button calls the provider
+	Name string
+}
+
+// metadata is the part of the provider configuration the flow uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	config   Config
+	metadata metadata
+	client   *http.Client
+
+	// keysMu guards the signing keys, which are fetched again when a token names an unknown one
+	keysMu sync.Mutex
+	keys   map[string]*jsonWebKey
+}
+
+// Discover reads the provider configuration from the well-known URL of the issuer
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &Provider{
+		config: config,
+		client: client,
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.metadata); err != nil {
+		return nil, fmt.Errorf("discover the provider: %w", err)
+	}
+
+	// a configuration served for another issuer must not be trusted, see OpenID Connect Discovery 4.3
+	if p.metadata.Issuer != config.Issuer {
+		return nil, fmt.Errorf("the provider issuer %q doesn't match the configured %q", p.metadata.Issuer, config.Issuer)
+	}
+
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, errors.New("the provider configuration lacks an endpoint")
+	}
+
+	return p, nil
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// NewPKCEChallenge returns the S256 code challenge of the verifier, see RFC 7636
+func NewPKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where the user is sent to log in. The state and the nonce tie the callback
+// and the ID token to this login, the verifier is kept for Exchange
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", "openid email profile")
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", NewPKCEChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades the code for the ID token and returns its verified claims
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens tokenResponse
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("exchange the code: %s: %w", res.Status, err)
+	}
+
+	if res.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("exchange the code: %s: %s %s", res.Status, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return nil, errors.New("exchange the code: the response has no ID token")
+	}
+
+	return p.VerifyIDToken(ctx, tokens.IDToken, nonce, time.Now())
+}
@@ -0,0 +1,383 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/artemsmotritel/oktion/oidc"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "oktion"
+	testClientSecret = "s3cret"
+	testRedirectURL  = "http://oktion.test/login/oidc/callback"
+)
+
+type authorization struct {
+	challenge string
+	nonce     string
+}
+
+// mockProvider is an OpenID provider that logs in whoever is set as the user
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	keyID  string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+	// claims overrides the claims of the next ID tokens
+	claims map[string]any
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{
+		key:    key,
+		keyID:  "key-1",
+		codes:  make(map[string]authorization),
+		claims: make(map[string]any),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.handleDiscovery)
+	mux.HandleFunc("GET /authorize", m.handleAuthorize)
+	mux.HandleFunc("POST /token", m.handleToken)
+	mux.HandleFunc("GET /jwks", m.handleKeys)
+
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+func (m *mockProvider) issuer() string {
+	return m.server.URL
+}
+
+func (m *mockProvider) config() oidc.Config {
+	return oidc.Config{
+		Issuer:       m.issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Name:         "Mock",
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (m *mockProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 m.issuer(),
+		"authorization_endpoint": m.issuer() + "/authorize",
+		"token_endpoint":         m.issuer() + "/token",
+		"jwks_uri":               m.issuer() + "/jwks",
+	})
+}
+
+func (m *mockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || !strings.Contains(q.Get("scope"), "openid") {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	code := base64.RawURLEncoding.EncodeToString([]byte(q.Get("state") + q.Get("nonce")))
+
+	m.mu.Lock()
+	m.codes[code] = authorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", q.Get("state"))
+	redirect.RawQuery = query.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != testClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(testClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("redirect_uri") != testRedirectURL {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.Form.Get("code")]
+	// a code works once
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || oidc.NewPKCEChallenge(r.Form.Get("code_verifier")) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss":            m.issuer(),
+		"sub":            "248289761001",
+		"aud":            testClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          auth.nonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+	m.mu.Lock()
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	m.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     m.sign(claims, m.key, m.keyID),
+	})
+}
+
+func (m *mockProvider) handleKeys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": m.keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockProvider) sign(claims map[string]any, key *rsa.PrivateKey, keyID string) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize follows the login URL to the provider and returns the query the user is sent back with
+func authorize(t *testing.T, loginURL string) url.Values {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: got status %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return location.Query()
+}
+
+func discover(t *testing.T, m *mockProvider) *oidc.Provider {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), m.config(), m.server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func TestLoginFlow(t *testing.T) {
+	m := newMockProvider(t)
+	provider := discover(t, m)
+
+	callback := authorize(t, provider.AuthCodeURL("state-1", "nonce-1", "verifier-1"))
+	if callback.Get("state") != "state-1" {
+		t.Fatalf("got state %q, want %q", callback.Get("state"), "state-1")
+	}
+
+	claims, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Issuer != m.issuer() || claims.Subject != "248289761001" || claims.Email != "jane@example.com" || !claims.EmailVerified || claims.Name != "Jane Doe" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	m := newMockProvider(t)
+	provider := discover(t, m)
+
+	callback := authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+
+	if _, err := provider.Exchange(context.Background(), callback.Get("code"), "another-verifier", "nonce"); err == nil {
+		t.Fatal("the code was exchanged without the right verifier")
+	}
+}
+
+func TestExchangeRejectsReusedCode(t *testing.T) {
+	m := newMockProvider(t)
+	provider := discover(t, m)
+
+	callback := authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+
+	if _, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce"); err == nil {
+		t.Fatal("the code was exchanged twice")
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	m := newMockProvider(t)
+	provider := discover(t, m)
+
+	callback := authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+
+	_, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier", "another-nonce")
+	if !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Fatalf("got error %v, want %v", err, oidc.ErrInvalidIDToken)
+	}
+}
+
+func TestEmailVerifiedAsString(t *testing.T) {
+	m := newMockProvider(t)
+	m.claims["email_verified"] = "false"
+	provider := discover(t, m)
+
+	callback := authorize(t, provider.AuthCodeURL("state", "nonce", "verifier"))
+
+	claims, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.EmailVerified {
+		t.Fatal("an unverified email was taken as verified")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	m := newMockProvider(t)
+	provider := discover(t, m)
+
+	now := time.Now()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   m.issuer(),
+			"sub":   "subject",
+			"aud":   testClientID,
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce",
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := valid()
+		claims[key] = value
+		return claims
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "none"})
+	payload, _ := json.Marshal(valid())
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", m.sign(valid(), m.key, m.keyID), nil},
+		{"audience list", m.sign(with("aud", []string{testClientID}), m.key, m.keyID), nil},
+		{"another issuer", m.sign(with("iss", "https://evil.test"), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"another audience", m.sign(with("aud", "someone-else"), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"several audiences without azp", m.sign(with("aud", []string{testClientID, "someone-else"}), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"expired", m.sign(with("exp", now.Add(-time.Hour).Unix()), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"issued in the future", m.sign(with("iat", now.Add(time.Hour).Unix()), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"another nonce", m.sign(with("nonce", "replayed"), m.key, m.keyID), oidc.ErrInvalidIDToken},
+		{"signed by another key", m.sign(valid(), otherKey, m.keyID), oidc.ErrInvalidIDToken},
+		{"unknown key", m.sign(valid(), otherKey, "key-2"), oidc.ErrUnknownKey},
+		{"unsigned", unsigned, oidc.ErrInvalidIDToken},
+		{"malformed", "not-a-token", oidc.ErrInvalidIDToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(context.Background(), tt.token, "nonce", now)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoverRejectsAnotherIssuer(t *testing.T) {
+	m := newMockProvider(t)
+
+	config := m.config()
+	config.Issuer = m.issuer() + "/"
+
+	if _, err := oidc.Discover(context.Background(), config, m.server.Client()); err == nil {
+		t.Fatal("the configuration of another issuer was accepted")
+	}
+}
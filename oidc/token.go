@@ -0,0 +1,218 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Claims are the ID token claims the login uses
+type Claims struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	// AuthorizedParty is the client the token was issued to when it has several audiences
+	AuthorizedParty string
+	ExpiresAt       time.Time
+	IssuedAt        time.Time
+	Nonce           string
+	Email           string
+	EmailVerified   bool
+	Name            string
+}
+
+type rawClaims struct {
+	Issuer          string          `json:"iss"`
+	Subject         string          `json:"sub"`
+	Audience        json.RawMessage `json:"aud"`
+	AuthorizedParty string          `json:"azp"`
+	ExpiresAt       float64         `json:"exp"`
+	IssuedAt        float64         `json:"iat"`
+	Nonce           string          `json:"nonce"`
+	Email           string          `json:"email"`
+	// EmailVerified is a boolean, but some providers send it as a string
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Name          string          `json:"name"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type jsonWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+
+	publicKey *rsa.PublicKey
+}
+
+type jsonWebKeySet struct {
+	Keys []*jsonWebKey `json:"keys"`
+}
+
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// VerifyIDToken checks the signature of the token and that it was issued by the provider
+// to this client for the login with the nonce, see OpenID Connect Core 3.1.3.7
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string, now time.Time) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidIDToken)
+	}
+
+	// the algorithm is never taken from the token beyond this check, so "none" and HMAC tokens can't pass
+	if h.Algorithm != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, h.Algorithm)
+	}
+
+	key, err := p.signingKey(ctx, h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var raw rawClaims
+	if err = decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidIDToken)
+	}
+
+	claims, err := raw.claims()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case claims.Issuer != p.metadata.Issuer:
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidIDToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return nil, fmt.Errorf("%w: issued for another client", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+		return nil, fmt.Errorf("%w: authorized for another client", ErrInvalidIDToken)
+	case !now.Before(claims.ExpiresAt.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case claims.IssuedAt.After(now.Add(clockSkew)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: the nonce doesn't match", ErrInvalidIDToken)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func (raw *rawClaims) claims() (*Claims, error) {
+	claims := &Claims{
+		Issuer:          raw.Issuer,
+		Subject:         raw.Subject,
+		AuthorizedParty: raw.AuthorizedParty,
+		ExpiresAt:       time.Unix(int64(raw.ExpiresAt), 0),
+		IssuedAt:        time.Unix(int64(raw.IssuedAt), 0),
+		Nonce:           raw.Nonce,
+		Email:           raw.Email,
+		Name:            raw.Name,
+	}
+
+	var audience string
+	if err := json.Unmarshal(raw.Audience, &audience); err == nil {
+		claims.Audience = []string{audience}
+	} else if err = json.Unmarshal(raw.Audience, &claims.Audience); err != nil {
+		return nil, fmt.Errorf("%w: malformed audience", ErrInvalidIDToken)
+	}
+
+	var verified any
+	if len(raw.EmailVerified) > 0 {
+		if err := json.Unmarshal(raw.EmailVerified, &verified); err != nil {
+			return nil, fmt.Errorf("%w: malformed email_verified", ErrInvalidIDToken)
+		}
+	}
+	claims.EmailVerified = verified == true || verified == "true"
+
+	return claims, nil
+}
+
+// signingKey fetches the keys again when the token names an unknown one, which is how providers rotate them
+func (p *Provider) signingKey(ctx context.Context, keyId string) (*rsa.PublicKey, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if key, ok := p.keys[keyId]; ok {
+		return key.publicKey, nil
+	}
+
+	var set jsonWebKeySet
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetch the provider keys: %w", err)
+	}
+
+	p.keys = make(map[string]*jsonWebKey)
+	for _, key := range set.Keys {
+		if key.KeyType != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := key.rsaPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		key.publicKey = publicKey
+		p.keys[key.KeyID] = key
+	}
+
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key.publicKey, nil
+}
+
+func (k *jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, fmt.Errorf("malformed modulus of the key %q", k.KeyID)
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("malformed exponent of the key %q", k.KeyID)
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
@@ -0,0 +1,130 @@
+// Package proxybid implements the automatic (proxy) bidding.
+//
+// Users submit hidden maximum bids and the engine bids on their behalf against the competing bids,
+// raising the price only as much as needed to keep the lead, up to the maximum.
+// A plain bid is never outbid by a maximum of the same value, while a tie between two maximums
+// is won by the one placed earlier.
+//
+// The package doesn't touch the storage, so the stores call Resolve inside their bid placing transactions.
+package proxybid
+
+import (
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/shopspring/decimal"
+	"slices"
+	"time"
+)
+
+// Lot is the bidding state of an auction lot
+type Lot struct {
+	MinimalBid decimal.Decimal
+	Increments types.BidIncrements
+	// HighestBid is nil when the lot doesn't have any bids yet
+	HighestBid *types.Bid
+	MaxBids    []types.MaxBid
+}
+
+// Resolve returns the bids that have to be placed on behalf of the max bidders, in the order they must be placed.
+// The bids are not saved, their AuctionLotID is taken from the max bids and CreatedAt is set to now
+func Resolve(lot Lot, now time.Time) []types.Bid {
+	maxBids := sortMaxBids(lot.MaxBids)
+	current := lot.HighestBid
+	placed := make([]types.Bid, 0)
+
+	place := func(maxBid *types.MaxBid, value decimal.Decimal) {
+		bid := types.Bid{
+			AuctionLotID: maxBid.AuctionLotID,
+			UserID:       maxBid.UserID,
+			Value:        value,
+			CreatedAt:    now,
+		}
+		placed = append(placed, bid)
+		current = &placed[len(placed)-1]
+	}
+
+	// every contest leaves the loser's maximum below the price, so it never qualifies again,
+	// which makes the number of iterations bounded by the number of max bids
+	for range 2*len(maxBids) + 1 {
+		nextMinimalBid := lot.Increments.NextMinimalBid(lot.MinimalBid, current)
+		challenger := findChallenger(maxBids, current, nextMinimalBid)
+		if challenger == nil {
+			break
+		}
+
+		defender := findDefender(maxBids, current)
+		if defender == nil {
+			place(challenger, nextMinimalBid)
+			continue
+		}
+
+		winner, loser := defender, challenger
+		if beats(challenger, defender) {
+			winner, loser = challenger, defender
+		}
+
+		if winner.Amount.Equal(loser.Amount) {
+			place(winner, winner.Amount)
+			continue
+		}
+
+		// the leader's maximum may be too close to the price to make a valid bid, the winner outbids it all the same
+		if loser.Amount.GreaterThanOrEqual(nextMinimalBid) {
+			place(loser, loser.Amount)
+		}
+		place(winner, decimal.Min(winner.Amount, loser.Amount.Add(lot.Increments.For(loser.Amount))))
+	}
+
+	return placed
+}
+
+// sortMaxBids orders the max bids from the strongest to the weakest: by amount and then by the placing time
+func sortMaxBids(maxBids []types.MaxBid) []types.MaxBid {
+	sorted := slices.Clone(maxBids)
+	slices.SortStableFunc(sorted, func(a, b types.MaxBid) int {
+		if c := b.Amount.Compare(a.Amount); c != 0 {
+			return c
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return sorted
+}
+
+// findChallenger returns the strongest max bid of someone except the current leader that can outbid the current price
+func findChallenger(maxBids []types.MaxBid, current *types.Bid, nextMinimalBid decimal.Decimal) *types.MaxBid {
+	for i := range maxBids {
+		if current != nil && maxBids[i].UserID == current.UserID {
+			continue
+		}
+		if maxBids[i].Amount.GreaterThanOrEqual(nextMinimalBid) {
+			return &maxBids[i]
+		}
+		// the rest are even weaker
+		return nil
+	}
+
+	return nil
+}
+
+// findDefender returns the max bid of the current leader if it still leaves room to raise the price
+func findDefender(maxBids []types.MaxBid, current *types.Bid) *types.MaxBid {
+	if current == nil {
+		return nil
+	}
+
+	for i := range maxBids {
+		if maxBids[i].UserID == current.UserID && maxBids[i].Amount.GreaterThan(current.Value) {
+			return &maxBids[i]
+		}
+	}
+
+	return nil
+}
+
+func beats(a, b *types.MaxBid) bool {
+	if c := a.Amount.Compare(b.Amount); c != 0 {
+		return c > 0
+	}
+
+	return a.CreatedAt.Before(b.CreatedAt)
+}
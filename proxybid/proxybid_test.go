@@ -0,0 +1,110 @@
+package proxybid_test
+
+import (
+	"github.com/artemsmotritel/oktion/proxybid"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/shopspring/decimal"
+	"testing"
+	"time"
+)
+
+const (
+	alice int64 = iota + 1
+	bob
+	carol
+)
+
+type placedBid struct {
+	userId int64
+	value  string
+}
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	increments := types.BidIncrements{{From: decimal.Zero, Amount: decimal.NewFromInt(5)}}
+
+	maxBid := func(userId int64, amount int64, placedBefore time.Duration) types.MaxBid {
+		return types.MaxBid{AuctionLotID: 1, UserID: userId, Amount: decimal.NewFromInt(amount), CreatedAt: now.Add(-placedBefore)}
+	}
+	bid := func(userId int64, value int64) *types.Bid {
+		return &types.Bid{AuctionLotID: 1, UserID: userId, Value: decimal.NewFromInt(value), CreatedAt: now.Add(-time.Hour)}
+	}
+
+	tests := []struct {
+		name       string
+		highestBid *types.Bid
+		maxBids    []types.MaxBid
+		want       []placedBid
+	}{
+		{
+			name:    "tied max bids are won by the earlier one",
+			maxBids: []types.MaxBid{maxBid(bob, 50, time.Minute), maxBid(alice, 50, 2*time.Minute)},
+			want:    []placedBid{{alice, "10"}, {alice, "50"}},
+		},
+		{
+			name:       "a single max bid outbids the plain highest bid by one step",
+			highestBid: bid(carol, 20),
+			maxBids:    []types.MaxBid{maxBid(alice, 50, time.Minute)},
+			want:       []placedBid{{alice, "25"}},
+		},
+		{
+			name:    "the winner outbids the loser by one step",
+			maxBids: []types.MaxBid{maxBid(alice, 100, 2*time.Minute), maxBid(bob, 60, time.Minute)},
+			want:    []placedBid{{alice, "10"}, {bob, "60"}, {alice, "65"}},
+		},
+		{
+			name:    "the winner stops at a cap lower than the next step",
+			maxBids: []types.MaxBid{maxBid(alice, 62, 2*time.Minute), maxBid(bob, 60, time.Minute)},
+			want:    []placedBid{{alice, "10"}, {bob, "60"}, {alice, "62"}},
+		},
+		{
+			name:       "the highest bidder doesn't outbid themselves",
+			highestBid: bid(alice, 20),
+			maxBids:    []types.MaxBid{maxBid(alice, 50, time.Minute)},
+			want:       []placedBid{},
+		},
+		{
+			name:       "the loser's maximum below the next minimal bid isn't placed",
+			highestBid: bid(alice, 100),
+			maxBids:    []types.MaxBid{maxBid(alice, 103, 2*time.Minute), maxBid(bob, 200, time.Minute)},
+			want:       []placedBid{{bob, "108"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lot := proxybid.Lot{
+				MinimalBid: decimal.NewFromInt(10),
+				Increments: increments,
+				HighestBid: test.highestBid,
+				MaxBids:    test.maxBids,
+			}
+
+			got := proxybid.Resolve(lot, now)
+
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d bids %v, want %v", len(got), got, test.want)
+			}
+			for i, want := range test.want {
+				if got[i].UserID != want.userId || !got[i].Value.Equal(decimal.RequireFromString(want.value)) {
+					t.Errorf("bid %d: got user %d bidding %s, want user %d bidding %s", i, got[i].UserID, got[i].Value, want.userId, want.value)
+				}
+				if got[i].AuctionLotID != 1 || !got[i].CreatedAt.Equal(now) {
+					t.Errorf("bid %d: got lot %d placed at %v, want lot 1 placed at %v", i, got[i].AuctionLotID, got[i].CreatedAt, now)
+				}
+			}
+
+			// every placed bid has to be a valid bid on the lot
+			current := test.highestBid
+			for i := range got {
+				if current != nil && got[i].UserID == current.UserID && got[i].Value.LessThan(current.Value) {
+					t.Errorf("bid %d: user %d lowered their own bid to %s", i, got[i].UserID, got[i].Value)
+				}
+				if current != nil && got[i].UserID != current.UserID && !got[i].Value.GreaterThan(current.Value) {
+					t.Errorf("bid %d: %s doesn't outbid %s", i, got[i].Value, current.Value)
+				}
+				current = &got[i]
+			}
+		})
+	}
+}
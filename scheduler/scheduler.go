@@ -0,0 +1,120 @@
+// Package scheduler opens and closes auctions and auction lots at their scheduled times.
+// It keeps no state of its own: every run reads the pending transitions from storage,
+// so transitions missed while the app was down are applied as soon as it starts again.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/artemsmotritel/oktion/storage"
+	"log"
+	"time"
+)
+
+// Listener is notified about the transitions the scheduler has applied
+type Listener interface {
+	AuctionOpened(ctx context.Context, auctionId int64)
+	AuctionLotClosed(ctx context.Context, auctionLotId int64)
+}
+
+type Scheduler struct {
+	store    storage.Storage
+	listener Listener
+	logger   *log.Logger
+	// maxInterval bounds the sleep between runs, so schedules changed without Wake are still picked up
+	maxInterval time.Duration
+	wake        chan struct{}
+}
+
+func NewScheduler(store storage.Storage, logger *log.Logger, maxInterval time.Duration) *Scheduler {
+	return &Scheduler{
+		store:       store,
+		logger:      logger,
+		maxInterval: maxInterval,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetListener must be called before Run
+func (s *Scheduler) SetListener(listener Listener) {
+	s.listener = listener
+}
+
+// Run applies the due transitions and sleeps until the next one until the context is done
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		now := time.Now()
+		s.runDue(ctx, now)
+
+		wait := s.maxInterval
+		next, err := s.store.GetNextScheduledTransition(ctx, now)
+		if err != nil {
+			s.logger.Println("ERROR: scheduler; next transition:", err.Error())
+		} else if next.Valid {
+			wait = min(wait, max(time.Until(next.Time), 0))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Wake makes the scheduler look for due transitions right away. It must be called after a schedule has changed
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	opened, err := s.store.OpenDueAuctions(ctx, now)
+	if err != nil {
+		s.logger.Println("ERROR: scheduler; open auctions:", err.Error())
+	}
+	for _, id := range opened {
+		s.logger.Printf("INFO: scheduler; opened auction id=%d\n", id)
+		if s.listener != nil {
+			s.listener.AuctionOpened(ctx, id)
+		}
+	}
+
+	lotIds, err := s.store.GetDueAuctionLotIDs(ctx, now)
+	if err != nil {
+		s.logger.Println("ERROR: scheduler; due auction lots:", err.Error())
+	}
+	for _, id := range lotIds {
+		winner, err := s.store.CloseEndedAuctionLot(ctx, id, now)
+		if err != nil {
+			// the seller may have closed the lot by hand or a late bid may have extended it in the meantime
+			if !errors.Is(err, storage.ErrUnexpectedLotOutcome) && !errors.Is(err, storage.ErrAuctionLotNotEnded) {
+				s.logger.Printf("ERROR: scheduler; close auction lot id=%d: %s\n", id, err.Error())
+			}
+			continue
+		}
+
+		if winner != nil {
+			s.logger.Printf("INFO: scheduler; closed auction lot id=%d, winner id=%d\n", id, winner.UserID)
+		} else {
+			s.logger.Printf("INFO: scheduler; closed auction lot id=%d unsold\n", id)
+		}
+		if s.listener != nil {
+			s.listener.AuctionLotClosed(ctx, id)
+		}
+	}
+
+	closed, err := s.store.CloseDueAuctions(ctx, now)
+	if err != nil {
+		s.logger.Println("ERROR: scheduler; close auctions:", err.Error())
+	}
+	for _, id := range closed {
+		s.logger.Printf("INFO: scheduler; closed auction id=%d\n", id)
+	}
+}
@@ -0,0 +1,330 @@
+// Package seed generates the demo and load test data. The data depends only on the Config,
+// so every store seeded with the same Config holds the same users, auctions and bids with the same ids
+package seed
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/shopspring/decimal"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Password is what every seeded user logs in with
+const Password = "oktion-demo"
+
+// AdminEmail is the email of the first seeded user, who holds every role
+const AdminEmail = "admin@oktion.local"
+
+type Config struct {
+	// Seed is the seed of the random source, the same seed generates the same data
+	Seed              uint64
+	Users             int
+	Auctions          int
+	LotsPerAuction    int
+	MaxBidsPerLot     int
+	FavouritesPerUser int
+	// Now is the time the schedules are generated around: a third of the auctions has ended before it,
+	// a third is open and a third starts after it
+	Now time.Time
+}
+
+var DefaultConfig = Config{
+	Seed:              1,
+	Users:             50,
+	Auctions:          30,
+	LotsPerAuction:    5,
+	MaxBidsPerLot:     8,
+	FavouritesPerUser: 4,
+}
+
+func (c Config) Validate() error {
+	if c.Users < 1 {
+		return fmt.Errorf("at least one user is needed, got %d", c.Users)
+	}
+	if c.Auctions < 0 || c.LotsPerAuction < 0 || c.MaxBidsPerLot < 0 || c.FavouritesPerUser < 0 {
+		return fmt.Errorf("the volumes can't be negative")
+	}
+	if c.Now.IsZero() {
+		return fmt.Errorf("the time to generate the schedules around is not set")
+	}
+	return nil
+}
+
+// Dataset is the generated data, the ids start at 1 in every table and the slices are sorted by id.
+// Only the password hashes differ between two datasets of the same config, as they are salted
+type Dataset struct {
+	Users            []types.User
+	Categories       []types.Category
+	Auctions         []types.Auction
+	AuctionLots      []types.AuctionLot
+	Bids             []types.Bid
+	Winners          []types.AuctionLotWinner
+	SavedAuctionLots []types.SavedAuctionLot
+}
+
+var categoryNames = []string{
+	"Antiques", "Art", "Automotive", "Books", "Clothes", "Collectibles",
+	"Electronics", "Home & Garden", "Jewellery", "Music", "Sport", "Toys",
+}
+
+var firstNames = []string{
+	"Olena", "Taras", "Iryna", "Andrii", "Sofiia", "Dmytro", "Kateryna", "Oleksandr",
+	"Mariia", "Bohdan", "Anna", "Maksym", "Yuliia", "Serhii", "Oksana", "Viktor",
+}
+
+var lastNames = []string{
+	"Kovalenko", "Bondarenko", "Tkachenko", "Shevchenko", "Kravchenko", "Melnyk",
+	"Boiko", "Koval", "Oliinyk", "Lysenko", "Marchenko", "Savchenko",
+}
+
+var lotAdjectives = []string{"Vintage", "Rare", "Signed", "Restored", "Limited edition", "Handmade", "Antique", "Mint condition"}
+
+var lotNouns = map[string][]string{
+	"Antiques":      {"pocket watch", "oak chest", "brass samovar", "porcelain vase"},
+	"Art":           {"oil painting", "linocut print", "bronze sculpture", "watercolour"},
+	"Automotive":    {"motorcycle helmet", "car radio", "alloy wheel set", "enamel badge"},
+	"Books":         {"first edition novel", "atlas", "poetry collection", "cookbook"},
+	"Clothes":       {"embroidered shirt", "leather jacket", "wool coat", "silk scarf"},
+	"Collectibles":  {"stamp album", "coin set", "trading card", "postcard collection"},
+	"Electronics":   {"film camera", "turntable", "tube amplifier", "game console"},
+	"Home & Garden": {"tea set", "garden bench", "table lamp", "rug"},
+	"Jewellery":     {"silver ring", "amber necklace", "gold brooch", "pearl earrings"},
+	"Music":         {"acoustic guitar", "vinyl record", "bandura", "violin"},
+	"Sport":         {"road bike", "tennis racket", "signed football", "pair of skis"},
+	"Toys":          {"tin robot", "wooden train", "porcelain doll", "model plane"},
+}
+
+// Generate builds the dataset of the config
+func Generate(config Config) (*Dataset, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		config: config,
+		rand:   rand.New(rand.NewPCG(config.Seed, config.Seed^0x9e3779b97f4a7c15)),
+		now:    config.Now.Truncate(time.Second),
+		data:   &Dataset{},
+	}
+
+	// every user shares the password, so it is hashed only once
+	password, err := argon2id.CreateHash(Password, argon2id.DefaultParams)
+	if err != nil {
+		return nil, err
+	}
+
+	g.generateCategories()
+	g.generateUsers(password)
+	g.generateAuctions()
+	g.generateFavourites()
+
+	return g.data, nil
+}
+
+type generator struct {
+	config Config
+	rand   *rand.Rand
+	now    time.Time
+	data   *Dataset
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rand.IntN(len(values))]
+}
+
+// ago returns a time up to max before the end
+func (g *generator) ago(end time.Time, max time.Duration) time.Time {
+	return end.Add(-time.Duration(g.rand.Int64N(int64(max)))).Truncate(time.Second)
+}
+
+func (g *generator) generateCategories() {
+	for i, name := range categoryNames {
+		g.data.Categories = append(g.data.Categories, types.Category{ID: int64(i + 1), Name: name})
+	}
+}
+
+func (g *generator) generateUsers(password string) {
+	for i := 1; i <= g.config.Users; i++ {
+		first, last := g.pick(firstNames), g.pick(lastNames)
+		user := types.User{
+			ID:              int64(i),
+			FullName:        first + " " + last,
+			Email:           fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i),
+			Phone:           fmt.Sprintf("+380%09d", g.rand.IntN(1_000_000_000)),
+			Password:        password,
+			Roles:           types.DefaultRoles,
+			EmailVerifiedAt: sql.NullTime{Time: g.ago(g.now, 365*24*time.Hour), Valid: true},
+		}
+
+		if i == 1 {
+			user.FullName = "Oktion Admin"
+			user.Email = AdminEmail
+			user.Roles = types.AllRoles
+		}
+
+		g.data.Users = append(g.data.Users, user)
+	}
+}
+
+// auctionSchedule spreads the auctions evenly over the ended, open and scheduled ones
+func (g *generator) auctionSchedule(i int) (startsAt, endsAt time.Time) {
+	const day = 24 * time.Hour
+
+	switch i % 3 {
+	case 0:
+		endsAt = g.ago(g.now, 30*day)
+		startsAt = g.ago(endsAt, 14*day)
+	case 1:
+		startsAt = g.ago(g.now, 7*day)
+		endsAt = g.now.Add(time.Hour + time.Duration(g.rand.Int64N(int64(10*day)))).Truncate(time.Second)
+	default:
+		startsAt = g.now.Add(time.Hour + time.Duration(g.rand.Int64N(int64(14*day)))).Truncate(time.Second)
+		endsAt = startsAt.Add(day + time.Duration(g.rand.Int64N(int64(7*day)))).Truncate(time.Second)
+	}
+
+	return startsAt, endsAt
+}
+
+func (g *generator) generateAuctions() {
+	for i := 1; i <= g.config.Auctions; i++ {
+		owner := g.data.Users[g.rand.IntN(len(g.data.Users))]
+		startsAt, endsAt := g.auctionSchedule(i)
+		hasStarted := !startsAt.After(g.now)
+		hasEnded := !endsAt.After(g.now)
+
+		auction := types.Auction{
+			ID:          int64(i),
+			OwnerId:     owner.ID,
+			Name:        fmt.Sprintf("%s's %s sale", strings.Fields(owner.FullName)[0], strings.ToLower(g.pick(categoryNames))),
+			Description: "Everything is checked and photographed, shipping across Ukraine or pickup in Kyiv.",
+			IsActive:    true,
+			IsPrivate:   g.rand.IntN(10) == 0,
+			CreatedAt:   g.ago(startsAt, 7*24*time.Hour),
+			StartsAt:    sql.NullTime{Time: startsAt, Valid: true},
+			EndsAt:      sql.NullTime{Time: endsAt, Valid: true},
+			IsStarted:   hasStarted,
+			IsClosed:    hasEnded,
+		}
+		auction.UpdatedAt = auction.CreatedAt
+		if g.rand.IntN(2) == 0 {
+			auction.SoftCloseWindow = 5 * time.Minute
+		}
+		g.data.Auctions = append(g.data.Auctions, auction)
+
+		for j := 0; j < g.config.LotsPerAuction; j++ {
+			g.generateAuctionLot(&auction)
+		}
+	}
+}
+
+func (g *generator) generateAuctionLot(auction *types.Auction) {
+	category := g.data.Categories[g.rand.IntN(len(g.data.Categories))]
+	minimalBid := decimal.NewFromInt(int64(5 * (1 + g.rand.IntN(40))))
+
+	lot := types.AuctionLot{
+		ID:          int64(len(g.data.AuctionLots) + 1),
+		AuctionID:   auction.ID,
+		Name:        g.pick(lotAdjectives) + " " + g.pick(lotNouns[category.Name]),
+		Description: "See the photos for the details, ask the seller anything before bidding.",
+		CategoryId:  category.ID,
+		IsActive:    true,
+		MinimalBid:  minimalBid,
+		CreatedAt:   auction.CreatedAt,
+		UpdatedAt:   auction.CreatedAt,
+	}
+	if g.rand.IntN(3) == 0 {
+		lot.ReservePrice = minimalBid.Mul(decimal.NewFromInt(3))
+	}
+	if g.rand.IntN(4) == 0 {
+		lot.BinPrice = minimalBid.Mul(decimal.NewFromInt(10))
+	}
+
+	var highest *types.Bid
+	if auction.IsStarted {
+		highest = g.generateBids(auction, &lot)
+	}
+
+	if auction.IsClosed {
+		lot.IsClosed = true
+		lot.Outcome = lot.ClosingOutcome(highest)
+		lot.UpdatedAt = auction.EndsAt.Time
+		if lot.Outcome == types.AuctionLotOutcomeSold {
+			g.data.Winners = append(g.data.Winners, types.AuctionLotWinner{
+				AuctionLotID: lot.ID,
+				UserID:       highest.UserID,
+				BidID:        highest.ID,
+				Price:        highest.Value,
+				WonAt:        auction.EndsAt.Time,
+			})
+		}
+	}
+
+	g.data.AuctionLots = append(g.data.AuctionLots, lot)
+}
+
+// generateBids places rising bids of the users other than the owner and returns the highest one, which may be nil
+func (g *generator) generateBids(auction *types.Auction, lot *types.AuctionLot) *types.Bid {
+	if len(g.data.Users) < 2 || g.config.MaxBidsPerLot == 0 {
+		return nil
+	}
+
+	end := auction.EndsAt.Time
+	if end.After(g.now) {
+		end = g.now
+	}
+	span := end.Sub(auction.StartsAt.Time)
+	count := g.rand.IntN(g.config.MaxBidsPerLot + 1)
+
+	var highest *types.Bid
+	at := auction.StartsAt.Time
+	for i := 0; i < count; i++ {
+		bidder := g.data.Users[g.rand.IntN(len(g.data.Users))]
+		if bidder.ID == auction.OwnerId || (highest != nil && highest.UserID == bidder.ID) {
+			continue
+		}
+
+		value := auction.BidIncrements.NextMinimalBid(lot.MinimalBid, highest)
+		// some bidders raise more than the minimum
+		value = value.Add(decimal.NewFromInt(int64(g.rand.IntN(3) * 5)))
+		at = at.Add(time.Duration(g.rand.Int64N(int64(span/time.Duration(count+1)) + 1))).Truncate(time.Second)
+
+		bid := types.Bid{
+			ID:           int64(len(g.data.Bids) + 1),
+			AuctionLotID: lot.ID,
+			UserID:       bidder.ID,
+			Value:        value,
+			CreatedAt:    at,
+		}
+		g.data.Bids = append(g.data.Bids, bid)
+		highest = &bid
+	}
+
+	return highest
+}
+
+func (g *generator) generateFavourites() {
+	if len(g.data.AuctionLots) == 0 {
+		return
+	}
+
+	for _, user := range g.data.Users {
+		saved := make(map[int64]bool)
+		for i := 0; i < g.config.FavouritesPerUser && len(saved) < len(g.data.AuctionLots); i++ {
+			lot := g.data.AuctionLots[g.rand.IntN(len(g.data.AuctionLots))]
+			if saved[lot.ID] {
+				continue
+			}
+			saved[lot.ID] = true
+
+			g.data.SavedAuctionLots = append(g.data.SavedAuctionLots, types.SavedAuctionLot{
+				UserID:       user.ID,
+				AuctionLotID: lot.ID,
+				CreatedAt:    g.ago(g.now, 30*24*time.Hour),
+			})
+		}
+	}
+}
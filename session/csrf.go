@@ -0,0 +1,25 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/artemsmotritel/oktion/utils"
+)
+
+// CSRFToken derives the CSRF token of a session from its token, so it changes whenever the session does.
+// The session token itself never leaves the HttpOnly cookie, which keeps the derived token unguessable for other sites
+func CSRFToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// NewCSRFSecret returns a random secret to derive the CSRF token from for visitors that haven't logged in yet
+func NewCSRFSecret() (string, error) {
+	return utils.NewRandomToken()
+}
+
+func ValidCSRFToken(expected, actual string) bool {
+	return hmac.Equal([]byte(expected), []byte(actual))
+}
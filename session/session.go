@@ -0,0 +1,115 @@
+// Package session issues and resolves the opaque session tokens kept in the session cookie.
+// Only the SHA-256 hashes of the tokens reach the storage, so a leaked session table can't be used to log in.
+package session
+
+import (
+	"context"
+	"errors"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"time"
+)
+
+var ErrNoSession = errors.New("the session doesn't exist or has expired")
+
+type Manager struct {
+	store storage.SessionStore
+	// ttl is how long a session lives without requests, every request renews it
+	ttl time.Duration
+}
+
+func NewManager(store storage.SessionStore, ttl time.Duration) *Manager {
+	return &Manager{
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Create starts a new session of the user and returns its token.
+// The previous token of the browser, if there is one, is revoked so that a session is never reused across logins
+func (m *Manager) Create(ctx context.Context, userId int64, previousToken string) (string, error) {
+	now := time.Now()
+
+	if previousToken != "" {
+		if err := m.store.DeleteSession(ctx, utils.HashToken(previousToken)); err != nil {
+			return "", err
+		}
+	}
+
+	// logins are rare enough to clean up the abandoned sessions on the way
+	if err := m.store.DeleteExpiredSessions(ctx, now); err != nil {
+		return "", err
+	}
+
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = m.store.SaveSession(ctx, &types.Session{
+		ID:         utils.HashToken(token),
+		UserID:     userId,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(m.ttl),
+		LastSeenAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Resolve returns the session of the token, renewing it once half of its lifetime has passed.
+// The renewed result tells the caller to renew the cookie as well
+func (m *Manager) Resolve(ctx context.Context, token string) (session *types.Session, renewed bool, err error) {
+	if token == "" {
+		return nil, false, ErrNoSession
+	}
+
+	session, err = m.store.GetSession(ctx, utils.HashToken(token))
+	if err != nil {
+		return nil, false, err
+	}
+	if session == nil {
+		return nil, false, ErrNoSession
+	}
+
+	now := time.Now()
+	if session.IsExpired(now) {
+		if err = m.store.DeleteSession(ctx, session.ID); err != nil {
+			return nil, false, err
+		}
+		return nil, false, ErrNoSession
+	}
+
+	if session.ExpiresAt.Sub(now) < m.ttl/2 {
+		session.ExpiresAt = now.Add(m.ttl)
+		session.LastSeenAt = now
+		if err = m.store.RenewSession(ctx, session.ID, session.ExpiresAt, session.LastSeenAt); err != nil {
+			return nil, false, err
+		}
+		renewed = true
+	}
+
+	return session, renewed, nil
+}
+
+func (m *Manager) Revoke(ctx context.Context, token string) error {
+	return m.store.DeleteSession(ctx, utils.HashToken(token))
+}
+
+// RevokeOthers logs the user out on every device but the one with the token
+func (m *Manager) RevokeOthers(ctx context.Context, userId int64, token string) error {
+	return m.store.DeleteOtherUserSessions(ctx, userId, utils.HashToken(token))
+}
+
+// RevokeAll logs the user out on every device
+func (m *Manager) RevokeAll(ctx context.Context, userId int64) error {
+	return m.store.DeleteUserSessions(ctx, userId)
+}
@@ -1,4 +1,4 @@
-const codesToAllowSwap = [400, 401, 403, 404, 409, 500];
+const codesToAllowSwap = [400, 401, 403, 404, 409, 429, 500];
 
 document.body.addEventListener('htmx:beforeSwap', function(evt) {
     if (codesToAllowSwap.includes(evt.detail.xhr.status)) {
@@ -1,53 +1,135 @@
 package storage
 
 import (
+	"cmp"
+	"context"
+	"database/sql"
 	"fmt"
-	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/proxybid"
+	"github.com/artemsmotritel/oktion/seed"
 	"github.com/artemsmotritel/oktion/types"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// InMemoryStore keeps everything in memory, it is meant for the development and the tests
 type InMemoryStore struct {
-	users       []types.User
-	auctions    []types.Auction
-	categories  []types.Category
-	auctionLots []types.AuctionLot
-}
+	// mu guards the data that has no mutex of its own, along with the last handed out ids
+	mu sync.RWMutex
+	// sessionsMu guards the sessions, which are touched by every request
+	sessionsMu sync.Mutex
+	sessions   map[string]types.Session
+	// loginThrottlesMu guards the login throttles, which are touched by concurrent logins
+	loginThrottlesMu sync.Mutex
+	loginThrottles   map[string]types.LoginThrottle
+	users            []types.User
+	auctions         []types.Auction
+	categories       []types.Category
+	auctionLots      []types.AuctionLot
+	bids             []types.Bid
+	maxBids          []types.MaxBid
+	winners          []types.AuctionLotWinner
+	savedAuctionLots []types.SavedAuctionLot
+	audit            []types.AuditEntry
+
+	passwordResetTokens     []types.PasswordResetToken
+	emailVerificationTokens []types.EmailVerificationToken
+	twoFactors              map[int64]types.TwoFactor
+	recoveryCodes           []types.RecoveryCode
+	loginChallenges         map[string]types.LoginChallenge
+	externalIdentities      []types.ExternalIdentity
+	// apiTokensMu guards the API tokens, which are touched by every request with a token
+	apiTokensMu sync.Mutex
+	apiTokens   []types.APIToken
 
-var auctionId int64 = 0
-var auctionLotId int64 = 0
+	userId       int64
+	auctionId    int64
+	auctionLotId int64
+	bidId        int64
+	maxBidId     int64
+	auditEntryId int64
+	apiTokenId   int64
+}
 
 func NewInMemoryStore() *InMemoryStore {
-	return &InMemoryStore{}
+	return &InMemoryStore{
+		sessions:        make(map[string]types.Session),
+		twoFactors:      make(map[int64]types.TwoFactor),
+		loginChallenges: make(map[string]types.LoginChallenge),
+		loginThrottles:  make(map[string]types.LoginThrottle),
+	}
 }
 
-func (s *InMemoryStore) GetUserByID(id int64) (*types.User, error) {
+func (s *InMemoryStore) GetUserByID(ctx context.Context, id int64) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.userByID(id), nil
+}
+
+func (s *InMemoryStore) userByID(id int64) *types.User {
 	for i := 0; i < len(s.users); i++ {
 		if id == s.users[i].ID {
-			u := types.CopyUser(&s.users[i])
-			return u, nil
+			return types.CopyUser(&s.users[i])
 		}
 	}
 
-	return nil, nil
+	return nil
 }
 
-func (s *InMemoryStore) GetUsers() ([]types.User, error) {
-	res := make([]types.User, len(s.users))
+func (s *InMemoryStore) GetUsers(ctx context.Context, limit, offset int) ([]types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]types.User, len(s.users))
 
 	for i := 0; i < len(s.users); i++ {
-		res[i] = *types.CopyUser(&s.users[i])
+		users[i] = *types.CopyUser(&s.users[i])
 	}
 
-	return res, nil
+	slices.SortFunc(users, func(a, b types.User) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if offset >= len(users) {
+		return []types.User{}, nil
+	}
+
+	return users[offset:min(offset+limit, len(users))], nil
 }
 
-func (s *InMemoryStore) SaveUser(user *types.User) error {
-	s.users = append(s.users, *types.CopyUser(user))
-	return nil
+func (s *InMemoryStore) CountUsers(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.users)), nil
 }
 
-func (s *InMemoryStore) GetUserByEmail(email string) (*types.User, error) {
+func (s *InMemoryStore) SaveUser(ctx context.Context, user *types.User) (*types.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.users, func(u types.User) bool { return u.Email == user.Email }) {
+		return nil, fmt.Errorf("the email %s is already taken", user.Email)
+	}
+
+	s.userId++
+	saved := types.CopyUser(user)
+	saved.ID = s.userId
+	s.users = append(s.users, *saved)
+
+	saved = types.CopyUser(saved)
+	saved.Password = ""
+	return saved, nil
+}
+
+func (s *InMemoryStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	for _, user := range s.users {
 		if user.Email == email {
 			return types.CopyUser(&user), nil
@@ -57,10 +139,89 @@ func (s *InMemoryStore) GetUserByEmail(email string) (*types.User, error) {
 	return nil, nil
 }
 
-func (s *InMemoryStore) UpdateUser(id int64, request types.UserUpdateRequest) error {
+// UpdateUser DOES NOT update the user password or email
+func (s *InMemoryStore) UpdateUser(ctx context.Context, id int64, request types.UserUpdateRequest) (*types.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	for i := 0; i < len(s.users); i++ {
 		if id == s.users[i].ID {
 			s.users[i].FullName = request.FullName
+			s.users[i].Phone = request.Phone
+			return types.CopyUser(&s.users[i]), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no user with id=%d", id)
+}
+
+func (s *InMemoryStore) SetUserRoles(ctx context.Context, id int64, roles []types.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.users); i++ {
+		if id == s.users[i].ID {
+			s.users[i].Roles = slices.Clone(roles)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no user with id=%d", id)
+}
+
+func (s *InMemoryStore) SearchUsers(ctx context.Context, search string, limit int) ([]types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search = strings.ToLower(search)
+	users := make([]types.User, 0)
+
+	for i := 0; i < len(s.users) && len(users) < limit; i++ {
+		u := &s.users[i]
+		if strings.Contains(strings.ToLower(u.Email), search) || strings.Contains(strings.ToLower(u.FullName), search) || strconv.FormatInt(u.ID, 10) == search {
+			users = append(users, *types.CopyUser(u))
+		}
+	}
+
+	return users, nil
+}
+
+func (s *InMemoryStore) SetUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.users); i++ {
+		if s.users[i].ID == id {
+			s.users[i].Password = passwordHash
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no user with id=%d", id)
+}
+
+func (s *InMemoryStore) SetUserEmail(ctx context.Context, id int64, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.users); i++ {
+		if s.users[i].ID == id {
+			s.users[i].Email = email
+			s.users[i].EmailVerifiedAt = sql.NullTime{}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no user with id=%d", id)
+}
+
+func (s *InMemoryStore) SetUserSuspended(ctx context.Context, id int64, suspendedAt sql.NullTime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.users); i++ {
+		if id == s.users[i].ID {
+			s.users[i].SuspendedAt = suspendedAt
 			return nil
 		}
 	}
@@ -68,7 +229,10 @@ func (s *InMemoryStore) UpdateUser(id int64, request types.UserUpdateRequest) er
 	return fmt.Errorf("no user with id=%d", id)
 }
 
-func (s *InMemoryStore) DeleteUser(id int64) error {
+func (s *InMemoryStore) DeleteUser(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	idx := -1
 
 	for i := 0; i < len(s.users); i++ {
@@ -84,70 +248,56 @@ func (s *InMemoryStore) DeleteUser(id int64) error {
 	return nil
 }
 
-func (s *InMemoryStore) SeedData() error {
-	pass, _ := argon2id.CreateHash("1234", argon2id.DefaultParams)
-	s.users = []types.User{{
-		ID:       100,
-		FullName: "John",
-		Email:    "ready@ex.com",
-		Password: pass,
-	}, {
-		ID:       200,
-		FullName: "Jane",
-	}, {
-		ID:       30,
-		FullName: "Abobus",
-	},
-	}
-
-	s.auctions = []types.Auction{{
-		ID:          1,
-		OwnerId:     100,
-		Name:        "auction1",
-		Description: "lorem",
-		IsActive:    true,
-		IsPrivate:   false,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}, {
-		ID:          2,
-		OwnerId:     4,
-		Name:        "auction2",
-		Description: "lorem ipsum",
-		IsActive:    true,
-		IsPrivate:   true,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}}
-
-	s.auctionLots = []types.AuctionLot{{
-		ID:        1,
-		AuctionID: 1,
-		Name:      "First lot",
-	}, {
-		ID:        2,
-		AuctionID: 2,
-		Name:      "First lot",
-	}}
-
-	s.categories = []types.Category{{
-		ID:   1,
-		Name: "Sport",
-	},
-		{
-			ID:   11,
-			Name: "Clothes",
-		},
+func (s *InMemoryStore) SeedData(ctx context.Context, data *seed.Dataset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.users) > 0 {
+		return ErrSeedNotEmpty
+	}
+
+	s.users = make([]types.User, len(data.Users))
+	for i := range data.Users {
+		s.users[i] = *types.CopyUser(&data.Users[i])
+	}
+	s.categories = slices.Clone(data.Categories)
+	s.auctions = make([]types.Auction, len(data.Auctions))
+	for i := range data.Auctions {
+		s.auctions[i] = types.CopyAuction(&data.Auctions[i])
+	}
+	s.auctionLots = make([]types.AuctionLot, len(data.AuctionLots))
+	for i := range data.AuctionLots {
+		s.auctionLots[i] = *types.CopyAuctionLot(&data.AuctionLots[i])
+	}
+	s.bids = slices.Clone(data.Bids)
+	s.winners = slices.Clone(data.Winners)
+	s.savedAuctionLots = slices.Clone(data.SavedAuctionLots)
+
+	// the ids continue after the seeded ones
+	for _, user := range s.users {
+		s.userId = max(s.userId, user.ID)
+	}
+	for _, auction := range s.auctions {
+		s.auctionId = max(s.auctionId, auction.ID)
+	}
+	for _, lot := range s.auctionLots {
+		s.auctionLotId = max(s.auctionLotId, lot.ID)
+	}
+	for _, bid := range s.bids {
+		s.bidId = max(s.bidId, bid.ID)
 	}
 
 	return nil
 }
 
-func (s *InMemoryStore) GetAuctionsByOwnerId(ownerId int64) ([]types.Auction, error) {
+func (s *InMemoryStore) GetAuctionsByOwnerId(ctx context.Context, ownerId int64) ([]types.Auction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	res := make([]types.Auction, 0)
 
 	for i := 0; i < len(s.auctions); i++ {
-		if s.auctions[i].OwnerId == ownerId {
+		if s.auctions[i].OwnerId == ownerId && !s.auctions[i].DeletedAt.Valid {
 			res = append(res, types.CopyAuction(&s.auctions[i]))
 		}
 	}
@@ -155,63 +305,213 @@ func (s *InMemoryStore) GetAuctionsByOwnerId(ownerId int64) ([]types.Auction, er
 	return res, nil
 }
 
-func (s *InMemoryStore) GetOwnerIDByAuctionID(auctionId int64) (int64, error) {
+func (s *InMemoryStore) GetOwnerIDByAuctionID(ctx context.Context, auctionId int64) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	for i := 0; i < len(s.auctions); i++ {
-		if s.auctions[i].ID == auctionId {
+		if s.auctions[i].ID == auctionId && !s.auctions[i].DeletedAt.Valid {
 			return s.auctions[i].OwnerId, nil
 		}
 	}
 
-	return int64(0), fmt.Errorf("no auction with id=%d", auctionId)
+	return 0, nil
+}
+
+func (s *InMemoryStore) GetAuctionByID(ctx context.Context, id int64) (*types.Auction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if auction := s.auctionByID(id); auction != nil && !auction.DeletedAt.Valid {
+		return auction, nil
+	}
+
+	return nil, nil
 }
 
-func (s *InMemoryStore) GetAuctionByID(id int64) (*types.Auction, error) {
+func (s *InMemoryStore) auctionByID(id int64) *types.Auction {
 	for i := 0; i < len(s.auctions); i++ {
 		if s.auctions[i].ID == id {
 			auction := types.CopyAuction(&s.auctions[i])
-			return &auction, nil
+			return &auction
 		}
 	}
 
-	return nil, fmt.Errorf("no auction with id=%d", id)
+	return nil
 }
 
-func (s *InMemoryStore) GetAuctions() ([]types.Auction, error) {
-	res := make([]types.Auction, len(s.auctions))
+func (s *InMemoryStore) GetAuctions(ctx context.Context) ([]types.Auction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]types.Auction, 0, len(s.auctions))
 
 	for i := 0; i < len(s.auctions); i++ {
-		res[i] = types.CopyAuction(&s.auctions[i])
+		if !s.auctions[i].DeletedAt.Valid {
+			res = append(res, types.CopyAuction(&s.auctions[i]))
+		}
 	}
 
+	slices.SortFunc(res, func(a, b types.Auction) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
 	return res, nil
 }
 
-func (s *InMemoryStore) SaveAuction(auction *types.Auction) (*types.Auction, error) {
-	auction.ID = auctionId
-	s.auctions = append(s.auctions, types.CopyAuction(auction))
-	auctionId++
+func (s *InMemoryStore) SaveAuction(ctx context.Context, auction *types.Auction) (*types.Auction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return auction, nil
+	s.auctionId++
+	saved := types.CopyAuction(auction)
+	saved.ID = s.auctionId
+	saved.CreatedAt = time.Now()
+	saved.UpdatedAt = saved.CreatedAt
+	s.auctions = append(s.auctions, saved)
+
+	saved = types.CopyAuction(&saved)
+	return &saved, nil
 }
 
-func (s *InMemoryStore) DeleteAuction(id int64) error {
-	idx := -1
+func (s *InMemoryStore) DeleteAuction(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deletedAt := sql.NullTime{Time: time.Now(), Valid: true}
 
 	for i := 0; i < len(s.auctions); i++ {
-		if id == s.auctions[i].ID {
-			idx = i
+		if s.auctions[i].ID == id && !s.auctions[i].DeletedAt.Valid {
+			s.auctions[i].DeletedAt = deletedAt
 		}
 	}
 
-	if idx != -1 {
-		s.auctions[idx] = s.auctions[len(s.auctions)-1]
-		s.auctions = s.auctions[:len(s.auctions)-1]
+	for i := 0; i < len(s.auctionLots); i++ {
+		if s.auctionLots[i].AuctionID == id && !s.auctionLots[i].DeletedAt.Valid {
+			s.auctionLots[i].DeletedAt = deletedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) UpdateAuction(ctx context.Context, update types.AuctionUpdateRequest) (*types.Auction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		a := &s.auctions[i]
+		if a.ID != update.ID {
+			continue
+		}
+
+		a.Name = update.Name
+		a.Description = update.Description
+		a.IsPrivate = update.IsPrivate
+		a.StartsAt = update.StartsAt
+		a.EndsAt = update.EndsAt
+		a.UpdatedAt = time.Now()
+		// moving the start into the future leaves the auction waiting for the scheduler to open it again
+		if update.StartsAt.Valid && update.StartsAt.Time.After(a.UpdatedAt) {
+			a.IsStarted = false
+		}
+
+		auction := types.CopyAuction(a)
+		return &auction, nil
+	}
+
+	return nil, fmt.Errorf("no auction with id=%d", update.ID)
+}
+
+func (s *InMemoryStore) SetAuctionActiveStatus(ctx context.Context, auctionId int64, isActive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		if s.auctions[i].ID == auctionId {
+			if isActive && s.auctions[i].IsModerated() {
+				return ErrModerated
+			}
+			s.auctions[i].IsActive = isActive
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) SetAuctionModerated(ctx context.Context, auctionId int64, moderatedAt sql.NullTime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		if s.auctions[i].ID == auctionId {
+			s.auctions[i].ModeratedAt = moderatedAt
+			s.auctions[i].IsActive = !moderatedAt.Valid
+			s.auctions[i].UpdatedAt = time.Now()
+			return nil
+		}
 	}
 
 	return nil
 }
 
-func (s *InMemoryStore) GetCategories() ([]types.Category, error) {
+func (s *InMemoryStore) GetRecentAuctions(ctx context.Context, limit int) ([]types.Auction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	auctions := slices.DeleteFunc(slices.Clone(s.auctions), func(a types.Auction) bool {
+		return a.DeletedAt.Valid
+	})
+	slices.SortFunc(auctions, func(a, b types.Auction) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return auctions[:min(limit, len(auctions))], nil
+}
+
+func (s *InMemoryStore) GetRecentBids(ctx context.Context, limit int) ([]types.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bids := slices.Clone(s.bids)
+	slices.SortFunc(bids, func(a, b types.Bid) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+
+	return bids[:min(limit, len(bids))], nil
+}
+
+func (s *InMemoryStore) SaveAuditEntry(ctx context.Context, entry *types.AuditEntry) (*types.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditEntryId++
+	saved := types.CopyAuditEntry(entry)
+	saved.ID = s.auditEntryId
+	saved.CreatedAt = time.Now()
+	s.audit = append(s.audit, *saved)
+
+	return types.CopyAuditEntry(saved), nil
+}
+
+func (s *InMemoryStore) GetAuditEntries(ctx context.Context, limit int) ([]types.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]types.AuditEntry, 0, min(limit, len(s.audit)))
+
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, s.audit[i])
+	}
+
+	return entries, nil
+}
+
+func (s *InMemoryStore) GetCategories(ctx context.Context) ([]types.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	res := make([]types.Category, 0)
 
 	for _, c := range s.categories {
@@ -226,11 +526,14 @@ func (s *InMemoryStore) GetCategories() ([]types.Category, error) {
 	return res, nil
 }
 
-func (s *InMemoryStore) GetAuctionLotsByAuctionID(auctionId int64) ([]types.AuctionLot, error) {
+func (s *InMemoryStore) GetAuctionLotsByAuctionID(ctx context.Context, auctionId int64) ([]types.AuctionLot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	res := make([]types.AuctionLot, 0)
 
 	for _, lot := range s.auctionLots {
-		if lot.AuctionID == auctionId {
+		if lot.AuctionID == auctionId && !lot.DeletedAt.Valid {
 			res = append(res, *types.CopyAuctionLot(&lot))
 		}
 	}
@@ -238,21 +541,29 @@ func (s *InMemoryStore) GetAuctionLotsByAuctionID(auctionId int64) ([]types.Auct
 	return res, nil
 }
 
-func (s *InMemoryStore) SaveAuctionLot(auctionLot *types.AuctionLot) (*types.AuctionLot, error) {
-	auctionLotId++
+func (s *InMemoryStore) SaveAuctionLot(ctx context.Context, auctionLot *types.AuctionLot) (*types.AuctionLot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auctionLotId++
 	l := types.CopyAuctionLot(auctionLot)
-	l.ID = auctionLotId
+	l.ID = s.auctionLotId
+	l.CreatedAt = time.Now()
+	l.UpdatedAt = l.CreatedAt
 
 	s.auctionLots = append(s.auctionLots, *l)
 
-	return types.CopyAuctionLot(auctionLot), nil
+	return types.CopyAuctionLot(l), nil
 }
 
-func (s *InMemoryStore) GetAuctionLotCount(auctionId int64) (int, error) {
+func (s *InMemoryStore) GetAuctionLotCount(ctx context.Context, auctionId int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	count := 0
 
 	for _, lot := range s.auctionLots {
-		if lot.AuctionID == auctionId {
+		if lot.AuctionID == auctionId && !lot.DeletedAt.Valid {
 			count++
 		}
 	}
@@ -260,12 +571,1072 @@ func (s *InMemoryStore) GetAuctionLotCount(auctionId int64) (int, error) {
 	return count, nil
 }
 
-func (s *InMemoryStore) GetAuctionLotByID(auctionLotId int64) (*types.AuctionLot, error) {
+func (s *InMemoryStore) GetAuctionLotByID(ctx context.Context, auctionLotId int64) (*types.AuctionLot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if lot := s.auctionLotByID(auctionLotId); lot != nil && !lot.DeletedAt.Valid {
+		return lot, nil
+	}
+
+	return nil, nil
+}
+
+func (s *InMemoryStore) auctionLotByID(auctionLotId int64) *types.AuctionLot {
 	for _, lot := range s.auctionLots {
 		if lot.ID == auctionLotId {
-			return types.CopyAuctionLot(&lot), nil
+			return types.CopyAuctionLot(&lot)
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) UpdateAuctionLot(ctx context.Context, auctionLotId int64, request *types.AuctionLotUpdateRequest) (*types.AuctionLot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctionLots); i++ {
+		lot := &s.auctionLots[i]
+		if lot.ID != auctionLotId {
+			continue
+		}
+
+		endsAt := request.EndsAt
+		if lot.IsClosed || s.highestBid(lot.ID) != nil {
+			if lot.ChangesPrices(request) {
+				return nil, ErrLotPricesLocked
+			}
+			if auction := s.auctionByID(lot.AuctionID); auction != nil {
+				endsAt = lot.KeptEndsAt(auction, endsAt)
+			}
+		}
+
+		lot.Name = request.Name
+		lot.Description = request.Description
+		lot.CategoryId = request.CategoryId
+		lot.MinimalBid = request.MinimalBid
+		lot.ReservePrice = request.ReservePrice
+		lot.BinPrice = request.BinPrice
+		lot.StartsAt = request.StartsAt
+		lot.EndsAt = endsAt
+		lot.UpdatedAt = time.Now()
+
+		return types.CopyAuctionLot(lot), nil
+	}
+
+	return nil, fmt.Errorf("no auction lot with id=%d", auctionLotId)
+}
+
+func (s *InMemoryStore) SetAuctionLotActiveStatus(ctx context.Context, auctionLotId int64, isActive bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctionLots); i++ {
+		if s.auctionLots[i].ID == auctionLotId {
+			if isActive && s.auctionLots[i].IsModerated() {
+				return ErrModerated
+			}
+			s.auctionLots[i].IsActive = isActive
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) SetAuctionLotModerated(ctx context.Context, auctionLotId int64, moderatedAt sql.NullTime) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctionLots); i++ {
+		if s.auctionLots[i].ID == auctionLotId {
+			s.auctionLots[i].ModeratedAt = moderatedAt
+			s.auctionLots[i].IsActive = !moderatedAt.Valid
+			s.auctionLots[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// lotForBidding checks that the user may bid on the lot and collects its bidding state
+func (s *InMemoryStore) lotForBidding(auctionLotId, userId int64) (*proxybid.Lot, error) {
+	lot := s.auctionLotByID(auctionLotId)
+	if lot == nil {
+		return nil, fmt.Errorf("no auction lot with id=%d", auctionLotId)
+	}
+
+	auction := s.auctionByID(lot.AuctionID)
+	if auction == nil {
+		return nil, fmt.Errorf("no auction with id=%d", lot.AuctionID)
+	}
+
+	if auction.OwnerId == userId {
+		return nil, ErrOwnLotBid
+	}
+
+	if !lot.IsActive || lot.IsClosed || !auction.IsActive || auction.IsClosed || lot.DeletedAt.Valid || auction.DeletedAt.Valid || !lot.IsWithinSchedule(auction, time.Now()) {
+		return nil, ErrLotNotAcceptingBids
+	}
+
+	highest := s.highestBid(auctionLotId)
+	maxBids := make([]types.MaxBid, 0)
+	for _, maxBid := range s.maxBids {
+		if maxBid.AuctionLotID == auctionLotId {
+			maxBids = append(maxBids, maxBid)
+		}
+	}
+
+	return &proxybid.Lot{
+		MinimalBid: lot.MinimalBid,
+		Increments: auction.BidIncrements,
+		HighestBid: highest,
+		MaxBids:    maxBids,
+	}, nil
+}
+
+func (s *InMemoryStore) insertBid(bid *types.Bid) *types.Bid {
+	s.bidId++
+	b := types.CopyBid(bid)
+	b.ID = s.bidId
+	b.CreatedAt = time.Now()
+
+	s.bids = append(s.bids, *b)
+
+	return types.CopyBid(b)
+}
+
+func (s *InMemoryStore) placeProxyBids(lot *proxybid.Lot) []types.Bid {
+	placed := make([]types.Bid, 0)
+
+	for _, bid := range proxybid.Resolve(*lot, time.Now()) {
+		placed = append(placed, *s.insertBid(&bid))
+	}
+
+	return placed
+}
+
+// extendAuctionLotEnd applies the auction soft close window to the lot after a bid has been placed on it
+func (s *InMemoryStore) extendAuctionLotEnd(auctionLotId int64, bidAt time.Time) {
+	for i := 0; i < len(s.auctionLots); i++ {
+		if s.auctionLots[i].ID != auctionLotId {
+			continue
+		}
+
+		auction := s.auctionByID(s.auctionLots[i].AuctionID)
+		if auction == nil {
+			return
+		}
+
+		if endsAt, ok := s.auctionLots[i].SoftCloseEndsAt(auction, bidAt); ok {
+			s.auctionLots[i].EndsAt = sql.NullTime{Time: endsAt, Valid: true}
+		}
+		return
+	}
+}
+
+func (s *InMemoryStore) PlaceBid(ctx context.Context, bid *types.Bid) (*types.Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot, err := s.lotForBidding(bid.AuctionLotID, bid.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if bid.Value.LessThan(lot.Increments.NextMinimalBid(lot.MinimalBid, lot.HighestBid)) {
+		return nil, ErrBidTooLow
+	}
+
+	savedBid := s.insertBid(bid)
+	lot.HighestBid = savedBid
+	s.placeProxyBids(lot)
+	s.extendAuctionLotEnd(bid.AuctionLotID, savedBid.CreatedAt)
+
+	return savedBid, nil
+}
+
+func (s *InMemoryStore) PlaceMaxBid(ctx context.Context, maxBid *types.MaxBid) ([]types.Bid, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot, err := s.lotForBidding(maxBid.AuctionLotID, maxBid.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMaxBidHighEnough(lot, maxBid) {
+		return nil, ErrBidTooLow
+	}
+
+	saved := types.CopyMaxBid(maxBid)
+	saved.CreatedAt = time.Now()
+
+	idx := slices.IndexFunc(s.maxBids, func(m types.MaxBid) bool {
+		return m.AuctionLotID == maxBid.AuctionLotID && m.UserID == maxBid.UserID
+	})
+	if idx == -1 {
+		s.maxBidId++
+		saved.ID = s.maxBidId
+		s.maxBids = append(s.maxBids, *saved)
+	} else {
+		saved.ID = s.maxBids[idx].ID
+		s.maxBids[idx] = *saved
+	}
+
+	lot.MaxBids = replaceMaxBid(lot.MaxBids, saved)
+
+	placed := s.placeProxyBids(lot)
+	if len(placed) > 0 {
+		s.extendAuctionLotEnd(maxBid.AuctionLotID, placed[len(placed)-1].CreatedAt)
+	}
+
+	return placed, nil
+}
+
+func (s *InMemoryStore) GetMaxBid(ctx context.Context, auctionLotId, userId int64) (*types.MaxBid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, maxBid := range s.maxBids {
+		if maxBid.AuctionLotID == auctionLotId && maxBid.UserID == userId {
+			return types.CopyMaxBid(&maxBid), nil
 		}
 	}
 
 	return nil, nil
 }
+
+func (s *InMemoryStore) GetBidsByLotID(ctx context.Context, auctionLotId int64) ([]types.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]types.Bid, 0)
+
+	for _, bid := range s.bids {
+		if bid.AuctionLotID == auctionLotId {
+			res = append(res, *types.CopyBid(&bid))
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b types.Bid) int {
+		return b.Value.Compare(a.Value)
+	})
+
+	return res, nil
+}
+
+func (s *InMemoryStore) GetHighestBid(ctx context.Context, auctionLotId int64) (*types.Bid, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.highestBid(auctionLotId), nil
+}
+
+func (s *InMemoryStore) highestBid(auctionLotId int64) *types.Bid {
+	var highest *types.Bid
+
+	for i := 0; i < len(s.bids); i++ {
+		if s.bids[i].AuctionLotID == auctionLotId && (highest == nil || s.bids[i].Value.GreaterThan(highest.Value)) {
+			highest = &s.bids[i]
+		}
+	}
+
+	if highest == nil {
+		return nil
+	}
+
+	return types.CopyBid(highest)
+}
+
+func (s *InMemoryStore) SetAuctionBidIncrements(ctx context.Context, auctionId int64, increments types.BidIncrements) (types.BidIncrements, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		if s.auctions[i].ID == auctionId {
+			s.auctions[i].BidIncrements = types.CopyBidIncrements(increments)
+			s.auctions[i].BidIncrements.Sort()
+			return types.CopyBidIncrements(s.auctions[i].BidIncrements), nil
+		}
+	}
+
+	return nil, fmt.Errorf("no auction with id=%d", auctionId)
+}
+
+func (s *InMemoryStore) SetAuctionSoftCloseWindow(ctx context.Context, auctionId int64, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		if s.auctions[i].ID == auctionId {
+			s.auctions[i].SoftCloseWindow = window
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no auction with id=%d", auctionId)
+}
+
+func (s *InMemoryStore) SetAuctionManagerTwoFactor(ctx context.Context, auctionId int64, required bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.auctions); i++ {
+		if s.auctions[i].ID == auctionId {
+			s.auctions[i].RequireManagerTwoFactor = required
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no auction with id=%d", auctionId)
+}
+
+func (s *InMemoryStore) BuyNow(ctx context.Context, auctionLotId, userId int64) (*types.AuctionLotWinner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot, err := s.lotForBidding(auctionLotId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	auctionLot := s.auctionLotByID(auctionLotId)
+	if !auctionLot.IsBuyNowAvailable(lot.HighestBid) {
+		return nil, ErrBuyNowUnavailable
+	}
+
+	bid := s.insertBid(&types.Bid{
+		AuctionLotID: auctionLotId,
+		UserID:       userId,
+		Value:        auctionLot.BinPrice,
+	})
+
+	return s.closeAuctionLot(bid), nil
+}
+
+func (s *InMemoryStore) setAuctionLotOutcome(auctionLotId int64, outcome types.AuctionLotOutcome) {
+	for i := 0; i < len(s.auctionLots); i++ {
+		if s.auctionLots[i].ID == auctionLotId {
+			s.auctionLots[i].IsClosed = true
+			s.auctionLots[i].Outcome = outcome
+			s.auctionLots[i].UpdatedAt = time.Now()
+		}
+	}
+}
+
+func (s *InMemoryStore) closeAuctionLot(bid *types.Bid) *types.AuctionLotWinner {
+	s.setAuctionLotOutcome(bid.AuctionLotID, types.AuctionLotOutcomeSold)
+
+	winner := types.AuctionLotWinner{
+		AuctionLotID: bid.AuctionLotID,
+		UserID:       bid.UserID,
+		BidID:        bid.ID,
+		Price:        bid.Value,
+		WonAt:        time.Now(),
+	}
+	s.winners = append(s.winners, winner)
+
+	return types.CopyAuctionLotWinner(&winner)
+}
+
+func (s *InMemoryStore) GetAuctionLotWinner(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, winner := range s.winners {
+		if winner.AuctionLotID == auctionLotId {
+			return types.CopyAuctionLotWinner(&winner), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *InMemoryStore) CloseAuctionLot(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeAuctionLotByID(auctionLotId)
+}
+
+func (s *InMemoryStore) closeAuctionLotByID(auctionLotId int64) (*types.AuctionLotWinner, error) {
+	lot := s.auctionLotByID(auctionLotId)
+	if lot == nil {
+		return nil, fmt.Errorf("no auction lot with id=%d", auctionLotId)
+	}
+
+	if lot.IsClosed {
+		return nil, ErrUnexpectedLotOutcome
+	}
+
+	highest := s.highestBid(auctionLotId)
+	if outcome := lot.ClosingOutcome(highest); outcome != types.AuctionLotOutcomeSold {
+		s.setAuctionLotOutcome(auctionLotId, outcome)
+		return nil, nil
+	}
+
+	return s.closeAuctionLot(highest), nil
+}
+
+func (s *InMemoryStore) CloseEndedAuctionLot(ctx context.Context, auctionLotId int64, now time.Time) (*types.AuctionLotWinner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot := s.auctionLotByID(auctionLotId)
+	if lot == nil {
+		return nil, fmt.Errorf("no auction lot with id=%d", auctionLotId)
+	}
+
+	auction := s.auctionByID(lot.AuctionID)
+	if auction == nil {
+		return nil, fmt.Errorf("no auction with id=%d", lot.AuctionID)
+	}
+
+	if endsAt := lot.EffectiveEndsAt(auction); !lot.IsClosed && (!endsAt.Valid || endsAt.Time.After(now)) {
+		return nil, ErrAuctionLotNotEnded
+	}
+
+	return s.closeAuctionLotByID(auctionLotId)
+}
+
+func (s *InMemoryStore) OfferAuctionLotToHighestBidder(ctx context.Context, auctionLotId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot := s.auctionLotByID(auctionLotId)
+	if lot == nil {
+		return fmt.Errorf("no auction lot with id=%d", auctionLotId)
+	}
+
+	if lot.Outcome != types.AuctionLotOutcomeReserveNotMet {
+		return ErrUnexpectedLotOutcome
+	}
+
+	s.setAuctionLotOutcome(auctionLotId, types.AuctionLotOutcomeOffered)
+
+	return nil
+}
+
+func (s *InMemoryStore) RespondToAuctionLotOffer(ctx context.Context, auctionLotId, userId int64, accept bool) (*types.AuctionLotWinner, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lot := s.auctionLotByID(auctionLotId)
+	if lot == nil {
+		return nil, fmt.Errorf("no auction lot with id=%d", auctionLotId)
+	}
+
+	if lot.Outcome != types.AuctionLotOutcomeOffered {
+		return nil, ErrUnexpectedLotOutcome
+	}
+
+	highest := s.highestBid(auctionLotId)
+	if highest == nil || highest.UserID != userId {
+		return nil, ErrNotHighestBidder
+	}
+
+	if !accept {
+		s.setAuctionLotOutcome(auctionLotId, types.AuctionLotOutcomeOfferDeclined)
+		return nil, nil
+	}
+
+	return s.closeAuctionLot(highest), nil
+}
+
+func (s *InMemoryStore) OpenDueAuctions(ctx context.Context, now time.Time) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int64, 0)
+
+	for i := 0; i < len(s.auctions); i++ {
+		a := &s.auctions[i]
+		if a.IsActive && !a.IsStarted && !a.IsClosed && !a.DeletedAt.Valid && a.StartsAt.Valid && !a.StartsAt.Time.After(now) {
+			a.IsStarted = true
+			a.UpdatedAt = now
+			ids = append(ids, a.ID)
+		}
+	}
+
+	return ids, nil
+}
+
+// openLotEndTimes returns the effective end times of the open lots by their ids
+func (s *InMemoryStore) openLotEndTimes() map[int64]sql.NullTime {
+	res := make(map[int64]sql.NullTime)
+
+	for _, lot := range s.auctionLots {
+		if lot.IsClosed || lot.DeletedAt.Valid {
+			continue
+		}
+
+		auction := s.auctionByID(lot.AuctionID)
+		if auction == nil || auction.DeletedAt.Valid {
+			continue
+		}
+
+		res[lot.ID] = lot.EffectiveEndsAt(auction)
+	}
+
+	return res
+}
+
+func (s *InMemoryStore) GetDueAuctionLotIDs(ctx context.Context, now time.Time) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int64, 0)
+
+	for id, endsAt := range s.openLotEndTimes() {
+		if endsAt.Valid && !endsAt.Time.After(now) {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids, nil
+}
+
+func (s *InMemoryStore) CloseDueAuctions(ctx context.Context, now time.Time) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int64, 0)
+
+	for i := 0; i < len(s.auctions); i++ {
+		a := &s.auctions[i]
+		if a.IsClosed || a.DeletedAt.Valid || !a.EndsAt.Valid || a.EndsAt.Time.After(now) {
+			continue
+		}
+
+		hasOpenLots := slices.ContainsFunc(s.auctionLots, func(lot types.AuctionLot) bool {
+			return lot.AuctionID == a.ID && !lot.IsClosed && !lot.DeletedAt.Valid
+		})
+		if hasOpenLots {
+			continue
+		}
+
+		a.IsClosed = true
+		a.UpdatedAt = now
+		ids = append(ids, a.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *InMemoryStore) GetNextScheduledTransition(ctx context.Context, now time.Time) (sql.NullTime, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var next sql.NullTime
+
+	consider := func(t sql.NullTime) {
+		if t.Valid && t.Time.After(now) && (!next.Valid || t.Time.Before(next.Time)) {
+			next = t
+		}
+	}
+
+	for _, a := range s.auctions {
+		if a.IsClosed || a.DeletedAt.Valid {
+			continue
+		}
+		if !a.IsStarted {
+			consider(a.StartsAt)
+		}
+		consider(a.EndsAt)
+	}
+
+	for _, endsAt := range s.openLotEndTimes() {
+		consider(endsAt)
+	}
+
+	return next, nil
+}
+
+func (s *InMemoryStore) SaveSession(ctx context.Context, session *types.Session) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	s.sessions[session.ID] = *types.CopySession(session)
+
+	return nil
+}
+
+func (s *InMemoryStore) GetSession(ctx context.Context, id string) (*types.Session, error) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return types.CopySession(&session), nil
+}
+
+func (s *InMemoryStore) RenewSession(ctx context.Context, id string, expiresAt, lastSeenAt time.Time) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.ExpiresAt = expiresAt
+		session.LastSeenAt = lastSeenAt
+		s.sessions[id] = session
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteSession(ctx context.Context, id string) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	delete(s.sessions, id)
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteUserSessions(ctx context.Context, userId int64) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userId {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteOtherUserSessions(ctx context.Context, userId int64, keepId string) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userId && id != keepId {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.IsExpired(now) {
+			delete(s.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) SavePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.passwordResetTokens = append(s.passwordResetTokens, *types.CopyPasswordResetToken(token))
+	return nil
+}
+
+func (s *InMemoryStore) GetPasswordResetToken(ctx context.Context, id string) (*types.PasswordResetToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := range s.passwordResetTokens {
+		if s.passwordResetTokens[i].ID == id {
+			return types.CopyPasswordResetToken(&s.passwordResetTokens[i]), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *InMemoryStore) ResetPassword(ctx context.Context, tokenId string, passwordHash string, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.passwordResetTokens, func(t types.PasswordResetToken) bool {
+		return t.ID == tokenId
+	})
+	if idx == -1 || !s.passwordResetTokens[idx].IsUsable(now) {
+		return 0, ErrInvalidPasswordResetToken
+	}
+
+	userId := s.passwordResetTokens[idx].UserID
+	s.passwordResetTokens[idx].UsedAt = sql.NullTime{Time: now, Valid: true}
+
+	for i := range s.users {
+		if s.users[i].ID == userId {
+			s.users[i].Password = passwordHash
+		}
+	}
+
+	s.passwordResetTokens = slices.DeleteFunc(s.passwordResetTokens, func(t types.PasswordResetToken) bool {
+		return t.UserID == userId && !t.UsedAt.Valid
+	})
+
+	return userId, nil
+}
+
+func (s *InMemoryStore) SaveEmailVerificationToken(ctx context.Context, token *types.EmailVerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emailVerificationTokens = append(s.emailVerificationTokens, *types.CopyEmailVerificationToken(token))
+	return nil
+}
+
+func (s *InMemoryStore) CountEmailVerificationTokensSince(ctx context.Context, userId int64, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+
+	for _, token := range s.emailVerificationTokens {
+		if token.UserID == userId && token.CreatedAt.After(since) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *InMemoryStore) VerifyEmail(ctx context.Context, tokenId string, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := slices.IndexFunc(s.emailVerificationTokens, func(t types.EmailVerificationToken) bool {
+		return t.ID == tokenId
+	})
+	if idx == -1 || !now.Before(s.emailVerificationTokens[idx].ExpiresAt) {
+		return 0, ErrInvalidEmailVerificationToken
+	}
+	token := s.emailVerificationTokens[idx]
+
+	userIdx := slices.IndexFunc(s.users, func(u types.User) bool {
+		return u.ID == token.UserID && u.Email == token.Email
+	})
+	if userIdx == -1 {
+		return 0, ErrInvalidEmailVerificationToken
+	}
+	s.users[userIdx].EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
+
+	s.emailVerificationTokens = slices.DeleteFunc(s.emailVerificationTokens, func(t types.EmailVerificationToken) bool {
+		return t.UserID == token.UserID
+	})
+
+	return token.UserID, nil
+}
+
+func (s *InMemoryStore) GetTwoFactor(ctx context.Context, userId int64) (*types.TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	twoFactor, ok := s.twoFactors[userId]
+	if !ok {
+		return nil, nil
+	}
+
+	return types.CopyTwoFactor(&twoFactor), nil
+}
+
+func (s *InMemoryStore) SaveTwoFactorSecret(ctx context.Context, userId int64, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if twoFactor, ok := s.twoFactors[userId]; ok && twoFactor.IsEnabled() {
+		return nil
+	}
+
+	s.twoFactors[userId] = types.TwoFactor{
+		UserID: userId,
+		Secret: secret,
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) EnableTwoFactor(ctx context.Context, userId int64, step int64, now time.Time, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, ok := s.twoFactors[userId]
+	if !ok || twoFactor.IsEnabled() || twoFactor.LastUsedStep >= step {
+		return ErrTwoFactorCodeReused
+	}
+
+	twoFactor.EnabledAt = sql.NullTime{Time: now, Valid: true}
+	twoFactor.LastUsedStep = step
+	s.twoFactors[userId] = twoFactor
+
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(c types.RecoveryCode) bool {
+		return c.UserID == userId
+	})
+	for _, hash := range recoveryCodeHashes {
+		s.recoveryCodes = append(s.recoveryCodes, types.RecoveryCode{UserID: userId, CodeHash: hash})
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) DisableTwoFactor(ctx context.Context, userId int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.twoFactors, userId)
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(c types.RecoveryCode) bool {
+		return c.UserID == userId
+	})
+
+	return nil
+}
+
+func (s *InMemoryStore) UseTwoFactorStep(ctx context.Context, userId int64, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	twoFactor, ok := s.twoFactors[userId]
+	if !ok || twoFactor.LastUsedStep >= step {
+		return ErrTwoFactorCodeReused
+	}
+
+	twoFactor.LastUsedStep = step
+	s.twoFactors[userId] = twoFactor
+
+	return nil
+}
+
+func (s *InMemoryStore) UseRecoveryCode(ctx context.Context, userId int64, codeHash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.recoveryCodes {
+		c := &s.recoveryCodes[i]
+		if c.UserID == userId && c.CodeHash == codeHash && !c.UsedAt.Valid {
+			c.UsedAt = sql.NullTime{Time: now, Valid: true}
+			return nil
+		}
+	}
+
+	return ErrInvalidRecoveryCode
+}
+
+func (s *InMemoryStore) CountRecoveryCodes(ctx context.Context, userId int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+
+	for _, c := range s.recoveryCodes {
+		if c.UserID == userId && !c.UsedAt.Valid {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (s *InMemoryStore) SaveLoginChallenge(ctx context.Context, challenge *types.LoginChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, c := range s.loginChallenges {
+		if c.IsExpired(now) {
+			delete(s.loginChallenges, id)
+		}
+	}
+
+	s.loginChallenges[challenge.ID] = *types.CopyLoginChallenge(challenge)
+
+	return nil
+}
+
+func (s *InMemoryStore) GetLoginChallenge(ctx context.Context, id string) (*types.LoginChallenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	challenge, ok := s.loginChallenges[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return types.CopyLoginChallenge(&challenge), nil
+}
+
+func (s *InMemoryStore) RecordLoginChallengeAttempt(ctx context.Context, id string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.loginChallenges[id]
+	if !ok {
+		return 0, fmt.Errorf("no login challenge with id=%s", id)
+	}
+
+	challenge.Attempts++
+	s.loginChallenges[id] = challenge
+
+	return challenge.Attempts, nil
+}
+
+func (s *InMemoryStore) DeleteLoginChallenge(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginChallenges, id)
+	return nil
+}
+
+func (s *InMemoryStore) GetLoginThrottle(ctx context.Context, key string) (*types.LoginThrottle, error) {
+	s.loginThrottlesMu.Lock()
+	defer s.loginThrottlesMu.Unlock()
+
+	throttle, ok := s.loginThrottles[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return types.CopyLoginThrottle(&throttle), nil
+}
+
+func (s *InMemoryStore) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*types.LoginThrottle, error) {
+	s.loginThrottlesMu.Lock()
+	defer s.loginThrottlesMu.Unlock()
+
+	throttle, ok := s.loginThrottles[key]
+	if !ok || !throttle.LastFailureAt.Add(resetAfter).After(now) {
+		throttle = types.LoginThrottle{Key: key}
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	s.loginThrottles[key] = throttle
+
+	return types.CopyLoginThrottle(&throttle), nil
+}
+
+func (s *InMemoryStore) DeleteLoginThrottle(ctx context.Context, key string) error {
+	s.loginThrottlesMu.Lock()
+	defer s.loginThrottlesMu.Unlock()
+
+	delete(s.loginThrottles, key)
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error {
+	s.loginThrottlesMu.Lock()
+	defer s.loginThrottlesMu.Unlock()
+
+	for key, throttle := range s.loginThrottles {
+		if throttle.LastFailureAt.Before(before) {
+			delete(s.loginThrottles, key)
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) GetUserByExternalIdentity(ctx context.Context, issuer, subject string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, identity := range s.externalIdentities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return s.userByID(identity.UserID), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *InMemoryStore) SaveExternalIdentity(ctx context.Context, identity *types.ExternalIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.externalIdentities {
+		if existing.Issuer == identity.Issuer && existing.Subject == identity.Subject {
+			return fmt.Errorf("the identity %s of %s is already linked", identity.Subject, identity.Issuer)
+		}
+	}
+
+	s.externalIdentities = append(s.externalIdentities, *identity)
+
+	return nil
+}
+
+func (s *InMemoryStore) SaveAPIToken(ctx context.Context, token *types.APIToken) (*types.APIToken, error) {
+	s.apiTokensMu.Lock()
+	defer s.apiTokensMu.Unlock()
+
+	s.apiTokenId++
+	saved := types.CopyAPIToken(token)
+	saved.ID = s.apiTokenId
+	s.apiTokens = append(s.apiTokens, *saved)
+
+	return types.CopyAPIToken(saved), nil
+}
+
+func (s *InMemoryStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (*types.APIToken, error) {
+	s.apiTokensMu.Lock()
+	defer s.apiTokensMu.Unlock()
+
+	for i := range s.apiTokens {
+		if s.apiTokens[i].TokenHash == tokenHash {
+			return types.CopyAPIToken(&s.apiTokens[i]), nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *InMemoryStore) GetAPITokensByUserID(ctx context.Context, userId int64) ([]types.APIToken, error) {
+	s.apiTokensMu.Lock()
+	defer s.apiTokensMu.Unlock()
+
+	tokens := make([]types.APIToken, 0)
+	for i := len(s.apiTokens) - 1; i >= 0; i-- {
+		if s.apiTokens[i].UserID == userId {
+			tokens = append(tokens, *types.CopyAPIToken(&s.apiTokens[i]))
+		}
+	}
+
+	return tokens, nil
+}
+
+func (s *InMemoryStore) SetAPITokenLastUsed(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	s.apiTokensMu.Lock()
+	defer s.apiTokensMu.Unlock()
+
+	for i := range s.apiTokens {
+		if s.apiTokens[i].ID == id {
+			s.apiTokens[i].LastUsedAt = sql.NullTime{Time: lastUsedAt, Valid: true}
+		}
+	}
+
+	return nil
+}
+
+func (s *InMemoryStore) DeleteAPIToken(ctx context.Context, userId, id int64) error {
+	s.apiTokensMu.Lock()
+	defer s.apiTokensMu.Unlock()
+
+	s.apiTokens = slices.DeleteFunc(s.apiTokens, func(t types.APIToken) bool {
+		return t.ID == id && t.UserID == userId
+	})
+
+	return nil
+}
+
+func (s *InMemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
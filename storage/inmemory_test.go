@@ -0,0 +1,13 @@
+package storage_test
+
+import (
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/storagetest"
+	"testing"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return storage.NewInMemoryStore()
+	})
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"github.com/artemsmotritel/oktion/types"
+	"time"
+)
+
+// LoginThrottleStore keeps the failed login counters, see throttle.LoginLimiter
+type LoginThrottleStore interface {
+	// GetLoginThrottle returns nil if there are no failures recorded for the key
+	GetLoginThrottle(ctx context.Context, key string) (*types.LoginThrottle, error)
+	// RecordLoginFailure counts a failure of the key. The count starts over
+	// when the previous failure is older than resetAfter
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*types.LoginThrottle, error)
+	DeleteLoginThrottle(ctx context.Context, key string) error
+	// DeleteStaleLoginThrottles forgets the keys that haven't failed since before
+	DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error
+}
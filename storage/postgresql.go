@@ -4,105 +4,277 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"github.com/artemsmotritel/oktion/proxybid"
+	"github.com/artemsmotritel/oktion/seed"
 	"github.com/artemsmotritel/oktion/types"
+	pgxdecimal "github.com/jackc/pgx-shopspring-decimal"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"log"
 	"time"
 )
 
 type PostgresqlStore struct {
-	connection *pgx.Conn
-	logger     *log.Logger
+	pool   *pgxpool.Pool
+	logger *log.Logger
 }
 
-func NewPostgresqlStore(conn *pgx.Conn, logger *log.Logger) *PostgresqlStore {
+func NewPostgresqlStore(pool *pgxpool.Pool, logger *log.Logger) *PostgresqlStore {
 	return &PostgresqlStore{
-		connection: conn,
-		logger:     logger,
+		pool:   pool,
+		logger: logger,
 	}
 }
 
-func (p *PostgresqlStore) logError(err error, tag string) {
-	p.logger.Printf("An error occurred when executing a query to the postgres db\nTAG: %s\nERROR: %+v\n", tag, err)
+// PoolConfig sizes the connection pool, a zero field keeps the pgxpool default
+type PoolConfig struct {
+	MaxConns int32
+	MinConns int32
+	// MaxConnLifetime closes the connections older than it, so that they are spread over the database replicas again
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	// HealthCheckPeriod is how often the idle connections are checked and the broken ones replaced
+	HealthCheckPeriod time.Duration
 }
 
-func (p *PostgresqlStore) GetUserByID(id int64) (*types.User, error) {
-	var user types.User
+// NewPostgresqlPool connects to the database and checks the connection before returning the pool
+func NewPostgresqlPool(ctx context.Context, dbURL string, poolConfig PoolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if poolConfig.MaxConns > 0 {
+		config.MaxConns = poolConfig.MaxConns
+	}
+	if poolConfig.MinConns > 0 {
+		config.MinConns = poolConfig.MinConns
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = poolConfig.MaxConnLifetime
+	}
+	if poolConfig.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = poolConfig.MaxConnIdleTime
+	}
+	if poolConfig.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = poolConfig.HealthCheckPeriod
+	}
+
+	// every connection of the pool has to know the numeric columns are scanned into decimal.Decimal
+	config.AfterConnect = func(_ context.Context, conn *pgx.Conn) error {
+		pgxdecimal.Register(conn.TypeMap())
+		return nil
+	}
 
-	query := "SELECT id, email, phone, fullname, password FROM users where id = $1"
-	err := p.connection.QueryRow(context.Background(), query, id).Scan(&user.ID, &user.Email, &user.Phone, &user.FullName, &user.Password)
+	pool, err := pgxpool.NewWithConfig(ctx, config)
 	if err != nil {
-		// TODO: think of a normal way to log an error
-		p.logError(err, "get user by id")
 		return nil, err
 	}
 
-	return &user, nil
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// Ping checks that a connection to the database can be acquired and used
+func (p *PostgresqlStore) Ping(ctx context.Context) error {
+	return p.pool.Ping(ctx)
+}
+
+// querier is implemented by pgxpool.Pool, pgx.Conn and pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func (p *PostgresqlStore) logError(err error, tag string) {
+	p.logger.Printf("An error occurred when executing a query to the postgres db\nTAG: %s\nERROR: %+v\n", tag, err)
+}
+
+// roles are kept in a text[] column, pgx scans it into plain strings
+func rolesFromStrings(values []string) []types.Role {
+	roles := make([]types.Role, len(values))
+	for i, value := range values {
+		roles[i] = types.Role(value)
+	}
+	return roles
+}
+
+func rolesToStrings(roles []types.Role) []string {
+	values := make([]string, len(roles))
+	for i, role := range roles {
+		values[i] = string(role)
+	}
+	return values
 }
 
-func (p *PostgresqlStore) GetUsers() ([]types.User, error) {
-	rows, err := p.connection.Query(context.Background(), "SELECT * FROM users")
+// userColumns are the columns scanUser expects, in its order
+const userColumns = "id, email, phone, fullname, password, roles, suspended_at, email_verified_at"
+
+func scanUser(row pgx.Row) (*types.User, error) {
+	var (
+		user  types.User
+		roles []string
+	)
+
+	err := row.Scan(&user.ID, &user.Email, &user.Phone, &user.FullName, &user.Password, &roles, &user.SuspendedAt, &user.EmailVerifiedAt)
 	if err != nil {
-		p.logError(err, "get users")
 		return nil, err
 	}
+	user.Roles = rolesFromStrings(roles)
+
+	return &user, nil
+}
+
+func (p *PostgresqlStore) collectUsers(rows pgx.Rows, tag string) ([]types.User, error) {
 	defer rows.Close()
 
-	var users []types.User
+	users := make([]types.User, 0)
 
 	for rows.Next() {
-		var user types.User
-		err := rows.Scan(&user.ID, &user.Email, &user.FullName, &user.Phone)
+		user, err := scanUser(rows)
 		if err != nil {
-			p.logError(err, "get users; rows")
+			p.logError(err, tag+"; rows")
 			return nil, err
 		}
-		users = append(users, user)
+		user.Password = ""
+		users = append(users, *user)
 	}
-	if err = rows.Err(); err != nil {
-		p.logError(err, "get users; after rows")
+	if err := rows.Err(); err != nil {
+		p.logError(err, tag+"; after rows")
 		return nil, err
 	}
 
 	return users, nil
 }
 
-func (p *PostgresqlStore) SaveUser(user *types.User) (*types.User, error) {
-	query := "INSERT INTO users (email, fullname, phone, password) VALUES ($1, $2, $3, $4) RETURNING id, email, fullname, phone"
-	args := []any{user.Email, user.FullName, user.Phone, user.Password}
+func (p *PostgresqlStore) GetUserByID(ctx context.Context, id int64) (*types.User, error) {
+	query := "SELECT " + userColumns + " FROM users where id = $1"
+	user, err := scanUser(p.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		// TODO: think of a normal way to log an error
+		p.logError(err, "get user by id")
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (p *PostgresqlStore) GetUsers(ctx context.Context, limit, offset int) ([]types.User, error) {
+	query := "SELECT " + userColumns + " FROM users ORDER BY id LIMIT $1 OFFSET $2"
+	rows, err := p.pool.Query(ctx, query, limit, offset)
+	if err != nil {
+		p.logError(err, "get users")
+		return nil, err
+	}
+
+	return p.collectUsers(rows, "get users")
+}
+
+func (p *PostgresqlStore) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+
+	err := p.pool.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&count)
+	if err != nil {
+		p.logError(err, "count users")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p *PostgresqlStore) SearchUsers(ctx context.Context, search string, limit int) ([]types.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE email ILIKE '%' || $1 || '%' OR fullname ILIKE '%' || $1 || '%' OR id::text = $1 ORDER BY id LIMIT $2"
+	rows, err := p.pool.Query(ctx, query, search, limit)
+	if err != nil {
+		p.logError(err, "search users")
+		return nil, err
+	}
+
+	return p.collectUsers(rows, "search users")
+}
+
+func (p *PostgresqlStore) SaveUser(ctx context.Context, user *types.User) (*types.User, error) {
+	query := "INSERT INTO users (email, fullname, phone, password, roles, email_verified_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + userColumns
+	args := []any{user.Email, user.FullName, user.Phone, user.Password, rolesToStrings(user.Roles), user.EmailVerifiedAt}
 
-	var savedUser types.User
-	returningArgs := []any{&savedUser.ID, &savedUser.Email, &savedUser.FullName, &savedUser.Phone}
-	err := p.connection.QueryRow(context.Background(), query, args...).Scan(returningArgs...)
+	savedUser, err := scanUser(p.pool.QueryRow(ctx, query, args...))
 	if err != nil {
 		p.logError(err, "save user")
 		return nil, err
 	}
+	savedUser.Password = ""
 
-	return &savedUser, nil
+	return savedUser, nil
 }
 
 // UpdateUser DOES NOT update the user password or email
-func (p *PostgresqlStore) UpdateUser(id int64, request types.UserUpdateRequest) (*types.User, error) {
+func (p *PostgresqlStore) UpdateUser(ctx context.Context, id int64, request types.UserUpdateRequest) (*types.User, error) {
 	// intentionally skip email update for now
-	query := "UPDATE users SET fullname = $1, phone = $2 WHERE id = $3 RETURNING fullname, email, phone, password"
+	query := "UPDATE users SET fullname = $1, phone = $2 WHERE id = $3 RETURNING " + userColumns
 	args := []any{request.FullName, request.Phone, id}
 
-	var user types.User
-	user.ID = id
-
-	err := p.connection.QueryRow(context.Background(), query, args...).Scan(&user.FullName, &user.Email, &user.Phone, &user.Password)
+	user, err := scanUser(p.pool.QueryRow(ctx, query, args...))
 	if err != nil {
 		p.logError(err, "update user")
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
+}
+
+func (p *PostgresqlStore) SetUserRoles(ctx context.Context, id int64, roles []types.Role) error {
+	query := "UPDATE users SET roles = $1 WHERE id = $2"
+	if _, err := p.pool.Exec(ctx, query, rolesToStrings(roles), id); err != nil {
+		p.logError(err, "set user roles")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	query := "UPDATE users SET password = $1 WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, passwordHash, id); err != nil {
+		p.logError(err, "set user password")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetUserEmail(ctx context.Context, id int64, email string) error {
+	query := "UPDATE users SET email = $1, email_verified_at = NULL WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, email, id); err != nil {
+		p.logError(err, "set user email")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetUserSuspended(ctx context.Context, id int64, suspendedAt sql.NullTime) error {
+	query := "UPDATE users SET suspended_at = $1 WHERE id = $2"
+	if _, err := p.pool.Exec(ctx, query, suspendedAt, id); err != nil {
+		p.logError(err, "set user suspended")
+		return err
+	}
+
+	return nil
 }
 
-func (p *PostgresqlStore) DeleteUser(id int64) error {
+func (p *PostgresqlStore) DeleteUser(ctx context.Context, id int64) error {
 	query := "DELETE FROM users WHERE id = $1"
-	_, err := p.connection.Exec(context.Background(), query, id)
+	_, err := p.pool.Exec(ctx, query, id)
 	if err != nil {
 		p.logError(err, "delete user")
 		return err
@@ -111,11 +283,9 @@ func (p *PostgresqlStore) DeleteUser(id int64) error {
 	return nil
 }
 
-func (p *PostgresqlStore) GetUserByEmail(email string) (*types.User, error) {
-	var user types.User
-
-	query := "SELECT id, email, phone, fullname, password FROM users where email = $1"
-	err := p.connection.QueryRow(context.Background(), query, email).Scan(&user.ID, &user.Email, &user.Phone, &user.FullName, &user.Password)
+func (p *PostgresqlStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	query := "SELECT " + userColumns + " FROM users where email = $1"
+	user, err := scanUser(p.pool.QueryRow(ctx, query, email))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -124,13 +294,13 @@ func (p *PostgresqlStore) GetUserByEmail(email string) (*types.User, error) {
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (p *PostgresqlStore) GetAuctionsByOwnerId(ownerId int64) ([]types.Auction, error) {
-	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id FROM auction WHERE owner_id = $1"
+func (p *PostgresqlStore) GetAuctionsByOwnerId(ctx context.Context, ownerId int64) ([]types.Auction, error) {
+	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id, starts_at, ends_at, is_started, is_closed, soft_close_seconds, require_manager_two_factor, moderated_at FROM auction WHERE owner_id = $1 AND deleted_at IS NULL"
 
-	rows, err := p.connection.Query(context.Background(), query, ownerId)
+	rows, err := p.pool.Query(ctx, query, ownerId)
 	if err != nil {
 		p.logError(err, "get auctions by owner id")
 		return nil, err
@@ -139,12 +309,16 @@ func (p *PostgresqlStore) GetAuctionsByOwnerId(ownerId int64) ([]types.Auction,
 	auctions := make([]types.Auction, 0)
 
 	for rows.Next() {
-		var auction types.Auction
-		err := rows.Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId)
+		var (
+			auction          types.Auction
+			softCloseSeconds int64
+		)
+		err := rows.Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId, &auction.StartsAt, &auction.EndsAt, &auction.IsStarted, &auction.IsClosed, &softCloseSeconds, &auction.RequireManagerTwoFactor, &auction.ModeratedAt)
 		if err != nil {
 			p.logError(err, "get auctions by owner id; rows")
 			return nil, err
 		}
+		auction.SoftCloseWindow = time.Duration(softCloseSeconds) * time.Second
 
 		auctions = append(auctions, auction)
 	}
@@ -157,11 +331,48 @@ func (p *PostgresqlStore) GetAuctionsByOwnerId(ownerId int64) ([]types.Auction,
 	return auctions, nil
 }
 
-func (p *PostgresqlStore) GetOwnerIDByAuctionID(auctionId int64) (int64, error) {
-	query := "SELECT owner_id FROM auction WHERE id = $1"
+func (p *PostgresqlStore) GetRecentAuctions(ctx context.Context, limit int) ([]types.Auction, error) {
+	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id, starts_at, ends_at, is_started, is_closed, soft_close_seconds, require_manager_two_factor, moderated_at FROM auction WHERE deleted_at IS NULL ORDER BY created_at DESC, id DESC LIMIT $1"
+
+	rows, err := p.pool.Query(ctx, query, limit)
+	if err != nil {
+		p.logError(err, "get recent auctions")
+		return nil, err
+	}
+	defer rows.Close()
+	auctions := make([]types.Auction, 0)
+
+	for rows.Next() {
+		var (
+			auction          types.Auction
+			softCloseSeconds int64
+		)
+		err := rows.Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId, &auction.StartsAt, &auction.EndsAt, &auction.IsStarted, &auction.IsClosed, &softCloseSeconds, &auction.RequireManagerTwoFactor, &auction.ModeratedAt)
+		if err != nil {
+			p.logError(err, "get recent auctions; rows")
+			return nil, err
+		}
+		auction.SoftCloseWindow = time.Duration(softCloseSeconds) * time.Second
+
+		auctions = append(auctions, auction)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get recent auctions; after rows")
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+func (p *PostgresqlStore) GetOwnerIDByAuctionID(ctx context.Context, auctionId int64) (int64, error) {
+	query := "SELECT owner_id FROM auction WHERE id = $1 AND deleted_at IS NULL"
 	var ownerId int64
-	err := p.connection.QueryRow(context.Background(), query, auctionId).Scan(&ownerId)
+	err := p.pool.QueryRow(ctx, query, auctionId).Scan(&ownerId)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
 		p.logError(err, "get auction owner id by auction id")
 		return 0, err
 	}
@@ -169,32 +380,104 @@ func (p *PostgresqlStore) GetOwnerIDByAuctionID(auctionId int64) (int64, error)
 	return ownerId, nil
 }
 
-func (p *PostgresqlStore) GetAuctionByID(id int64) (*types.Auction, error) {
-	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id FROM auction WHERE id = $1"
-	var auction types.Auction
+func (p *PostgresqlStore) GetAuctionByID(ctx context.Context, id int64) (*types.Auction, error) {
+	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id, starts_at, ends_at, is_started, is_closed, soft_close_seconds, require_manager_two_factor, moderated_at FROM auction WHERE id = $1 AND deleted_at IS NULL"
+	var (
+		auction          types.Auction
+		softCloseSeconds int64
+	)
 
-	err := p.connection.QueryRow(context.Background(), query, id).Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId)
+	err := p.pool.QueryRow(ctx, query, id).Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId, &auction.StartsAt, &auction.EndsAt, &auction.IsStarted, &auction.IsClosed, &softCloseSeconds, &auction.RequireManagerTwoFactor, &auction.ModeratedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
 		p.logError(err, "get auction by id")
 		return nil, err
 	}
+	auction.SoftCloseWindow = time.Duration(softCloseSeconds) * time.Second
+
+	if auction.BidIncrements, err = p.getBidIncrements(ctx, p.pool, id); err != nil {
+		return nil, err
+	}
 
 	return &auction, nil
 }
 
-func (p *PostgresqlStore) GetAuctions() ([]types.Auction, error) {
-	return make([]types.Auction, 0), nil
+func (p *PostgresqlStore) getBidIncrements(ctx context.Context, q querier, auctionId int64) (types.BidIncrements, error) {
+	query := "SELECT price_from, amount FROM auction_bid_increment WHERE auction_id = $1 ORDER BY price_from"
+
+	rows, err := q.Query(ctx, query, auctionId)
+	if err != nil {
+		p.logError(err, "get bid increments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	increments := make(types.BidIncrements, 0)
+
+	for rows.Next() {
+		var increment types.BidIncrement
+
+		if err = rows.Scan(&increment.From, &increment.Amount); err != nil {
+			p.logError(err, "get bid increments; rows")
+			return nil, err
+		}
+
+		increments = append(increments, increment)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get bid increments; after rows")
+		return nil, err
+	}
+
+	return increments, nil
+}
+
+func (p *PostgresqlStore) GetAuctions(ctx context.Context) ([]types.Auction, error) {
+	query := "SELECT id, name, description, is_active, is_private, created_at, updated_at, deleted_at, owner_id, starts_at, ends_at, is_started, is_closed, soft_close_seconds, require_manager_two_factor, moderated_at FROM auction WHERE deleted_at IS NULL ORDER BY id"
+
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		p.logError(err, "get auctions")
+		return nil, err
+	}
+	defer rows.Close()
+	auctions := make([]types.Auction, 0)
+
+	for rows.Next() {
+		var (
+			auction          types.Auction
+			softCloseSeconds int64
+		)
+		err := rows.Scan(&auction.ID, &auction.Name, &auction.Description, &auction.IsActive, &auction.IsPrivate, &auction.CreatedAt, &auction.UpdatedAt, &auction.DeletedAt, &auction.OwnerId, &auction.StartsAt, &auction.EndsAt, &auction.IsStarted, &auction.IsClosed, &softCloseSeconds, &auction.RequireManagerTwoFactor, &auction.ModeratedAt)
+		if err != nil {
+			p.logError(err, "get auctions; rows")
+			return nil, err
+		}
+		auction.SoftCloseWindow = time.Duration(softCloseSeconds) * time.Second
+
+		auctions = append(auctions, auction)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get auctions; after rows")
+		return nil, err
+	}
+
+	return auctions, nil
 }
 
-func (p *PostgresqlStore) SaveAuction(auction *types.Auction) (*types.Auction, error) {
-	query := "INSERT INTO auction (name, description, is_active, is_private, owner_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at"
-	args := []any{auction.Name, auction.Description, auction.IsActive, auction.IsPrivate, auction.OwnerId}
+func (p *PostgresqlStore) SaveAuction(ctx context.Context, auction *types.Auction) (*types.Auction, error) {
+	query := "INSERT INTO auction (name, description, is_active, is_private, owner_id, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at"
+	args := []any{auction.Name, auction.Description, auction.IsActive, auction.IsPrivate, auction.OwnerId, auction.StartsAt, auction.EndsAt}
 	var (
 		id        int64
 		createdAt time.Time
 	)
 
-	err := p.connection.QueryRow(context.Background(), query, args...).Scan(&id, &createdAt)
+	err := p.pool.QueryRow(ctx, query, args...).Scan(&id, &createdAt)
 	if err != nil {
 		p.logError(err, "save auction")
 		return nil, err
@@ -210,23 +493,40 @@ func (p *PostgresqlStore) SaveAuction(auction *types.Auction) (*types.Auction, e
 		CreatedAt:   createdAt,
 		UpdatedAt:   createdAt,
 		DeletedAt:   sql.NullTime{},
+		StartsAt:    auction.StartsAt,
+		EndsAt:      auction.EndsAt,
 	}, nil
 }
 
-func (p *PostgresqlStore) DeleteAuction(id int64) error {
-	query := "DELETE FROM auction WHERE id = $1"
-	_, err := p.connection.Exec(context.Background(), query, id)
+func (p *PostgresqlStore) DeleteAuction(ctx context.Context, id int64) error {
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
+		p.logError(err, "delete auction; begin")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "UPDATE auction_lot SET deleted_at = now() WHERE auction_id = $1 AND deleted_at IS NULL", id); err != nil {
+		p.logError(err, "delete auction; lots")
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, "UPDATE auction SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id); err != nil {
 		p.logError(err, "delete auction")
 		return err
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "delete auction; commit")
+		return err
+	}
+
 	return nil
 }
 
-func (p *PostgresqlStore) GetAuctionLotsByAuctionID(auctionId int64) ([]types.AuctionLot, error) {
-	query := "SELECT id, name, description, is_active, minimal_bid, reserve_price, bin_price, created_at, updated_at, deleted_at, auction_id, COALESCE((SELECT category_id FROM auction_lot_categories WHERE auction_lot_id = $1), 0) FROM auction_lot WHERE auction_id = $1"
-	rows, err := p.connection.Query(context.Background(), query, auctionId)
+func (p *PostgresqlStore) GetAuctionLotsByAuctionID(ctx context.Context, auctionId int64) ([]types.AuctionLot, error) {
+	query := "SELECT id, name, description, is_active, is_closed, outcome, minimal_bid, reserve_price, bin_price, starts_at, ends_at, created_at, updated_at, deleted_at, moderated_at, auction_id, COALESCE((SELECT category_id FROM auction_lot_categories WHERE auction_lot_id = auction_lot.id), 0) FROM auction_lot WHERE auction_id = $1 AND deleted_at IS NULL"
+	rows, err := p.pool.Query(ctx, query, auctionId)
 	if err != nil {
 		p.logError(err, "get auction lots by auction id")
 		return nil, err
@@ -237,7 +537,7 @@ func (p *PostgresqlStore) GetAuctionLotsByAuctionID(auctionId int64) ([]types.Au
 	for rows.Next() {
 		var lot types.AuctionLot
 
-		if err := rows.Scan(&lot.ID, &lot.Name, &lot.Description, &lot.IsActive, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.CreatedAt, &lot.UpdatedAt, &lot.DeletedAt, &lot.AuctionID, &lot.CategoryId); err != nil {
+		if err := rows.Scan(&lot.ID, &lot.Name, &lot.Description, &lot.IsActive, &lot.IsClosed, &lot.Outcome, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.StartsAt, &lot.EndsAt, &lot.CreatedAt, &lot.UpdatedAt, &lot.DeletedAt, &lot.ModeratedAt, &lot.AuctionID, &lot.CategoryId); err != nil {
 			p.logError(err, "get auction lots by auction id; rows")
 			return nil, err
 		}
@@ -253,7 +553,7 @@ func (p *PostgresqlStore) GetAuctionLotsByAuctionID(auctionId int64) ([]types.Au
 	return lots, err
 }
 
-func (p *PostgresqlStore) SaveAuctionLot(auctionLot *types.AuctionLot) (*types.AuctionLot, error) {
+func (p *PostgresqlStore) SaveAuctionLot(ctx context.Context, auctionLot *types.AuctionLot) (*types.AuctionLot, error) {
 	query := "INSERT INTO auction_lot (NAME, DESCRIPTION, IS_ACTIVE, MINIMAL_BID, RESERVE_PRICE, BIN_PRICE, AUCTION_ID) VALUES (@name, @description, @is_active, @minimal_bid, @reserve_price, @bin_price, @auction_id) RETURNING id, created_at"
 	args := pgx.NamedArgs{
 		"name":          auctionLot.Name,
@@ -270,7 +570,7 @@ func (p *PostgresqlStore) SaveAuctionLot(auctionLot *types.AuctionLot) (*types.A
 		createdAt time.Time
 	)
 
-	err := p.connection.QueryRow(context.Background(), query, args).Scan(&id, &createdAt)
+	err := p.pool.QueryRow(ctx, query, args).Scan(&id, &createdAt)
 	if err != nil {
 		p.logError(err, "save auction lot")
 		return nil, err
@@ -290,10 +590,10 @@ func (p *PostgresqlStore) SaveAuctionLot(auctionLot *types.AuctionLot) (*types.A
 	}, nil
 }
 
-func (p *PostgresqlStore) GetAuctionLotCount(auctionId int64) (int, error) {
-	query := "SELECT COUNT(id) FROM auction_lot WHERE auction_id = $1"
+func (p *PostgresqlStore) GetAuctionLotCount(ctx context.Context, auctionId int64) (int, error) {
+	query := "SELECT COUNT(id) FROM auction_lot WHERE auction_id = $1 AND deleted_at IS NULL"
 	var count int
-	err := p.connection.QueryRow(context.Background(), query, auctionId).Scan(&count)
+	err := p.pool.QueryRow(ctx, query, auctionId).Scan(&count)
 	if err != nil {
 		p.logError(err, "get auction lot count")
 		return 0, err
@@ -302,16 +602,19 @@ func (p *PostgresqlStore) GetAuctionLotCount(auctionId int64) (int, error) {
 	return count, nil
 }
 
-func (p *PostgresqlStore) GetAuctionLotByID(auctionLotId int64) (*types.AuctionLot, error) {
-	query := "SELECT name, description, is_active, minimal_bid, reserve_price, bin_price, created_at, updated_at, deleted_at, auction_id, COALESCE((SELECT category_id FROM auction_lot_categories WHERE auction_lot_id = $1), 0) FROM auction_lot WHERE id = $1"
+func (p *PostgresqlStore) GetAuctionLotByID(ctx context.Context, auctionLotId int64) (*types.AuctionLot, error) {
+	query := "SELECT name, description, is_active, is_closed, outcome, minimal_bid, reserve_price, bin_price, starts_at, ends_at, created_at, updated_at, deleted_at, moderated_at, auction_id, COALESCE((SELECT category_id FROM auction_lot_categories WHERE auction_lot_id = $1), 0) FROM auction_lot WHERE id = $1 AND deleted_at IS NULL"
 
 	var lot types.AuctionLot
 	lot.ID = auctionLotId
 
-	returningArgs := []any{&lot.Name, &lot.Description, &lot.IsActive, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.CreatedAt, &lot.UpdatedAt, &lot.DeletedAt, &lot.AuctionID, &lot.CategoryId}
+	returningArgs := []any{&lot.Name, &lot.Description, &lot.IsActive, &lot.IsClosed, &lot.Outcome, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.StartsAt, &lot.EndsAt, &lot.CreatedAt, &lot.UpdatedAt, &lot.DeletedAt, &lot.ModeratedAt, &lot.AuctionID, &lot.CategoryId}
 
-	err := p.connection.QueryRow(context.Background(), query, auctionLotId).Scan(returningArgs...)
+	err := p.pool.QueryRow(ctx, query, auctionLotId).Scan(returningArgs...)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
 		p.logError(err, "get auction lot by id")
 		return nil, err
 	}
@@ -319,10 +622,58 @@ func (p *PostgresqlStore) GetAuctionLotByID(auctionLotId int64) (*types.AuctionL
 	return &lot, nil
 }
 
-func (p *PostgresqlStore) GetCategories() ([]types.Category, error) {
+func (p *PostgresqlStore) SaveAuditEntry(ctx context.Context, entry *types.AuditEntry) (*types.AuditEntry, error) {
+	query := "INSERT INTO audit_entry (actor_id, action, target_id, reason) VALUES ($1, $2, $3, $4) RETURNING id, created_at"
+
+	saved := types.CopyAuditEntry(entry)
+	err := p.pool.QueryRow(ctx, query, entry.ActorID, string(entry.Action), entry.TargetID, entry.Reason).Scan(&saved.ID, &saved.CreatedAt)
+	if err != nil {
+		p.logError(err, "save audit entry")
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func (p *PostgresqlStore) GetAuditEntries(ctx context.Context, limit int) ([]types.AuditEntry, error) {
+	query := "SELECT id, actor_id, action, target_id, reason, created_at FROM audit_entry ORDER BY created_at DESC, id DESC LIMIT $1"
+
+	rows, err := p.pool.Query(ctx, query, limit)
+	if err != nil {
+		p.logError(err, "get audit entries")
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]types.AuditEntry, 0)
+
+	for rows.Next() {
+		var (
+			entry  types.AuditEntry
+			action string
+		)
+
+		if err = rows.Scan(&entry.ID, &entry.ActorID, &action, &entry.TargetID, &entry.Reason, &entry.CreatedAt); err != nil {
+			p.logError(err, "get audit entries; rows")
+			return nil, err
+		}
+		entry.Action = types.AuditAction(action)
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get audit entries; after rows")
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (p *PostgresqlStore) GetCategories(ctx context.Context) ([]types.Category, error) {
 	query := "SELECT id, name FROM category"
 
-	rows, err := p.connection.Query(context.Background(), query)
+	rows, err := p.pool.Query(ctx, query)
 	if err != nil {
 		p.logError(err, "get categories")
 		return nil, err
@@ -350,56 +701,286 @@ func (p *PostgresqlStore) GetCategories() ([]types.Category, error) {
 	return categories, nil
 }
 
-func (p *PostgresqlStore) SeedData() error {
+func (p *PostgresqlStore) SeedData(ctx context.Context, data *seed.Dataset) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "seed data; begin")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var hasUsers bool
+	if err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users)").Scan(&hasUsers); err != nil {
+		p.logError(err, "seed data; check users")
+		return err
+	}
+	if hasUsers {
+		return ErrSeedNotEmpty
+	}
+
+	tables := []struct {
+		name    string
+		columns []string
+		rows    [][]any
+	}{{
+		name:    "users",
+		columns: []string{"id", "email", "phone", "fullname", "password", "roles", "suspended_at", "email_verified_at"},
+		rows: seedRows(data.Users, func(user types.User) []any {
+			return []any{user.ID, user.Email, user.Phone, user.FullName, user.Password, rolesToStrings(user.Roles), user.SuspendedAt, user.EmailVerifiedAt}
+		}),
+	}, {
+		name:    "category",
+		columns: []string{"id", "name"},
+		rows: seedRows(data.Categories, func(category types.Category) []any {
+			return []any{category.ID, category.Name}
+		}),
+	}, {
+		name: "auction",
+		columns: []string{"id", "owner_id", "name", "description", "is_active", "is_private", "created_at", "updated_at",
+			"starts_at", "ends_at", "is_started", "is_closed", "soft_close_seconds", "require_manager_two_factor"},
+		rows: seedRows(data.Auctions, func(auction types.Auction) []any {
+			return []any{auction.ID, auction.OwnerId, auction.Name, auction.Description, auction.IsActive, auction.IsPrivate, auction.CreatedAt, auction.UpdatedAt,
+				auction.StartsAt, auction.EndsAt, auction.IsStarted, auction.IsClosed, int64(auction.SoftCloseWindow / time.Second), auction.RequireManagerTwoFactor}
+		}),
+	}, {
+		name: "auction_lot",
+		columns: []string{"id", "auction_id", "name", "description", "is_active", "is_closed", "outcome", "minimal_bid", "reserve_price", "bin_price",
+			"starts_at", "ends_at", "created_at", "updated_at"},
+		rows: seedRows(data.AuctionLots, func(lot types.AuctionLot) []any {
+			return []any{lot.ID, lot.AuctionID, lot.Name, lot.Description, lot.IsActive, lot.IsClosed, string(lot.Outcome), lot.MinimalBid, lot.ReservePrice, lot.BinPrice,
+				lot.StartsAt, lot.EndsAt, lot.CreatedAt, lot.UpdatedAt}
+		}),
+	}, {
+		name:    "auction_lot_categories",
+		columns: []string{"auction_lot_id", "category_id"},
+		rows: seedRows(data.AuctionLots, func(lot types.AuctionLot) []any {
+			return []any{lot.ID, lot.CategoryId}
+		}),
+	}, {
+		name:    "bid",
+		columns: []string{"id", "auction_lot_id", "user_id", "value", "created_at"},
+		rows: seedRows(data.Bids, func(bid types.Bid) []any {
+			return []any{bid.ID, bid.AuctionLotID, bid.UserID, bid.Value, bid.CreatedAt}
+		}),
+	}, {
+		name:    "auction_lot_winner",
+		columns: []string{"auction_lot_id", "user_id", "bid_id", "won_at"},
+		rows: seedRows(data.Winners, func(winner types.AuctionLotWinner) []any {
+			return []any{winner.AuctionLotID, winner.UserID, winner.BidID, winner.WonAt}
+		}),
+	}, {
+		name:    "saved_auction_lots",
+		columns: []string{"user_id", "auction_lot_id", "created_at"},
+		rows: seedRows(data.SavedAuctionLots, func(saved types.SavedAuctionLot) []any {
+			return []any{saved.UserID, saved.AuctionLotID, saved.CreatedAt}
+		}),
+	}}
+
+	for _, table := range tables {
+		if _, err = tx.CopyFrom(ctx, pgx.Identifier{table.name}, table.columns, pgx.CopyFromRows(table.rows)); err != nil {
+			p.logError(err, "seed data; copy "+table.name)
+			return err
+		}
+	}
+
+	// the ids were set explicitly, so the sequences have to catch up for the next inserts
+	for _, table := range []string{"users", "category", "auction", "auction_lot", "bid"} {
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s", table)
+		if _, err = tx.Exec(ctx, query); err != nil {
+			p.logError(err, "seed data; set sequence of "+table)
+			return err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "seed data; commit")
+		return err
+	}
+
 	return nil
 }
 
-func (p *PostgresqlStore) UpdateAuction(update types.AuctionUpdateRequest) (*types.Auction, error) {
-	query := "UPDATE auction SET name = @name, description = @description, is_private = @is_private, updated_at = @updated_at WHERE id = @id RETURNING name, description, is_private, is_active, updated_at, created_at, deleted_at, owner_id"
+func seedRows[T any](values []T, row func(T) []any) [][]any {
+	rows := make([][]any, len(values))
+	for i, value := range values {
+		rows[i] = row(value)
+	}
+	return rows
+}
+
+func (p *PostgresqlStore) UpdateAuction(ctx context.Context, update types.AuctionUpdateRequest) (*types.Auction, error) {
+	// moving the start into the future leaves the auction waiting for the scheduler to open it again
+	query := "UPDATE auction SET name = @name, description = @description, is_private = @is_private, updated_at = @updated_at, starts_at = @starts_at, ends_at = @ends_at, " +
+		"is_started = CASE WHEN @starts_at::timestamptz > @updated_at THEN false ELSE is_started END " +
+		"WHERE id = @id RETURNING name, description, is_private, is_active, updated_at, created_at, deleted_at, owner_id, starts_at, ends_at, is_started, is_closed, soft_close_seconds, require_manager_two_factor, moderated_at"
 	args := pgx.NamedArgs{
 		"name":        update.Name,
 		"description": update.Description,
 		"is_private":  update.IsPrivate,
 		"updated_at":  time.Now(),
+		"starts_at":   update.StartsAt,
+		"ends_at":     update.EndsAt,
 		"id":          update.ID,
 	}
 
-	var auction types.Auction
+	var (
+		auction          types.Auction
+		softCloseSeconds int64
+	)
 	auction.ID = update.ID
 
-	returningArgs := []any{&auction.Name, &auction.Description, &auction.IsPrivate, &auction.IsActive, &auction.UpdatedAt, &auction.CreatedAt, &auction.DeletedAt, &auction.OwnerId}
+	returningArgs := []any{&auction.Name, &auction.Description, &auction.IsPrivate, &auction.IsActive, &auction.UpdatedAt, &auction.CreatedAt, &auction.DeletedAt, &auction.OwnerId, &auction.StartsAt, &auction.EndsAt, &auction.IsStarted, &auction.IsClosed, &softCloseSeconds, &auction.RequireManagerTwoFactor, &auction.ModeratedAt}
 
-	if err := p.connection.QueryRow(context.Background(), query, args).Scan(returningArgs...); err != nil {
+	if err := p.pool.QueryRow(ctx, query, args).Scan(returningArgs...); err != nil {
 		p.logError(err, "update auction")
 		return nil, err
 	}
+	auction.SoftCloseWindow = time.Duration(softCloseSeconds) * time.Second
+
+	increments, err := p.getBidIncrements(ctx, p.pool, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	auction.BidIncrements = increments
 
 	return &auction, nil
 }
 
-func (p *PostgresqlStore) SetAuctionActiveStatus(id int64, isActive bool) error {
-	query := "UPDATE auction SET is_active = $1 WHERE id = $2"
-	if _, err := p.connection.Exec(context.Background(), query, isActive, id); err != nil {
+func (p *PostgresqlStore) SetAuctionActiveStatus(ctx context.Context, id int64, isActive bool) error {
+	query := "UPDATE auction SET is_active = $1 WHERE id = $2 AND (NOT $1 OR moderated_at IS NULL)"
+	tag, err := p.pool.Exec(ctx, query, isActive, id)
+	if err != nil {
 		p.logError(err, "set auction active status")
 		return err
 	}
 
+	if isActive && tag.RowsAffected() == 0 {
+		var isModerated bool
+		err = p.pool.QueryRow(ctx, "SELECT moderated_at IS NOT NULL FROM auction WHERE id = $1", id).Scan(&isModerated)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			p.logError(err, "set auction active status; moderation")
+			return err
+		}
+
+		if isModerated {
+			return ErrModerated
+		}
+	}
+
 	return nil
 }
 
-func (p *PostgresqlStore) UpdateAuctionLot(auctionLotId int64, request *types.AuctionLotUpdateRequest) (*types.AuctionLot, error) {
-	updateLotCategorySubQuery := "WITH category_subquery AS (INSERT INTO auction_lot_categories (auction_lot_id, category_id) VALUES (@id, @category_id) ON CONFLICT (auction_lot_id) DO UPDATE SET category_id = @category_id RETURNING category_id), "
-	updateLotQuery := "lot_subquery AS (UPDATE auction_lot SET name = @name, description = @description, minimal_bid = @minimal_bid, reserve_price = @reserve_price, bin_price = @bin_price, updated_at = @updated_at WHERE id = @id RETURNING name, description, is_active, minimal_bid, reserve_price, bin_price, updated_at, created_at) "
-	selectQuery := "SELECT * FROM category_subquery, lot_subquery"
+func (p *PostgresqlStore) SetAuctionModerated(ctx context.Context, id int64, moderatedAt sql.NullTime) error {
+	query := "UPDATE auction SET moderated_at = $1, is_active = $1 IS NULL, updated_at = now() WHERE id = $2"
+	if _, err := p.pool.Exec(ctx, query, moderatedAt, id); err != nil {
+		p.logError(err, "set auction moderated")
+		return err
+	}
 
-	query := updateLotCategorySubQuery + updateLotQuery + selectQuery
-	args := pgx.NamedArgs{
-		"id":            auctionLotId,
-		"name":          request.Name,
+	return nil
+}
+
+func (p *PostgresqlStore) SetAuctionSoftCloseWindow(ctx context.Context, auctionId int64, window time.Duration) error {
+	query := "UPDATE auction SET soft_close_seconds = $1, updated_at = now() WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, int64(window/time.Second), auctionId); err != nil {
+		p.logError(err, "set auction soft close window")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetAuctionManagerTwoFactor(ctx context.Context, auctionId int64, required bool) error {
+	query := "UPDATE auction SET require_manager_two_factor = $1, updated_at = now() WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, required, auctionId); err != nil {
+		p.logError(err, "set auction manager two factor")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetAuctionBidIncrements(ctx context.Context, auctionId int64, increments types.BidIncrements) (types.BidIncrements, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "set auction bid increments; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "DELETE FROM auction_bid_increment WHERE auction_id = $1", auctionId); err != nil {
+		p.logError(err, "set auction bid increments; delete")
+		return nil, err
+	}
+
+	insertQuery := "INSERT INTO auction_bid_increment (auction_id, price_from, amount) VALUES ($1, $2, $3)"
+	for _, increment := range increments {
+		if _, err = tx.Exec(ctx, insertQuery, auctionId, increment.From, increment.Amount); err != nil {
+			p.logError(err, "set auction bid increments; insert")
+			return nil, err
+		}
+	}
+
+	saved, err := p.getBidIncrements(ctx, tx, auctionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "set auction bid increments; commit")
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func (p *PostgresqlStore) UpdateAuctionLot(ctx context.Context, auctionLotId int64, request *types.AuctionLotUpdateRequest) (*types.AuctionLot, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "update auction lot; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the lock keeps the bids and the soft close extensions from slipping in between the check and the update
+	lockQuery := "SELECT l.is_closed OR EXISTS (SELECT 1 FROM bid WHERE auction_lot_id = l.id), l.minimal_bid, l.reserve_price, l.bin_price, l.ends_at, a.ends_at " +
+		"FROM auction_lot l INNER JOIN auction a ON a.id = l.auction_id WHERE l.id = $1 FOR UPDATE OF l"
+	var (
+		hasBidsOrClosed bool
+		current         types.AuctionLot
+		auction         types.Auction
+	)
+
+	if err = tx.QueryRow(ctx, lockQuery, auctionLotId).Scan(&hasBidsOrClosed, &current.MinimalBid, &current.ReservePrice, &current.BinPrice, &current.EndsAt, &auction.EndsAt); err != nil {
+		p.logError(err, "update auction lot; lock")
+		return nil, err
+	}
+
+	endsAt := request.EndsAt
+	if hasBidsOrClosed {
+		if current.ChangesPrices(request) {
+			return nil, ErrLotPricesLocked
+		}
+		endsAt = current.KeptEndsAt(&auction, endsAt)
+	}
+
+	updateLotCategorySubQuery := "WITH category_subquery AS (INSERT INTO auction_lot_categories (auction_lot_id, category_id) VALUES (@id, @category_id) ON CONFLICT (auction_lot_id) DO UPDATE SET category_id = @category_id RETURNING category_id), "
+	updateLotQuery := "lot_subquery AS (UPDATE auction_lot SET name = @name, description = @description, minimal_bid = @minimal_bid, reserve_price = @reserve_price, bin_price = @bin_price, starts_at = @starts_at, ends_at = @ends_at, updated_at = @updated_at WHERE id = @id RETURNING name, description, is_active, is_closed, outcome, minimal_bid, reserve_price, bin_price, starts_at, ends_at, updated_at, created_at, moderated_at) "
+	selectQuery := "SELECT * FROM category_subquery, lot_subquery"
+
+	query := updateLotCategorySubQuery + updateLotQuery + selectQuery
+	args := pgx.NamedArgs{
+		"id":            auctionLotId,
+		"name":          request.Name,
 		"description":   request.Description,
 		"minimal_bid":   request.MinimalBid,
 		"reserve_price": request.ReservePrice,
 		"bin_price":     request.BinPrice,
+		"starts_at":     request.StartsAt,
+		"ends_at":       endsAt,
 		"updated_at":    time.Now(),
 		"category_id":   request.CategoryId,
 	}
@@ -407,23 +988,1245 @@ func (p *PostgresqlStore) UpdateAuctionLot(auctionLotId int64, request *types.Au
 	var lot types.AuctionLot
 	lot.ID = auctionLotId
 
-	returningArgs := []any{&lot.CategoryId, &lot.Name, &lot.Description, &lot.IsActive, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.UpdatedAt, &lot.CreatedAt}
+	returningArgs := []any{&lot.CategoryId, &lot.Name, &lot.Description, &lot.IsActive, &lot.IsClosed, &lot.Outcome, &lot.MinimalBid, &lot.ReservePrice, &lot.BinPrice, &lot.StartsAt, &lot.EndsAt, &lot.UpdatedAt, &lot.CreatedAt, &lot.ModeratedAt}
 
-	if err := p.connection.QueryRow(context.Background(), query, args).Scan(returningArgs...); err != nil {
+	if err = tx.QueryRow(ctx, query, args).Scan(returningArgs...); err != nil {
 		p.logError(err, "update auction lot")
 		return nil, err
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "update auction lot; commit")
+		return nil, err
+	}
+
 	return &lot, nil
 }
 
-func (p *PostgresqlStore) SetAuctionLotActiveStatus(auctionLotId int64, isActive bool) error {
-	query := "UPDATE auction_lot SET is_active = $1 WHERE id = $2"
+func (p *PostgresqlStore) SetAuctionLotActiveStatus(ctx context.Context, auctionLotId int64, isActive bool) error {
+	query := "UPDATE auction_lot SET is_active = $1 WHERE id = $2 AND (NOT $1 OR moderated_at IS NULL)"
 
-	if _, err := p.connection.Exec(context.Background(), query, isActive, auctionLotId); err != nil {
+	tag, err := p.pool.Exec(ctx, query, isActive, auctionLotId)
+	if err != nil {
 		p.logError(err, "set auction lot active status")
 		return err
 	}
 
+	if isActive && tag.RowsAffected() == 0 {
+		var isModerated bool
+		err = p.pool.QueryRow(ctx, "SELECT moderated_at IS NOT NULL FROM auction_lot WHERE id = $1", auctionLotId).Scan(&isModerated)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			p.logError(err, "set auction lot active status; moderation")
+			return err
+		}
+
+		if isModerated {
+			return ErrModerated
+		}
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SetAuctionLotModerated(ctx context.Context, auctionLotId int64, moderatedAt sql.NullTime) error {
+	query := "UPDATE auction_lot SET moderated_at = $1, is_active = $1 IS NULL, updated_at = now() WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, moderatedAt, auctionLotId); err != nil {
+		p.logError(err, "set auction lot moderated")
+		return err
+	}
+
+	return nil
+}
+
+// lockLotForBidding locks the lot row so that concurrent bids on the same lot are placed one after another,
+// checks that the user may bid on it and loads its bidding state
+func (p *PostgresqlStore) lockLotForBidding(ctx context.Context, tx pgx.Tx, auctionLotId, userId int64) (*proxybid.Lot, error) {
+	lockQuery := "SELECT l.is_active AND NOT l.is_closed AND a.is_active AND NOT a.is_closed AND l.deleted_at IS NULL AND a.deleted_at IS NULL " +
+		"AND COALESCE(COALESCE(l.starts_at, a.starts_at) <= now(), true) AND COALESCE(COALESCE(l.ends_at, a.ends_at) > now(), true), " +
+		"l.minimal_bid, a.owner_id, a.id FROM auction_lot l INNER JOIN auction a ON a.id = l.auction_id WHERE l.id = $1 FOR UPDATE OF l"
+	var (
+		acceptsBids bool
+		ownerId     int64
+		auctionId   int64
+		lot         proxybid.Lot
+		err         error
+	)
+
+	if err = tx.QueryRow(ctx, lockQuery, auctionLotId).Scan(&acceptsBids, &lot.MinimalBid, &ownerId, &auctionId); err != nil {
+		p.logError(err, "lock lot for bidding")
+		return nil, err
+	}
+
+	if ownerId == userId {
+		return nil, ErrOwnLotBid
+	}
+
+	if !acceptsBids {
+		return nil, ErrLotNotAcceptingBids
+	}
+
+	if lot.HighestBid, err = p.getHighestBid(ctx, tx, auctionLotId); err != nil {
+		return nil, err
+	}
+
+	if lot.Increments, err = p.getBidIncrements(ctx, tx, auctionId); err != nil {
+		return nil, err
+	}
+
+	if lot.MaxBids, err = p.getMaxBidsByLotID(ctx, tx, auctionLotId); err != nil {
+		return nil, err
+	}
+
+	return &lot, nil
+}
+
+func (p *PostgresqlStore) insertBid(ctx context.Context, tx pgx.Tx, bid *types.Bid) (*types.Bid, error) {
+	query := "INSERT INTO bid (value, auction_lot_id, user_id) VALUES ($1, $2, $3) RETURNING id, created_at"
+	savedBid := types.CopyBid(bid)
+
+	if err := tx.QueryRow(ctx, query, bid.Value, bid.AuctionLotID, bid.UserID).Scan(&savedBid.ID, &savedBid.CreatedAt); err != nil {
+		p.logError(err, "insert bid")
+		return nil, err
+	}
+
+	return savedBid, nil
+}
+
+// placeProxyBids places the bids the proxy bidding engine resolves for the lot
+func (p *PostgresqlStore) placeProxyBids(ctx context.Context, tx pgx.Tx, lot *proxybid.Lot) ([]types.Bid, error) {
+	placed := make([]types.Bid, 0)
+
+	for _, bid := range proxybid.Resolve(*lot, time.Now()) {
+		savedBid, err := p.insertBid(ctx, tx, &bid)
+		if err != nil {
+			return nil, err
+		}
+		placed = append(placed, *savedBid)
+	}
+
+	return placed, nil
+}
+
+// extendAuctionLotEnd applies the auction soft close window to the lot after a bid has been placed on it
+func (p *PostgresqlStore) extendAuctionLotEnd(ctx context.Context, tx pgx.Tx, auctionLotId int64) error {
+	query := "UPDATE auction_lot l SET ends_at = now() + make_interval(secs => a.soft_close_seconds) FROM auction a " +
+		"WHERE l.id = $1 AND a.id = l.auction_id AND a.soft_close_seconds > 0 AND COALESCE(l.ends_at, a.ends_at) < now() + make_interval(secs => a.soft_close_seconds)"
+
+	if _, err := tx.Exec(ctx, query, auctionLotId); err != nil {
+		p.logError(err, "extend auction lot end")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) PlaceBid(ctx context.Context, bid *types.Bid) (*types.Bid, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "place bid; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lot, err := p.lockLotForBidding(ctx, tx, bid.AuctionLotID, bid.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if bid.Value.LessThan(lot.Increments.NextMinimalBid(lot.MinimalBid, lot.HighestBid)) {
+		return nil, ErrBidTooLow
+	}
+
+	savedBid, err := p.insertBid(ctx, tx, bid)
+	if err != nil {
+		return nil, err
+	}
+
+	lot.HighestBid = savedBid
+	if _, err = p.placeProxyBids(ctx, tx, lot); err != nil {
+		return nil, err
+	}
+
+	if err = p.extendAuctionLotEnd(ctx, tx, bid.AuctionLotID); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "place bid; commit")
+		return nil, err
+	}
+
+	return savedBid, nil
+}
+
+func (p *PostgresqlStore) PlaceMaxBid(ctx context.Context, maxBid *types.MaxBid) ([]types.Bid, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "place max bid; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lot, err := p.lockLotForBidding(ctx, tx, maxBid.AuctionLotID, maxBid.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isMaxBidHighEnough(lot, maxBid) {
+		return nil, ErrBidTooLow
+	}
+
+	upsertQuery := "INSERT INTO max_bid (auction_lot_id, user_id, amount) VALUES ($1, $2, $3) ON CONFLICT (auction_lot_id, user_id) DO UPDATE SET amount = EXCLUDED.amount, created_at = now() RETURNING id, created_at"
+	savedMaxBid := types.CopyMaxBid(maxBid)
+
+	if err = tx.QueryRow(ctx, upsertQuery, maxBid.AuctionLotID, maxBid.UserID, maxBid.Amount).Scan(&savedMaxBid.ID, &savedMaxBid.CreatedAt); err != nil {
+		p.logError(err, "place max bid; upsert")
+		return nil, err
+	}
+
+	lot.MaxBids = replaceMaxBid(lot.MaxBids, savedMaxBid)
+	placed, err := p.placeProxyBids(ctx, tx, lot)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(placed) > 0 {
+		if err = p.extendAuctionLotEnd(ctx, tx, maxBid.AuctionLotID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "place max bid; commit")
+		return nil, err
+	}
+
+	return placed, nil
+}
+
+func (p *PostgresqlStore) BuyNow(ctx context.Context, auctionLotId, userId int64) (*types.AuctionLotWinner, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "buy now; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lot, err := p.lockLotForBidding(ctx, tx, auctionLotId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	auctionLot := types.AuctionLot{ID: auctionLotId}
+	if err = tx.QueryRow(ctx, "SELECT bin_price FROM auction_lot WHERE id = $1", auctionLotId).Scan(&auctionLot.BinPrice); err != nil {
+		p.logError(err, "buy now; bin price")
+		return nil, err
+	}
+
+	if !auctionLot.IsBuyNowAvailable(lot.HighestBid) {
+		return nil, ErrBuyNowUnavailable
+	}
+
+	bid, err := p.insertBid(ctx, tx, &types.Bid{
+		AuctionLotID: auctionLotId,
+		UserID:       userId,
+		Value:        auctionLot.BinPrice,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	winner, err := p.closeAuctionLot(ctx, tx, bid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "buy now; commit")
+		return nil, err
+	}
+
+	return winner, nil
+}
+
+// setAuctionLotOutcome closes the lot with the outcome
+func (p *PostgresqlStore) setAuctionLotOutcome(ctx context.Context, tx pgx.Tx, auctionLotId int64, outcome types.AuctionLotOutcome) error {
+	query := "UPDATE auction_lot SET is_closed = true, outcome = $1, updated_at = now() WHERE id = $2"
+
+	if _, err := tx.Exec(ctx, query, outcome, auctionLotId); err != nil {
+		p.logError(err, "set auction lot outcome")
+		return err
+	}
+
+	return nil
+}
+
+// closeAuctionLot closes the lot of the bid as sold and records the bidder as its winner
+func (p *PostgresqlStore) closeAuctionLot(ctx context.Context, tx pgx.Tx, bid *types.Bid) (*types.AuctionLotWinner, error) {
+	if err := p.setAuctionLotOutcome(ctx, tx, bid.AuctionLotID, types.AuctionLotOutcomeSold); err != nil {
+		return nil, err
+	}
+
+	query := "INSERT INTO auction_lot_winner (auction_lot_id, user_id, bid_id) VALUES ($1, $2, $3) RETURNING won_at"
+	winner := &types.AuctionLotWinner{
+		AuctionLotID: bid.AuctionLotID,
+		UserID:       bid.UserID,
+		BidID:        bid.ID,
+		Price:        bid.Value,
+	}
+
+	if err := tx.QueryRow(ctx, query, bid.AuctionLotID, bid.UserID, bid.ID).Scan(&winner.WonAt); err != nil {
+		p.logError(err, "close auction lot; winner")
+		return nil, err
+	}
+
+	return winner, nil
+}
+
+// lockAuctionLot locks the lot row and returns the lot state its closing depends on.
+// The returned EndsAt falls back to the auction end time
+func (p *PostgresqlStore) lockAuctionLot(ctx context.Context, tx pgx.Tx, auctionLotId int64) (*types.AuctionLot, error) {
+	query := "SELECT l.is_closed, l.outcome, l.reserve_price, COALESCE(l.ends_at, a.ends_at) FROM auction_lot l INNER JOIN auction a ON a.id = l.auction_id WHERE l.id = $1 FOR UPDATE OF l"
+	lot := types.AuctionLot{ID: auctionLotId}
+
+	if err := tx.QueryRow(ctx, query, auctionLotId).Scan(&lot.IsClosed, &lot.Outcome, &lot.ReservePrice, &lot.EndsAt); err != nil {
+		p.logError(err, "lock auction lot")
+		return nil, err
+	}
+
+	return &lot, nil
+}
+
+func (p *PostgresqlStore) CloseAuctionLot(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error) {
+	return p.closeAuctionLotEndedBy(ctx, auctionLotId, sql.NullTime{})
+}
+
+func (p *PostgresqlStore) CloseEndedAuctionLot(ctx context.Context, auctionLotId int64, now time.Time) (*types.AuctionLotWinner, error) {
+	return p.closeAuctionLotEndedBy(ctx, auctionLotId, sql.NullTime{Time: now, Valid: true})
+}
+
+// closeAuctionLotEndedBy closes the lot if its end time is no later than the given time, which is ignored when it is invalid
+func (p *PostgresqlStore) closeAuctionLotEndedBy(ctx context.Context, auctionLotId int64, endedBy sql.NullTime) (*types.AuctionLotWinner, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "close auction lot; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lot, err := p.lockAuctionLot(ctx, tx, auctionLotId)
+	if err != nil {
+		return nil, err
+	}
+
+	if lot.IsClosed {
+		return nil, ErrUnexpectedLotOutcome
+	}
+
+	if endedBy.Valid && (!lot.EndsAt.Valid || lot.EndsAt.Time.After(endedBy.Time)) {
+		return nil, ErrAuctionLotNotEnded
+	}
+
+	highest, err := p.getHighestBid(ctx, tx, auctionLotId)
+	if err != nil {
+		return nil, err
+	}
+
+	var winner *types.AuctionLotWinner
+
+	if outcome := lot.ClosingOutcome(highest); outcome == types.AuctionLotOutcomeSold {
+		winner, err = p.closeAuctionLot(ctx, tx, highest)
+	} else {
+		err = p.setAuctionLotOutcome(ctx, tx, auctionLotId, outcome)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "close auction lot; commit")
+		return nil, err
+	}
+
+	return winner, nil
+}
+
+func (p *PostgresqlStore) OfferAuctionLotToHighestBidder(ctx context.Context, auctionLotId int64) error {
+	query := "UPDATE auction_lot SET outcome = $1, updated_at = now() WHERE id = $2 AND outcome = $3"
+
+	tag, err := p.pool.Exec(ctx, query, types.AuctionLotOutcomeOffered, auctionLotId, types.AuctionLotOutcomeReserveNotMet)
+	if err != nil {
+		p.logError(err, "offer auction lot to highest bidder")
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUnexpectedLotOutcome
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) RespondToAuctionLotOffer(ctx context.Context, auctionLotId, userId int64, accept bool) (*types.AuctionLotWinner, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "respond to auction lot offer; begin")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	lot, err := p.lockAuctionLot(ctx, tx, auctionLotId)
+	if err != nil {
+		return nil, err
+	}
+
+	if lot.Outcome != types.AuctionLotOutcomeOffered {
+		return nil, ErrUnexpectedLotOutcome
+	}
+
+	highest, err := p.getHighestBid(ctx, tx, auctionLotId)
+	if err != nil {
+		return nil, err
+	}
+
+	if highest == nil || highest.UserID != userId {
+		return nil, ErrNotHighestBidder
+	}
+
+	var winner *types.AuctionLotWinner
+
+	if accept {
+		winner, err = p.closeAuctionLot(ctx, tx, highest)
+	} else {
+		err = p.setAuctionLotOutcome(ctx, tx, auctionLotId, types.AuctionLotOutcomeOfferDeclined)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "respond to auction lot offer; commit")
+		return nil, err
+	}
+
+	return winner, nil
+}
+
+func (p *PostgresqlStore) GetAuctionLotWinner(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error) {
+	query := "SELECT w.auction_lot_id, w.user_id, w.bid_id, b.value, w.won_at FROM auction_lot_winner w INNER JOIN bid b ON b.id = w.bid_id WHERE w.auction_lot_id = $1"
+	var winner types.AuctionLotWinner
+
+	err := p.pool.QueryRow(ctx, query, auctionLotId).Scan(&winner.AuctionLotID, &winner.UserID, &winner.BidID, &winner.Price, &winner.WonAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get auction lot winner")
+		return nil, err
+	}
+
+	return &winner, nil
+}
+
+func (p *PostgresqlStore) getMaxBidsByLotID(ctx context.Context, q querier, auctionLotId int64) ([]types.MaxBid, error) {
+	query := "SELECT id, auction_lot_id, user_id, amount, created_at FROM max_bid WHERE auction_lot_id = $1"
+
+	rows, err := q.Query(ctx, query, auctionLotId)
+	if err != nil {
+		p.logError(err, "get max bids by lot id")
+		return nil, err
+	}
+	defer rows.Close()
+
+	maxBids := make([]types.MaxBid, 0)
+
+	for rows.Next() {
+		var maxBid types.MaxBid
+
+		if err = rows.Scan(&maxBid.ID, &maxBid.AuctionLotID, &maxBid.UserID, &maxBid.Amount, &maxBid.CreatedAt); err != nil {
+			p.logError(err, "get max bids by lot id; rows")
+			return nil, err
+		}
+
+		maxBids = append(maxBids, maxBid)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get max bids by lot id; after rows")
+		return nil, err
+	}
+
+	return maxBids, nil
+}
+
+func (p *PostgresqlStore) GetMaxBid(ctx context.Context, auctionLotId, userId int64) (*types.MaxBid, error) {
+	query := "SELECT id, auction_lot_id, user_id, amount, created_at FROM max_bid WHERE auction_lot_id = $1 AND user_id = $2"
+	var maxBid types.MaxBid
+
+	err := p.pool.QueryRow(ctx, query, auctionLotId, userId).Scan(&maxBid.ID, &maxBid.AuctionLotID, &maxBid.UserID, &maxBid.Amount, &maxBid.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get max bid")
+		return nil, err
+	}
+
+	return &maxBid, nil
+}
+
+func (p *PostgresqlStore) GetBidsByLotID(ctx context.Context, auctionLotId int64) ([]types.Bid, error) {
+	query := "SELECT id, value, auction_lot_id, user_id, created_at FROM bid WHERE auction_lot_id = $1 ORDER BY value DESC, created_at"
+
+	rows, err := p.pool.Query(ctx, query, auctionLotId)
+	if err != nil {
+		p.logError(err, "get bids by lot id")
+		return nil, err
+	}
+	defer rows.Close()
+
+	bids := make([]types.Bid, 0)
+
+	for rows.Next() {
+		var bid types.Bid
+
+		if err = rows.Scan(&bid.ID, &bid.Value, &bid.AuctionLotID, &bid.UserID, &bid.CreatedAt); err != nil {
+			p.logError(err, "get bids by lot id; rows")
+			return nil, err
+		}
+
+		bids = append(bids, bid)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get bids by lot id; after rows")
+		return nil, err
+	}
+
+	return bids, nil
+}
+
+func (p *PostgresqlStore) GetHighestBid(ctx context.Context, auctionLotId int64) (*types.Bid, error) {
+	return p.getHighestBid(ctx, p.pool, auctionLotId)
+}
+
+func (p *PostgresqlStore) GetRecentBids(ctx context.Context, limit int) ([]types.Bid, error) {
+	query := "SELECT id, value, auction_lot_id, user_id, created_at FROM bid ORDER BY created_at DESC, id DESC LIMIT $1"
+
+	rows, err := p.pool.Query(ctx, query, limit)
+	if err != nil {
+		p.logError(err, "get recent bids")
+		return nil, err
+	}
+	defer rows.Close()
+
+	bids := make([]types.Bid, 0)
+
+	for rows.Next() {
+		var bid types.Bid
+
+		if err = rows.Scan(&bid.ID, &bid.Value, &bid.AuctionLotID, &bid.UserID, &bid.CreatedAt); err != nil {
+			p.logError(err, "get recent bids; rows")
+			return nil, err
+		}
+
+		bids = append(bids, bid)
+	}
+
+	if err = rows.Err(); err != nil {
+		p.logError(err, "get recent bids; after rows")
+		return nil, err
+	}
+
+	return bids, nil
+}
+
+func (p *PostgresqlStore) getHighestBid(ctx context.Context, q querier, auctionLotId int64) (*types.Bid, error) {
+	query := "SELECT id, value, auction_lot_id, user_id, created_at FROM bid WHERE auction_lot_id = $1 ORDER BY value DESC, created_at LIMIT 1"
+	var bid types.Bid
+
+	err := q.QueryRow(ctx, query, auctionLotId).Scan(&bid.ID, &bid.Value, &bid.AuctionLotID, &bid.UserID, &bid.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get highest bid")
+		return nil, err
+	}
+
+	return &bid, nil
+}
+
+func (p *PostgresqlStore) OpenDueAuctions(ctx context.Context, now time.Time) ([]int64, error) {
+	query := "UPDATE auction SET is_started = true, updated_at = $1 WHERE is_active AND NOT is_started AND NOT is_closed AND deleted_at IS NULL AND starts_at <= $1 RETURNING id"
+
+	rows, err := p.pool.Query(ctx, query, now)
+	if err != nil {
+		p.logError(err, "open due auctions")
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		p.logError(err, "open due auctions; rows")
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (p *PostgresqlStore) GetDueAuctionLotIDs(ctx context.Context, now time.Time) ([]int64, error) {
+	query := "SELECT l.id FROM auction_lot l INNER JOIN auction a ON a.id = l.auction_id WHERE NOT l.is_closed AND l.deleted_at IS NULL AND a.deleted_at IS NULL AND COALESCE(l.ends_at, a.ends_at) <= $1"
+
+	rows, err := p.pool.Query(ctx, query, now)
+	if err != nil {
+		p.logError(err, "get due auction lot ids")
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		p.logError(err, "get due auction lot ids; rows")
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (p *PostgresqlStore) CloseDueAuctions(ctx context.Context, now time.Time) ([]int64, error) {
+	query := "UPDATE auction a SET is_closed = true, updated_at = $1 WHERE NOT a.is_closed AND a.deleted_at IS NULL AND a.ends_at <= $1 " +
+		"AND NOT EXISTS (SELECT 1 FROM auction_lot l WHERE l.auction_id = a.id AND NOT l.is_closed AND l.deleted_at IS NULL) RETURNING a.id"
+
+	rows, err := p.pool.Query(ctx, query, now)
+	if err != nil {
+		p.logError(err, "close due auctions")
+		return nil, err
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		p.logError(err, "close due auctions; rows")
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (p *PostgresqlStore) GetNextScheduledTransition(ctx context.Context, now time.Time) (sql.NullTime, error) {
+	query := "SELECT MIN(t) FROM (" +
+		"SELECT starts_at AS t FROM auction WHERE NOT is_started AND NOT is_closed AND deleted_at IS NULL AND starts_at > $1 " +
+		"UNION ALL SELECT ends_at FROM auction WHERE NOT is_closed AND deleted_at IS NULL AND ends_at > $1 " +
+		"UNION ALL SELECT COALESCE(l.ends_at, a.ends_at) FROM auction_lot l INNER JOIN auction a ON a.id = l.auction_id WHERE NOT l.is_closed AND l.deleted_at IS NULL AND a.deleted_at IS NULL AND COALESCE(l.ends_at, a.ends_at) > $1" +
+		") transitions"
+	var next sql.NullTime
+
+	if err := p.pool.QueryRow(ctx, query, now).Scan(&next); err != nil {
+		p.logError(err, "get next scheduled transition")
+		return sql.NullTime{}, err
+	}
+
+	return next, nil
+}
+
+func (p *PostgresqlStore) SaveSession(ctx context.Context, session *types.Session) error {
+	query := "INSERT INTO session (id, user_id, created_at, expires_at, last_seen_at) VALUES ($1, $2, $3, $4, $5)"
+
+	if _, err := p.pool.Exec(ctx, query, session.ID, session.UserID, session.CreatedAt, session.ExpiresAt, session.LastSeenAt); err != nil {
+		p.logError(err, "save session")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) GetSession(ctx context.Context, id string) (*types.Session, error) {
+	query := "SELECT id, user_id, created_at, expires_at, last_seen_at FROM session WHERE id = $1"
+	var session types.Session
+
+	err := p.pool.QueryRow(ctx, query, id).Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &session.LastSeenAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get session")
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (p *PostgresqlStore) RenewSession(ctx context.Context, id string, expiresAt, lastSeenAt time.Time) error {
+	query := "UPDATE session SET expires_at = $1, last_seen_at = $2 WHERE id = $3"
+
+	if _, err := p.pool.Exec(ctx, query, expiresAt, lastSeenAt, id); err != nil {
+		p.logError(err, "renew session")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteSession(ctx context.Context, id string) error {
+	query := "DELETE FROM session WHERE id = $1"
+
+	if _, err := p.pool.Exec(ctx, query, id); err != nil {
+		p.logError(err, "delete session")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteUserSessions(ctx context.Context, userId int64) error {
+	query := "DELETE FROM session WHERE user_id = $1"
+
+	if _, err := p.pool.Exec(ctx, query, userId); err != nil {
+		p.logError(err, "delete user sessions")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteOtherUserSessions(ctx context.Context, userId int64, keepId string) error {
+	query := "DELETE FROM session WHERE user_id = $1 AND id <> $2"
+
+	if _, err := p.pool.Exec(ctx, query, userId, keepId); err != nil {
+		p.logError(err, "delete other user sessions")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	query := "DELETE FROM session WHERE expires_at <= $1"
+
+	if _, err := p.pool.Exec(ctx, query, now); err != nil {
+		p.logError(err, "delete expired sessions")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) SavePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error {
+	query := "INSERT INTO password_reset_token (id, user_id, created_at, expires_at) VALUES ($1, $2, $3, $4)"
+
+	if _, err := p.pool.Exec(ctx, query, token.ID, token.UserID, token.CreatedAt, token.ExpiresAt); err != nil {
+		p.logError(err, "save password reset token")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) GetPasswordResetToken(ctx context.Context, id string) (*types.PasswordResetToken, error) {
+	query := "SELECT id, user_id, created_at, expires_at, used_at FROM password_reset_token WHERE id = $1"
+	var token types.PasswordResetToken
+
+	err := p.pool.QueryRow(ctx, query, id).Scan(&token.ID, &token.UserID, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get password reset token")
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (p *PostgresqlStore) ResetPassword(ctx context.Context, tokenId string, passwordHash string, now time.Time) (int64, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "reset password; begin")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the conditional update uses the token up exactly once even with concurrent requests
+	var userId int64
+	query := "UPDATE password_reset_token SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id"
+	if err = tx.QueryRow(ctx, query, now, tokenId).Scan(&userId); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidPasswordResetToken
+		}
+		p.logError(err, "reset password; use token")
+		return 0, err
+	}
+
+	if _, err = tx.Exec(ctx, "UPDATE users SET password = $1 WHERE id = $2", passwordHash, userId); err != nil {
+		p.logError(err, "reset password; update password")
+		return 0, err
+	}
+
+	if _, err = tx.Exec(ctx, "DELETE FROM password_reset_token WHERE user_id = $1 AND used_at IS NULL", userId); err != nil {
+		p.logError(err, "reset password; discard tokens")
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "reset password; commit")
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (p *PostgresqlStore) SaveEmailVerificationToken(ctx context.Context, token *types.EmailVerificationToken) error {
+	query := "INSERT INTO email_verification_token (id, user_id, email, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)"
+
+	if _, err := p.pool.Exec(ctx, query, token.ID, token.UserID, token.Email, token.CreatedAt, token.ExpiresAt); err != nil {
+		p.logError(err, "save email verification token")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) CountEmailVerificationTokensSince(ctx context.Context, userId int64, since time.Time) (int, error) {
+	query := "SELECT count(*) FROM email_verification_token WHERE user_id = $1 AND created_at > $2"
+	var count int
+
+	if err := p.pool.QueryRow(ctx, query, userId, since).Scan(&count); err != nil {
+		p.logError(err, "count email verification tokens")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p *PostgresqlStore) VerifyEmail(ctx context.Context, tokenId string, now time.Time) (int64, error) {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "verify email; begin")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var (
+		userId int64
+		email  string
+	)
+	query := "SELECT user_id, email FROM email_verification_token WHERE id = $1 AND expires_at > $2 FOR UPDATE"
+	if err = tx.QueryRow(ctx, query, tokenId, now).Scan(&userId, &email); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrInvalidEmailVerificationToken
+		}
+		p.logError(err, "verify email; get token")
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, "UPDATE users SET email_verified_at = $1 WHERE id = $2 AND email = $3", now, userId, email)
+	if err != nil {
+		p.logError(err, "verify email; update user")
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, ErrInvalidEmailVerificationToken
+	}
+
+	if _, err = tx.Exec(ctx, "DELETE FROM email_verification_token WHERE user_id = $1", userId); err != nil {
+		p.logError(err, "verify email; discard tokens")
+		return 0, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "verify email; commit")
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+func (p *PostgresqlStore) GetTwoFactor(ctx context.Context, userId int64) (*types.TwoFactor, error) {
+	query := "SELECT user_id, secret, enabled_at, last_used_step FROM two_factor WHERE user_id = $1"
+	var twoFactor types.TwoFactor
+
+	err := p.pool.QueryRow(ctx, query, userId).Scan(&twoFactor.UserID, &twoFactor.Secret, &twoFactor.EnabledAt, &twoFactor.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get two factor")
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+func (p *PostgresqlStore) SaveTwoFactorSecret(ctx context.Context, userId int64, secret string) error {
+	query := "INSERT INTO two_factor (user_id, secret) VALUES ($1, $2) " +
+		"ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0 WHERE two_factor.enabled_at IS NULL"
+
+	if _, err := p.pool.Exec(ctx, query, userId, secret); err != nil {
+		p.logError(err, "save two factor secret")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) EnableTwoFactor(ctx context.Context, userId int64, step int64, now time.Time, recoveryCodeHashes []string) error {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "enable two factor; begin")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := "UPDATE two_factor SET enabled_at = $1, last_used_step = $2 WHERE user_id = $3 AND enabled_at IS NULL AND last_used_step < $2"
+	tag, err := tx.Exec(ctx, query, now, step, userId)
+	if err != nil {
+		p.logError(err, "enable two factor; update")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorCodeReused
+	}
+
+	if _, err = tx.Exec(ctx, "DELETE FROM two_factor_recovery_code WHERE user_id = $1", userId); err != nil {
+		p.logError(err, "enable two factor; discard recovery codes")
+		return err
+	}
+
+	rows := make([][]any, len(recoveryCodeHashes))
+	for i, hash := range recoveryCodeHashes {
+		rows[i] = []any{userId, hash}
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"two_factor_recovery_code"}, []string{"user_id", "code_hash"}, pgx.CopyFromRows(rows))
+	if err != nil {
+		p.logError(err, "enable two factor; save recovery codes")
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "enable two factor; commit")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DisableTwoFactor(ctx context.Context, userId int64) error {
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		p.logError(err, "disable two factor; begin")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, "DELETE FROM two_factor_recovery_code WHERE user_id = $1", userId); err != nil {
+		p.logError(err, "disable two factor; discard recovery codes")
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, "DELETE FROM two_factor WHERE user_id = $1", userId); err != nil {
+		p.logError(err, "disable two factor; delete")
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		p.logError(err, "disable two factor; commit")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) UseTwoFactorStep(ctx context.Context, userId int64, step int64) error {
+	// the conditional update accepts a step once even with concurrent logins
+	query := "UPDATE two_factor SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1"
+
+	tag, err := p.pool.Exec(ctx, query, step, userId)
+	if err != nil {
+		p.logError(err, "use two factor step")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorCodeReused
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) UseRecoveryCode(ctx context.Context, userId int64, codeHash string, now time.Time) error {
+	query := "UPDATE two_factor_recovery_code SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL"
+
+	tag, err := p.pool.Exec(ctx, query, now, userId, codeHash)
+	if err != nil {
+		p.logError(err, "use recovery code")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidRecoveryCode
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) CountRecoveryCodes(ctx context.Context, userId int64) (int, error) {
+	query := "SELECT count(*) FROM two_factor_recovery_code WHERE user_id = $1 AND used_at IS NULL"
+	var count int
+
+	if err := p.pool.QueryRow(ctx, query, userId).Scan(&count); err != nil {
+		p.logError(err, "count recovery codes")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p *PostgresqlStore) SaveLoginChallenge(ctx context.Context, challenge *types.LoginChallenge) error {
+
+	// logins are rare enough to clean up the abandoned challenges on the way
+	if _, err := p.pool.Exec(ctx, "DELETE FROM login_challenge WHERE expires_at <= now()"); err != nil {
+		p.logError(err, "save login challenge; delete expired")
+		return err
+	}
+
+	query := "INSERT INTO login_challenge (id, user_id, expires_at, attempts) VALUES ($1, $2, $3, $4)"
+	if _, err := p.pool.Exec(ctx, query, challenge.ID, challenge.UserID, challenge.ExpiresAt, challenge.Attempts); err != nil {
+		p.logError(err, "save login challenge")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) GetLoginChallenge(ctx context.Context, id string) (*types.LoginChallenge, error) {
+	query := "SELECT id, user_id, expires_at, attempts FROM login_challenge WHERE id = $1"
+	var challenge types.LoginChallenge
+
+	err := p.pool.QueryRow(ctx, query, id).Scan(&challenge.ID, &challenge.UserID, &challenge.ExpiresAt, &challenge.Attempts)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get login challenge")
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (p *PostgresqlStore) RecordLoginChallengeAttempt(ctx context.Context, id string) (int, error) {
+	query := "UPDATE login_challenge SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts"
+	var attempts int
+
+	if err := p.pool.QueryRow(ctx, query, id).Scan(&attempts); err != nil {
+		p.logError(err, "record login challenge attempt")
+		return 0, err
+	}
+
+	return attempts, nil
+}
+
+func (p *PostgresqlStore) DeleteLoginChallenge(ctx context.Context, id string) error {
+	query := "DELETE FROM login_challenge WHERE id = $1"
+
+	if _, err := p.pool.Exec(ctx, query, id); err != nil {
+		p.logError(err, "delete login challenge")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) GetLoginThrottle(ctx context.Context, key string) (*types.LoginThrottle, error) {
+	query := "SELECT key, failures, last_failure_at FROM login_throttle WHERE key = $1"
+	var throttle types.LoginThrottle
+
+	err := p.pool.QueryRow(ctx, query, key).Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get login throttle")
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+func (p *PostgresqlStore) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*types.LoginThrottle, error) {
+	// the upsert counts concurrent failures of the key without losing any
+	query := "INSERT INTO login_throttle (key, failures, last_failure_at) VALUES ($1, 1, $2) " +
+		"ON CONFLICT (key) DO UPDATE SET last_failure_at = EXCLUDED.last_failure_at, " +
+		"failures = CASE WHEN login_throttle.last_failure_at + $3 * interval '1 second' > EXCLUDED.last_failure_at THEN login_throttle.failures + 1 ELSE 1 END " +
+		"RETURNING key, failures, last_failure_at"
+	var throttle types.LoginThrottle
+
+	err := p.pool.QueryRow(ctx, query, key, now, resetAfter.Seconds()).Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt)
+	if err != nil {
+		p.logError(err, "record login failure")
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+func (p *PostgresqlStore) DeleteLoginThrottle(ctx context.Context, key string) error {
+	query := "DELETE FROM login_throttle WHERE key = $1"
+
+	if _, err := p.pool.Exec(ctx, query, key); err != nil {
+		p.logError(err, "delete login throttle")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) error {
+	query := "DELETE FROM login_throttle WHERE last_failure_at < $1"
+
+	if _, err := p.pool.Exec(ctx, query, before); err != nil {
+		p.logError(err, "delete stale login throttles")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) GetUserByExternalIdentity(ctx context.Context, issuer, subject string) (*types.User, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = (SELECT user_id FROM external_identity WHERE issuer = $1 AND subject = $2)"
+
+	user, err := scanUser(p.pool.QueryRow(ctx, query, issuer, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get user by external identity")
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (p *PostgresqlStore) SaveExternalIdentity(ctx context.Context, identity *types.ExternalIdentity) error {
+	query := "INSERT INTO external_identity (issuer, subject, user_id, email, created_at) VALUES ($1, $2, $3, $4, $5)"
+
+	if _, err := p.pool.Exec(ctx, query, identity.Issuer, identity.Subject, identity.UserID, identity.Email, identity.CreatedAt); err != nil {
+		p.logError(err, "save external identity")
+		return err
+	}
+
+	return nil
+}
+
+func scopesFromStrings(values []string) []types.Scope {
+	scopes := make([]types.Scope, len(values))
+	for i, value := range values {
+		scopes[i] = types.Scope(value)
+	}
+	return scopes
+}
+
+func scopesToStrings(scopes []types.Scope) []string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+	return values
+}
+
+const apiTokenColumns = "id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at"
+
+func scanAPIToken(row pgx.Row) (*types.APIToken, error) {
+	var (
+		token  types.APIToken
+		scopes []string
+	)
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = scopesFromStrings(scopes)
+
+	return &token, nil
+}
+
+func (p *PostgresqlStore) SaveAPIToken(ctx context.Context, token *types.APIToken) (*types.APIToken, error) {
+	query := "INSERT INTO api_token (user_id, name, token_hash, scopes, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING " + apiTokenColumns
+	args := []any{token.UserID, token.Name, token.TokenHash, scopesToStrings(token.Scopes), token.CreatedAt, token.ExpiresAt}
+
+	saved, err := scanAPIToken(p.pool.QueryRow(ctx, query, args...))
+	if err != nil {
+		p.logError(err, "save api token")
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+func (p *PostgresqlStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (*types.APIToken, error) {
+	query := "SELECT " + apiTokenColumns + " FROM api_token WHERE token_hash = $1"
+
+	token, err := scanAPIToken(p.pool.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		p.logError(err, "get api token by hash")
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (p *PostgresqlStore) GetAPITokensByUserID(ctx context.Context, userId int64) ([]types.APIToken, error) {
+	query := "SELECT " + apiTokenColumns + " FROM api_token WHERE user_id = $1 ORDER BY created_at DESC, id DESC"
+
+	rows, err := p.pool.Query(ctx, query, userId)
+	if err != nil {
+		p.logError(err, "get api tokens by user id")
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]types.APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			p.logError(err, "get api tokens by user id; rows")
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (p *PostgresqlStore) SetAPITokenLastUsed(ctx context.Context, id int64, lastUsedAt time.Time) error {
+	query := "UPDATE api_token SET last_used_at = $1 WHERE id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, lastUsedAt, id); err != nil {
+		p.logError(err, "set api token last used")
+		return err
+	}
+
+	return nil
+}
+
+func (p *PostgresqlStore) DeleteAPIToken(ctx context.Context, userId, id int64) error {
+	query := "DELETE FROM api_token WHERE id = $1 AND user_id = $2"
+
+	if _, err := p.pool.Exec(ctx, query, id, userId); err != nil {
+		p.logError(err, "delete api token")
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,67 @@
+package storage_test
+
+import (
+	"context"
+	"github.com/artemsmotritel/oktion/migration"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/storagetest"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testDatabaseEnv names the connection url of a database the tests may empty, the Postgres tests are skipped without it
+const testDatabaseEnv = "OKTION_TEST_DATABASE_URL"
+
+func TestPostgresqlStore(t *testing.T) {
+	dbURL := os.Getenv(testDatabaseEnv)
+	if dbURL == "" {
+		t.Skipf("%s is not set", testDatabaseEnv)
+	}
+
+	ctx := context.Background()
+	logger := log.New(io.Discard, "", 0)
+
+	pool, err := storage.NewPostgresqlPool(ctx, dbURL, storage.PoolConfig{})
+	if err != nil {
+		t.Fatalf("connect to the database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrations, err := migration.Embedded()
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err = migration.NewMigrator(pool, migrations, logger).Up(ctx); err != nil {
+		t.Fatalf("migrate the database: %v", err)
+	}
+
+	store := storage.NewPostgresqlStore(pool, logger)
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		// every table but the migration history is emptied and its ids start over
+		rows, err := pool.Query(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'")
+		if err != nil {
+			t.Fatalf("list tables: %v", err)
+		}
+		tables := make([]string, 0)
+		for rows.Next() {
+			var table string
+			if err = rows.Scan(&table); err != nil {
+				t.Fatalf("list tables: %v", err)
+			}
+			tables = append(tables, table)
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatalf("list tables: %v", err)
+		}
+
+		if _, err = pool.Exec(ctx, "TRUNCATE "+strings.Join(tables, ", ")+" RESTART IDENTITY CASCADE"); err != nil {
+			t.Fatalf("empty the tables: %v", err)
+		}
+
+		return store
+	})
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"context"
+	"github.com/artemsmotritel/oktion/types"
+	"time"
+)
+
+// SessionStore keeps the logged-in sessions, see session.Manager
+type SessionStore interface {
+	SaveSession(ctx context.Context, session *types.Session) error
+	// GetSession returns nil if there is no session with the id
+	GetSession(ctx context.Context, id string) (*types.Session, error)
+	// RenewSession moves the session expiry, which is how the sliding renewal works
+	RenewSession(ctx context.Context, id string, expiresAt, lastSeenAt time.Time) error
+	DeleteSession(ctx context.Context, id string) error
+	// DeleteUserSessions logs the user out everywhere
+	DeleteUserSessions(ctx context.Context, userId int64) error
+	// DeleteOtherUserSessions logs the user out everywhere except the session with the id
+	DeleteOtherUserSessions(ctx context.Context, userId int64, keepId string) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) error
+}
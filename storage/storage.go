@@ -1,32 +1,224 @@
 package storage
 
-import "github.com/artemsmotritel/oktion/types"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/artemsmotritel/oktion/proxybid"
+	"github.com/artemsmotritel/oktion/seed"
+	"github.com/artemsmotritel/oktion/types"
+	"time"
+)
+
+var (
+	ErrBidTooLow           = errors.New("the bid is lower than the minimal acceptable bid")
+	ErrLotNotAcceptingBids = errors.New("the auction lot does not accept bids")
+	ErrOwnLotBid           = errors.New("the auction owner cannot bid on their own lots")
+	ErrBuyNowUnavailable   = errors.New("the auction lot can't be bought now")
+	// ErrUnexpectedLotOutcome is returned when the action doesn't fit the current auction lot outcome
+	ErrUnexpectedLotOutcome = errors.New("the auction lot outcome doesn't allow this action")
+	ErrNotHighestBidder     = errors.New("the user is not the highest bidder of the auction lot")
+	ErrAuctionLotNotEnded   = errors.New("the auction lot end time hasn't come yet")
+	ErrLotPricesLocked      = errors.New("the auction lot prices can't change once it has bids or has closed")
+	// ErrModerated is returned when the owner tries to reinstate an auction or a lot an admin has taken off
+	ErrModerated = errors.New("an admin has taken it off, only an admin can reinstate it")
+	// ErrInvalidPasswordResetToken is returned for an unknown, used or expired password reset token
+	ErrInvalidPasswordResetToken = errors.New("the password reset token is invalid")
+	// ErrInvalidEmailVerificationToken is returned for an unknown or expired token, or one sent to an email the user no longer has
+	ErrInvalidEmailVerificationToken = errors.New("the email verification token is invalid")
+	// ErrTwoFactorCodeReused is returned for a code of the time step the user has already logged in with
+	ErrTwoFactorCodeReused = errors.New("the two-factor code has already been used")
+	// ErrInvalidRecoveryCode is returned for an unknown or used recovery code
+	ErrInvalidRecoveryCode = errors.New("the recovery code is invalid")
+	ErrSeedNotEmpty        = errors.New("the storage already holds data, seeding needs an empty one")
+)
 
 type Storage interface {
-	GetUserByID(id int64) (*types.User, error)
-	GetUsers() ([]types.User, error)
-	SaveUser(user *types.User) (*types.User, error)
-	UpdateUser(id int64, request types.UserUpdateRequest) (*types.User, error)
-	DeleteUser(id int64) error
-	GetUserByEmail(email string) (*types.User, error)
-
-	GetAuctionsByOwnerId(ownerId int64) ([]types.Auction, error)
-	GetOwnerIDByAuctionID(auctionId int64) (int64, error)
-	GetAuctionByID(id int64) (*types.Auction, error)
-	GetAuctions() ([]types.Auction, error)
-	SaveAuction(auction *types.Auction) (*types.Auction, error)
-	DeleteAuction(id int64) error
-	UpdateAuction(auction types.AuctionUpdateRequest) (*types.Auction, error)
-	SetAuctionActiveStatus(auctionId int64, isActive bool) error
-
-	GetAuctionLotsByAuctionID(auctionId int64) ([]types.AuctionLot, error)
-	SaveAuctionLot(auctionLot *types.AuctionLot) (*types.AuctionLot, error)
-	GetAuctionLotCount(auctionId int64) (int, error)
-	GetAuctionLotByID(auctionLotId int64) (*types.AuctionLot, error)
-	UpdateAuctionLot(auctionLotId int64, lot *types.AuctionLotUpdateRequest) (*types.AuctionLot, error)
-	SetAuctionLotActiveStatus(auctionLotId int64, isActive bool) error
-
-	GetCategories() ([]types.Category, error)
-
-	SeedData() error
+	// GetUserByID returns nil if there is no user with the id
+	GetUserByID(ctx context.Context, id int64) (*types.User, error)
+	// GetUsers returns a page of users ordered by id
+	GetUsers(ctx context.Context, limit, offset int) ([]types.User, error)
+	CountUsers(ctx context.Context) (int64, error)
+	SaveUser(ctx context.Context, user *types.User) (*types.User, error)
+	UpdateUser(ctx context.Context, id int64, request types.UserUpdateRequest) (*types.User, error)
+	DeleteUser(ctx context.Context, id int64) error
+	SetUserRoles(ctx context.Context, id int64, roles []types.Role) error
+	GetUserByEmail(ctx context.Context, email string) (*types.User, error)
+	// SearchUsers matches the search against the user email, full name and id
+	SearchUsers(ctx context.Context, search string, limit int) ([]types.User, error)
+	SetUserPassword(ctx context.Context, id int64, passwordHash string) error
+	// SetUserEmail changes the email and marks it unverified
+	SetUserEmail(ctx context.Context, id int64, email string) error
+	// SetUserSuspended suspends the user, an invalid time lifts the suspension
+	SetUserSuspended(ctx context.Context, id int64, suspendedAt sql.NullTime) error
+
+	// GetUserByExternalIdentity returns nil if no user is linked to the identity
+	GetUserByExternalIdentity(ctx context.Context, issuer, subject string) (*types.User, error)
+	SaveExternalIdentity(ctx context.Context, identity *types.ExternalIdentity) error
+
+	SaveAPIToken(ctx context.Context, token *types.APIToken) (*types.APIToken, error)
+	// GetAPITokenByHash returns nil if there is no token with the hash
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*types.APIToken, error)
+	// GetAPITokensByUserID returns the latest created tokens first
+	GetAPITokensByUserID(ctx context.Context, userId int64) ([]types.APIToken, error)
+	SetAPITokenLastUsed(ctx context.Context, id int64, lastUsedAt time.Time) error
+	// DeleteAPIToken deletes the token only if it belongs to the user
+	DeleteAPIToken(ctx context.Context, userId, id int64) error
+
+	SavePasswordResetToken(ctx context.Context, token *types.PasswordResetToken) error
+	// GetPasswordResetToken returns nil if there is no token with the id
+	GetPasswordResetToken(ctx context.Context, id string) (*types.PasswordResetToken, error)
+	// ResetPassword uses up the token, sets the new password hash of its user and discards the other unused tokens of the user.
+	// It returns the user id, or ErrInvalidPasswordResetToken if the token can't be used at now
+	ResetPassword(ctx context.Context, tokenId string, passwordHash string, now time.Time) (int64, error)
+
+	SaveEmailVerificationToken(ctx context.Context, token *types.EmailVerificationToken) error
+	// CountEmailVerificationTokensSince counts the tokens sent to the user after since, to rate limit the resending
+	CountEmailVerificationTokensSince(ctx context.Context, userId int64, since time.Time) (int, error)
+	// VerifyEmail marks the token email as verified and discards the tokens of the user.
+	// It returns the user id, or ErrInvalidEmailVerificationToken if the token can't be used at now
+	VerifyEmail(ctx context.Context, tokenId string, now time.Time) (int64, error)
+
+	// GetTwoFactor returns nil if the user has never started the two-factor enrolment
+	GetTwoFactor(ctx context.Context, userId int64) (*types.TwoFactor, error)
+	// SaveTwoFactorSecret starts the enrolment over with a new secret, an enabled enrolment is left as it is
+	SaveTwoFactorSecret(ctx context.Context, userId int64, secret string) error
+	// EnableTwoFactor finishes the enrolment confirmed by the code of the step and replaces the recovery codes
+	EnableTwoFactor(ctx context.Context, userId int64, step int64, now time.Time, recoveryCodeHashes []string) error
+	// DisableTwoFactor removes the enrolment along with the recovery codes
+	DisableTwoFactor(ctx context.Context, userId int64) error
+	// UseTwoFactorStep records the step of an accepted code.
+	// It returns ErrTwoFactorCodeReused if the step isn't after the last used one, so a seen code can't be replayed
+	UseTwoFactorStep(ctx context.Context, userId int64, step int64) error
+	// UseRecoveryCode uses the code up. It returns ErrInvalidRecoveryCode for an unknown or used code
+	UseRecoveryCode(ctx context.Context, userId int64, codeHash string, now time.Time) error
+	// CountRecoveryCodes counts the unused recovery codes of the user
+	CountRecoveryCodes(ctx context.Context, userId int64) (int, error)
+
+	SaveLoginChallenge(ctx context.Context, challenge *types.LoginChallenge) error
+	// GetLoginChallenge returns nil if there is no challenge with the id
+	GetLoginChallenge(ctx context.Context, id string) (*types.LoginChallenge, error)
+	// RecordLoginChallengeAttempt counts a wrong code and returns the attempts made so far
+	RecordLoginChallengeAttempt(ctx context.Context, id string) (int, error)
+	DeleteLoginChallenge(ctx context.Context, id string) error
+
+	GetAuctionsByOwnerId(ctx context.Context, ownerId int64) ([]types.Auction, error)
+	// GetOwnerIDByAuctionID returns 0 if there is no auction with the id
+	GetOwnerIDByAuctionID(ctx context.Context, auctionId int64) (int64, error)
+	// GetAuctionByID returns nil if there is no auction with the id
+	GetAuctionByID(ctx context.Context, id int64) (*types.Auction, error)
+	// GetAuctions returns every auction ordered by id
+	GetAuctions(ctx context.Context) ([]types.Auction, error)
+	SaveAuction(ctx context.Context, auction *types.Auction) (*types.Auction, error)
+	// DeleteAuction soft deletes the auction along with its lots: they are hidden from the reads, listings and
+	// the schedule, and can't be bid on, but their bids stay
+	DeleteAuction(ctx context.Context, id int64) error
+	UpdateAuction(ctx context.Context, auction types.AuctionUpdateRequest) (*types.Auction, error)
+	// SetAuctionActiveStatus returns ErrModerated when reinstating an auction an admin has taken off
+	SetAuctionActiveStatus(ctx context.Context, auctionId int64, isActive bool) error
+	// SetAuctionModerated archives the auction for the admin when moderatedAt is valid and reinstates it otherwise
+	SetAuctionModerated(ctx context.Context, auctionId int64, moderatedAt sql.NullTime) error
+	// SetAuctionBidIncrements replaces the whole auction bid increment ladder
+	SetAuctionBidIncrements(ctx context.Context, auctionId int64, increments types.BidIncrements) (types.BidIncrements, error)
+	SetAuctionSoftCloseWindow(ctx context.Context, auctionId int64, window time.Duration) error
+	SetAuctionManagerTwoFactor(ctx context.Context, auctionId int64, required bool) error
+	// GetRecentAuctions returns the latest created auctions of every owner
+	GetRecentAuctions(ctx context.Context, limit int) ([]types.Auction, error)
+
+	GetAuctionLotsByAuctionID(ctx context.Context, auctionId int64) ([]types.AuctionLot, error)
+	SaveAuctionLot(ctx context.Context, auctionLot *types.AuctionLot) (*types.AuctionLot, error)
+	GetAuctionLotCount(ctx context.Context, auctionId int64) (int, error)
+	// GetAuctionLotByID returns nil if there is no auction lot with the id
+	GetAuctionLotByID(ctx context.Context, auctionLotId int64) (*types.AuctionLot, error)
+	// UpdateAuctionLot returns ErrLotPricesLocked if the lot has bids or has closed and the update changes its prices.
+	// The end time of such a lot is never moved earlier, see types.AuctionLot.KeptEndsAt
+	UpdateAuctionLot(ctx context.Context, auctionLotId int64, lot *types.AuctionLotUpdateRequest) (*types.AuctionLot, error)
+	// SetAuctionLotActiveStatus returns ErrModerated when reinstating a lot an admin has taken off
+	SetAuctionLotActiveStatus(ctx context.Context, auctionLotId int64, isActive bool) error
+	// SetAuctionLotModerated deactivates the lot for the admin when moderatedAt is valid and reinstates it otherwise
+	SetAuctionLotModerated(ctx context.Context, auctionLotId int64, moderatedAt sql.NullTime) error
+
+	// PlaceBid saves the bid only if it is no less than the next minimal bid of the lot, see types.BidIncrements,
+	// and lets the max bidders respond to it, see proxybid.Resolve.
+	// A bid placed in the auction soft close window extends the lot end time, see types.AuctionLot.SoftCloseEndsAt.
+	// It returns ErrBidTooLow, ErrLotNotAcceptingBids or ErrOwnLotBid when the bid can't be placed
+	PlaceBid(ctx context.Context, bid *types.Bid) (*types.Bid, error)
+	// PlaceMaxBid saves or raises the user maximum bid and returns the bids the proxy bidding engine has placed.
+	// It returns the same errors as PlaceBid
+	PlaceMaxBid(ctx context.Context, maxBid *types.MaxBid) ([]types.Bid, error)
+	// GetMaxBid returns nil if the user hasn't placed a maximum bid on the lot
+	GetMaxBid(ctx context.Context, auctionLotId, userId int64) (*types.MaxBid, error)
+	// BuyNow places a bid of the lot BinPrice, closes the lot and records the user as its winner at once.
+	// It returns ErrBuyNowUnavailable when the lot can't be bought, or the same errors as PlaceBid
+	BuyNow(ctx context.Context, auctionLotId, userId int64) (*types.AuctionLotWinner, error)
+	// GetAuctionLotWinner returns nil if the lot has no winner
+	GetAuctionLotWinner(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error)
+	// CloseAuctionLot closes the lot with the outcome of types.AuctionLot.ClosingOutcome.
+	// The winner is returned only when the lot is sold
+	CloseAuctionLot(ctx context.Context, auctionLotId int64) (*types.AuctionLotWinner, error)
+	// CloseEndedAuctionLot is CloseAuctionLot for the scheduler. It returns ErrAuctionLotNotEnded
+	// when the lot end time is after now, e.g. because a late bid has extended it
+	CloseEndedAuctionLot(ctx context.Context, auctionLotId int64, now time.Time) (*types.AuctionLotWinner, error)
+	// OfferAuctionLotToHighestBidder lets the highest bidder buy the lot that has closed with the reserve not met
+	OfferAuctionLotToHighestBidder(ctx context.Context, auctionLotId int64) error
+	// RespondToAuctionLotOffer sells the offered lot to the highest bidder if they accept the offer.
+	// It returns ErrNotHighestBidder if the user is not the one the lot was offered to
+	RespondToAuctionLotOffer(ctx context.Context, auctionLotId, userId int64, accept bool) (*types.AuctionLotWinner, error)
+	GetBidsByLotID(ctx context.Context, auctionLotId int64) ([]types.Bid, error)
+	// GetHighestBid returns nil if the lot doesn't have any bids yet
+	GetHighestBid(ctx context.Context, auctionLotId int64) (*types.Bid, error)
+	// GetRecentBids returns the latest bids on every lot
+	GetRecentBids(ctx context.Context, limit int) ([]types.Bid, error)
+
+	// OpenDueAuctions starts the scheduled auctions whose start time has come and returns their ids.
+	// It leaves out the archived auctions, so the scheduler never brings back an auction taken off before its start
+	OpenDueAuctions(ctx context.Context, now time.Time) ([]int64, error)
+	// GetDueAuctionLotIDs returns the open lots whose own or auction end time has come
+	GetDueAuctionLotIDs(ctx context.Context, now time.Time) ([]int64, error)
+	// CloseDueAuctions closes the ended auctions that have no open lots left and returns their ids
+	CloseDueAuctions(ctx context.Context, now time.Time) ([]int64, error)
+	// GetNextScheduledTransition returns the closest auction start or auction lot end after now.
+	// The time is invalid when nothing is scheduled
+	GetNextScheduledTransition(ctx context.Context, now time.Time) (sql.NullTime, error)
+
+	GetCategories(ctx context.Context) ([]types.Category, error)
+
+	SaveAuditEntry(ctx context.Context, entry *types.AuditEntry) (*types.AuditEntry, error)
+	// GetAuditEntries returns the latest entries first
+	GetAuditEntries(ctx context.Context, limit int) ([]types.AuditEntry, error)
+
+	// SeedData fills the empty storage with the dataset, keeping its ids. It returns ErrSeedNotEmpty when there are users already
+	SeedData(ctx context.Context, data *seed.Dataset) error
+
+	// Ping checks that the storage can serve queries
+	Ping(ctx context.Context) error
+}
+
+// isMaxBidHighEnough checks that the max bid raises the user's previous maximum
+// and either outbids the current price or, for the current leader, exceeds it
+func isMaxBidHighEnough(lot *proxybid.Lot, maxBid *types.MaxBid) bool {
+	for _, m := range lot.MaxBids {
+		if m.UserID == maxBid.UserID && maxBid.Amount.LessThanOrEqual(m.Amount) {
+			return false
+		}
+	}
+
+	if lot.HighestBid != nil && lot.HighestBid.UserID == maxBid.UserID {
+		return maxBid.Amount.GreaterThan(lot.HighestBid.Value)
+	}
+
+	return maxBid.Amount.GreaterThanOrEqual(lot.Increments.NextMinimalBid(lot.MinimalBid, lot.HighestBid))
+}
+
+// replaceMaxBid replaces the user's max bid with the new one
+func replaceMaxBid(maxBids []types.MaxBid, maxBid *types.MaxBid) []types.MaxBid {
+	res := make([]types.MaxBid, 0, len(maxBids)+1)
+
+	for _, m := range maxBids {
+		if m.UserID != maxBid.UserID {
+			res = append(res, m)
+		}
+	}
+
+	return append(res, *types.CopyMaxBid(maxBid))
 }
@@ -0,0 +1,896 @@
+// Package storagetest is the behaviour every storage.Storage has to show.
+// Each backend runs the same suite from its tests, so the backends can be swapped for one another
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/artemsmotritel/oktion/seed"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/shopspring/decimal"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Run runs the suite against the storages newStore makes. Every test gets a storage of its own,
+// which has to be empty, and the tests don't run in parallel, so one database can be emptied for each of them
+func Run(t *testing.T, newStore func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store storage.Storage)
+	}{
+		{"Users", testUsers},
+		{"UserDeletion", testUserDeletion},
+		{"Auctions", testAuctions},
+		{"AuctionDeletion", testAuctionDeletion},
+		{"SoftDelete", testSoftDelete},
+		{"AuctionActiveStatus", testAuctionActiveStatus},
+		{"ArchivedBeforeStart", testArchivedBeforeStart},
+		{"Moderation", testModeration},
+		{"AuctionLots", testAuctionLots},
+		{"AuctionLotUpdateOnceBid", testAuctionLotUpdateOnceBid},
+		{"AuctionLotActiveStatus", testAuctionLotActiveStatus},
+		{"AuctionLotCount", testAuctionLotCount},
+		{"AuctionLotCategory", testAuctionLotCategory},
+		{"NotFound", testNotFound},
+		{"SeedData", testSeedData},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newStore(t))
+		})
+	}
+}
+
+func saveUser(t *testing.T, store storage.Storage, email string) *types.User {
+	t.Helper()
+
+	user, err := store.SaveUser(context.Background(), &types.User{
+		FullName: "Test User",
+		Email:    email,
+		Password: "hash",
+		Roles:    types.DefaultRoles,
+	})
+	if err != nil {
+		t.Fatalf("save user %s: %v", email, err)
+	}
+
+	return user
+}
+
+func saveAuction(t *testing.T, store storage.Storage, ownerId int64, name string) *types.Auction {
+	t.Helper()
+
+	auction, err := store.SaveAuction(context.Background(), &types.Auction{
+		OwnerId:     ownerId,
+		Name:        name,
+		Description: "The description of " + name,
+		IsActive:    true,
+	})
+	if err != nil {
+		t.Fatalf("save auction %s: %v", name, err)
+	}
+
+	return auction
+}
+
+func saveAuctionLot(t *testing.T, store storage.Storage, auctionId int64, name string) *types.AuctionLot {
+	t.Helper()
+
+	lot, err := store.SaveAuctionLot(context.Background(), &types.AuctionLot{
+		AuctionID:    auctionId,
+		Name:         name,
+		Description:  "The description of " + name,
+		IsActive:     true,
+		MinimalBid:   decimal.NewFromInt(10),
+		ReservePrice: decimal.NewFromInt(50),
+		BinPrice:     decimal.NewFromInt(100),
+	})
+	if err != nil {
+		t.Fatalf("save auction lot %s: %v", name, err)
+	}
+
+	return lot
+}
+
+// seedCategories gets the categories in through the seed, as the storage has no other way to add them
+func seedCategories(t *testing.T, store storage.Storage) []types.Category {
+	t.Helper()
+	ctx := context.Background()
+
+	config := seed.DefaultConfig
+	config.Users = 1
+	config.Auctions = 0
+	config.Now = time.Now()
+
+	data, err := seed.Generate(config)
+	if err != nil {
+		t.Fatalf("generate seed data: %v", err)
+	}
+	if err = store.SeedData(ctx, data); err != nil {
+		t.Fatalf("seed data: %v", err)
+	}
+
+	categories, err := store.GetCategories(ctx)
+	if err != nil {
+		t.Fatalf("get categories: %v", err)
+	}
+	if len(categories) < 2 {
+		t.Fatalf("got %d categories after the seed, want at least 2", len(categories))
+	}
+
+	return categories
+}
+
+func testUsers(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	first := saveUser(t, store, "first@example.com")
+	second := saveUser(t, store, "second@example.com")
+	if first.ID == 0 || second.ID == 0 || first.ID == second.ID {
+		t.Fatalf("got user ids %d and %d, want distinct non-zero ids", first.ID, second.ID)
+	}
+	if first.Password != "" {
+		t.Errorf("SaveUser returned the password hash")
+	}
+
+	user, err := store.GetUserByID(ctx, first.ID)
+	if err != nil || user == nil {
+		t.Fatalf("get user by id: %v, %v", user, err)
+	}
+	if user.Email != "first@example.com" || user.FullName != "Test User" || user.Password != "hash" || !slices.Equal(user.Roles, types.DefaultRoles) {
+		t.Errorf("got user %+v, want the saved one", user)
+	}
+
+	user, err = store.GetUserByEmail(ctx, "second@example.com")
+	if err != nil || user == nil || user.ID != second.ID {
+		t.Fatalf("get user by email: %v, %v", user, err)
+	}
+
+	if _, err = store.SaveUser(ctx, &types.User{Email: "first@example.com", Password: "hash"}); err == nil {
+		t.Errorf("saved a second user with a taken email")
+	}
+
+	updated, err := store.UpdateUser(ctx, first.ID, types.UserUpdateRequest{FullName: "Renamed User", Phone: "+380000000000"})
+	if err != nil || updated == nil {
+		t.Fatalf("update user: %v, %v", updated, err)
+	}
+	if updated.FullName != "Renamed User" || updated.Phone != "+380000000000" || updated.Email != "first@example.com" {
+		t.Errorf("got updated user %+v, want the new name and phone and the same email", updated)
+	}
+
+	if err = store.SetUserRoles(ctx, first.ID, types.AllRoles); err != nil {
+		t.Fatalf("set user roles: %v", err)
+	}
+	user, _ = store.GetUserByID(ctx, first.ID)
+	if !slices.Equal(user.Roles, types.AllRoles) {
+		t.Errorf("got roles %v, want %v", user.Roles, types.AllRoles)
+	}
+
+	suspendedAt := sql.NullTime{Time: time.Now(), Valid: true}
+	if err = store.SetUserSuspended(ctx, second.ID, suspendedAt); err != nil {
+		t.Fatalf("suspend user: %v", err)
+	}
+	user, _ = store.GetUserByID(ctx, second.ID)
+	if !user.IsSuspended() {
+		t.Errorf("the suspended user is not suspended")
+	}
+	if err = store.SetUserSuspended(ctx, second.ID, sql.NullTime{}); err != nil {
+		t.Fatalf("lift user suspension: %v", err)
+	}
+	user, _ = store.GetUserByID(ctx, second.ID)
+	if user.IsSuspended() {
+		t.Errorf("the user is still suspended after the suspension is lifted")
+	}
+
+	count, err := store.CountUsers(ctx)
+	if err != nil || count != 2 {
+		t.Errorf("got %d users, %v, want 2", count, err)
+	}
+
+	page, err := store.GetUsers(ctx, 1, 1)
+	if err != nil || len(page) != 1 || page[0].ID != second.ID {
+		t.Errorf("got the users page %+v, %v, want only the second user", page, err)
+	}
+	page, err = store.GetUsers(ctx, 10, 2)
+	if err != nil || len(page) != 0 {
+		t.Errorf("got the users page %+v, %v, want an empty one", page, err)
+	}
+}
+
+func testUserDeletion(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	kept := saveUser(t, store, "kept@example.com")
+	deleted := saveUser(t, store, "deleted@example.com")
+
+	if err := store.DeleteUser(ctx, deleted.ID); err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+
+	if user, err := store.GetUserByID(ctx, deleted.ID); err != nil || user != nil {
+		t.Errorf("got the deleted user %v, %v, want nil", user, err)
+	}
+	if user, err := store.GetUserByEmail(ctx, "deleted@example.com"); err != nil || user != nil {
+		t.Errorf("got the deleted user by email %v, %v, want nil", user, err)
+	}
+	if user, err := store.GetUserByID(ctx, kept.ID); err != nil || user == nil {
+		t.Errorf("lost the other user: %v", err)
+	}
+
+	// the email is free again
+	saveUser(t, store, "deleted@example.com")
+}
+
+func testAuctions(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	other := saveUser(t, store, "other@example.com")
+
+	first := saveAuction(t, store, owner.ID, "First")
+	second := saveAuction(t, store, owner.ID, "Second")
+	third := saveAuction(t, store, other.ID, "Third")
+	if first.ID == 0 || first.ID == second.ID || second.ID == third.ID {
+		t.Fatalf("got auction ids %d, %d and %d, want distinct non-zero ids", first.ID, second.ID, third.ID)
+	}
+	if first.CreatedAt.IsZero() {
+		t.Errorf("the saved auction has no creation time")
+	}
+
+	auction, err := store.GetAuctionByID(ctx, first.ID)
+	if err != nil || auction == nil {
+		t.Fatalf("get auction by id: %v, %v", auction, err)
+	}
+	if auction.Name != "First" || auction.Description != "The description of First" || auction.OwnerId != owner.ID || !auction.IsActive || auction.DeletedAt.Valid {
+		t.Errorf("got auction %+v, want the saved one", auction)
+	}
+
+	ownerId, err := store.GetOwnerIDByAuctionID(ctx, third.ID)
+	if err != nil || ownerId != other.ID {
+		t.Errorf("got owner id %d, %v, want %d", ownerId, err, other.ID)
+	}
+
+	owned, err := store.GetAuctionsByOwnerId(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("get auctions by owner id: %v", err)
+	}
+	if ids := auctionIds(owned); !sameIds(ids, first.ID, second.ID) {
+		t.Errorf("got the owner auctions %v, want %d and %d", ids, first.ID, second.ID)
+	}
+
+	all, err := store.GetAuctions(ctx)
+	if err != nil {
+		t.Fatalf("get auctions: %v", err)
+	}
+	if ids := auctionIds(all); !slices.Equal(ids, []int64{first.ID, second.ID, third.ID}) {
+		t.Errorf("got the auctions %v, want %d, %d and %d in this order", ids, first.ID, second.ID, third.ID)
+	}
+
+	startsAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	endsAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	updated, err := store.UpdateAuction(ctx, types.AuctionUpdateRequest{
+		ID:          first.ID,
+		Name:        "First renamed",
+		Description: "A new description",
+		IsPrivate:   true,
+		StartsAt:    sql.NullTime{Time: startsAt, Valid: true},
+		EndsAt:      sql.NullTime{Time: endsAt, Valid: true},
+	})
+	if err != nil || updated == nil {
+		t.Fatalf("update auction: %v, %v", updated, err)
+	}
+
+	auction, _ = store.GetAuctionByID(ctx, first.ID)
+	if auction.Name != "First renamed" || auction.Description != "A new description" || !auction.IsPrivate || auction.OwnerId != owner.ID {
+		t.Errorf("got auction %+v after the update, want the new values", auction)
+	}
+	if !auction.StartsAt.Valid || !auction.StartsAt.Time.Equal(startsAt) || !auction.EndsAt.Valid || !auction.EndsAt.Time.Equal(endsAt) {
+		t.Errorf("got the schedule %v - %v, want %v - %v", auction.StartsAt, auction.EndsAt, startsAt, endsAt)
+	}
+	if !auction.IsActive {
+		t.Errorf("the update has taken off the auction that has already started")
+	}
+	if opened, err := store.OpenDueAuctions(ctx, time.Now()); err != nil || !slices.Contains(opened, first.ID) {
+		t.Fatalf("got the opened auctions %v, %v, want %d among them", opened, err, first.ID)
+	}
+
+	updated, err = store.UpdateAuction(ctx, types.AuctionUpdateRequest{
+		ID:       first.ID,
+		Name:     "First renamed",
+		StartsAt: sql.NullTime{Time: endsAt, Valid: true},
+		EndsAt:   sql.NullTime{Time: endsAt.Add(time.Hour), Valid: true},
+	})
+	if err != nil || updated == nil {
+		t.Fatalf("update auction: %v, %v", updated, err)
+	}
+	if !updated.IsActive || updated.IsStarted {
+		t.Errorf("got the auction active %t and started %t after its start has moved into the future, want active but not started", updated.IsActive, updated.IsStarted)
+	}
+}
+
+// testArchivedBeforeStart checks that the scheduler doesn't open an auction archived while it was waiting for its start
+func testArchivedBeforeStart(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	startsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	pending := make([]*types.Auction, 2)
+	for i, name := range []string{"Archived", "Pending"} {
+		auction, err := store.SaveAuction(ctx, &types.Auction{
+			OwnerId:  owner.ID,
+			Name:     name,
+			IsActive: true,
+			StartsAt: sql.NullTime{Time: startsAt, Valid: true},
+		})
+		if err != nil {
+			t.Fatalf("save auction %s: %v", name, err)
+		}
+		pending[i] = auction
+	}
+	archived, kept := pending[0], pending[1]
+
+	if err := store.SetAuctionActiveStatus(ctx, archived.ID, false); err != nil {
+		t.Fatalf("archive the auction: %v", err)
+	}
+
+	opened, err := store.OpenDueAuctions(ctx, startsAt.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("open due auctions: %v", err)
+	}
+	if !sameIds(opened, kept.ID) {
+		t.Errorf("got the opened auctions %v, want only %d", opened, kept.ID)
+	}
+
+	if got, _ := store.GetAuctionByID(ctx, archived.ID); got.IsActive || got.IsStarted {
+		t.Errorf("got the archived auction active %t and started %t after its start, want neither", got.IsActive, got.IsStarted)
+	}
+	if got, _ := store.GetAuctionByID(ctx, kept.ID); !got.IsActive || !got.IsStarted {
+		t.Errorf("got the pending auction active %t and started %t after its start, want both", got.IsActive, got.IsStarted)
+	}
+
+	// reinstated, it opens on the next run
+	if err = store.SetAuctionActiveStatus(ctx, archived.ID, true); err != nil {
+		t.Fatalf("reinstate the auction: %v", err)
+	}
+	if opened, err = store.OpenDueAuctions(ctx, startsAt.Add(2*time.Minute)); err != nil || !sameIds(opened, archived.ID) {
+		t.Errorf("got the opened auctions %v, %v after the reinstatement, want only %d", opened, err, archived.ID)
+	}
+}
+
+func testAuctionDeletion(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	deleted := saveAuction(t, store, owner.ID, "Deleted")
+	kept := saveAuction(t, store, owner.ID, "Kept")
+	deletedLot := saveAuctionLot(t, store, deleted.ID, "Deleted lot")
+	keptLot := saveAuctionLot(t, store, kept.ID, "Kept lot")
+
+	if err := store.DeleteAuction(ctx, deleted.ID); err != nil {
+		t.Fatalf("delete auction: %v", err)
+	}
+
+	if auction, err := store.GetAuctionByID(ctx, deleted.ID); err != nil || auction != nil {
+		t.Errorf("got the deleted auction %v, %v, want nil", auction, err)
+	}
+	if lot, err := store.GetAuctionLotByID(ctx, deletedLot.ID); err != nil || lot != nil {
+		t.Errorf("got the lot of the deleted auction %v, %v, want nil", lot, err)
+	}
+	if count, err := store.GetAuctionLotCount(ctx, deleted.ID); err != nil || count != 0 {
+		t.Errorf("got %d lots of the deleted auction, %v, want 0", count, err)
+	}
+
+	owned, err := store.GetAuctionsByOwnerId(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("get auctions by owner id: %v", err)
+	}
+	if ids := auctionIds(owned); !sameIds(ids, kept.ID) {
+		t.Errorf("got the owner auctions %v, want only %d", ids, kept.ID)
+	}
+	if lot, err := store.GetAuctionLotByID(ctx, keptLot.ID); err != nil || lot == nil {
+		t.Errorf("lost the lot of the other auction: %v", err)
+	}
+
+	// deleting it again is not an error
+	if err := store.DeleteAuction(ctx, deleted.ID); err != nil {
+		t.Errorf("delete the deleted auction: %v", err)
+	}
+}
+
+// testSoftDelete checks that a deleted auction keeps its rows, with deleted_at set, but is gone from every read,
+// listing and schedule, while the bids on its lots stay in the history
+func testSoftDelete(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	bidder := saveUser(t, store, "bidder@example.com")
+	endsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	deleted, err := store.SaveAuction(ctx, &types.Auction{
+		OwnerId:  owner.ID,
+		Name:     "Deleted",
+		IsActive: true,
+		EndsAt:   sql.NullTime{Time: endsAt, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("save auction: %v", err)
+	}
+	kept := saveAuction(t, store, owner.ID, "Kept")
+	deletedLot := saveAuctionLot(t, store, deleted.ID, "Deleted lot")
+
+	bid, err := store.PlaceBid(ctx, &types.Bid{AuctionLotID: deletedLot.ID, UserID: bidder.ID, Value: decimal.NewFromInt(10)})
+	if err != nil {
+		t.Fatalf("place bid: %v", err)
+	}
+	if next, err := store.GetNextScheduledTransition(ctx, time.Now()); err != nil || !next.Valid {
+		t.Fatalf("got no scheduled transition before the deletion: %v, %v", next, err)
+	}
+
+	if err = store.DeleteAuction(ctx, deleted.ID); err != nil {
+		t.Fatalf("delete auction: %v", err)
+	}
+
+	if auction, err := store.GetAuctionByID(ctx, deleted.ID); err != nil || auction != nil {
+		t.Errorf("got the deleted auction %v, %v, want nil", auction, err)
+	}
+	if ownerId, err := store.GetOwnerIDByAuctionID(ctx, deleted.ID); err != nil || ownerId != 0 {
+		t.Errorf("got the owner %d of the deleted auction, %v, want 0", ownerId, err)
+	}
+
+	listings := []struct {
+		name string
+		list func() ([]types.Auction, error)
+	}{
+		{"get auctions", func() ([]types.Auction, error) { return store.GetAuctions(ctx) }},
+		{"get auctions by owner id", func() ([]types.Auction, error) { return store.GetAuctionsByOwnerId(ctx, owner.ID) }},
+		{"get recent auctions", func() ([]types.Auction, error) { return store.GetRecentAuctions(ctx, 10) }},
+	}
+	for _, listing := range listings {
+		auctions, err := listing.list()
+		if err != nil {
+			t.Fatalf("%s: %v", listing.name, err)
+		}
+		if ids := auctionIds(auctions); !sameIds(ids, kept.ID) {
+			t.Errorf("%s: got %v, want only %d", listing.name, ids, kept.ID)
+		}
+	}
+
+	if lot, err := store.GetAuctionLotByID(ctx, deletedLot.ID); err != nil || lot != nil {
+		t.Errorf("got the lot of the deleted auction %v, %v, want nil", lot, err)
+	}
+	if lots, err := store.GetAuctionLotsByAuctionID(ctx, deleted.ID); err != nil || len(lots) != 0 {
+		t.Errorf("got the lots of the deleted auction %v, %v, want none", lots, err)
+	}
+	if count, err := store.GetAuctionLotCount(ctx, deleted.ID); err != nil || count != 0 {
+		t.Errorf("got %d lots of the deleted auction, %v, want 0", count, err)
+	}
+
+	if _, err = store.PlaceBid(ctx, &types.Bid{AuctionLotID: deletedLot.ID, UserID: bidder.ID, Value: decimal.NewFromInt(20)}); !errors.Is(err, storage.ErrLotNotAcceptingBids) {
+		t.Errorf("got %v placing a bid on the deleted lot, want ErrLotNotAcceptingBids", err)
+	}
+	if bids, err := store.GetBidsByLotID(ctx, deletedLot.ID); err != nil || len(bids) != 1 || bids[0].ID != bid.ID {
+		t.Errorf("got the bids %v, %v on the deleted lot, want only the bid placed before the deletion", bids, err)
+	}
+
+	if due, err := store.GetDueAuctionLotIDs(ctx, endsAt.Add(time.Hour)); err != nil || slices.Contains(due, deletedLot.ID) {
+		t.Errorf("got the deleted lot among the due lots %v, %v", due, err)
+	}
+	if next, err := store.GetNextScheduledTransition(ctx, time.Now()); err != nil || next.Valid {
+		t.Errorf("got the scheduled transition %v, %v of the deleted auction", next, err)
+	}
+}
+
+func testAuctionActiveStatus(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	other := saveAuction(t, store, owner.ID, "Other")
+
+	for _, isActive := range []bool{false, true, false} {
+		if err := store.SetAuctionActiveStatus(ctx, auction.ID, isActive); err != nil {
+			t.Fatalf("set auction active status %t: %v", isActive, err)
+		}
+
+		got, _ := store.GetAuctionByID(ctx, auction.ID)
+		if got.IsActive != isActive {
+			t.Errorf("got the auction active %t, want %t", got.IsActive, isActive)
+		}
+	}
+
+	if got, _ := store.GetAuctionByID(ctx, other.ID); !got.IsActive {
+		t.Errorf("the status of the other auction has changed")
+	}
+}
+
+// testModeration checks that the owner can't reinstate an auction or a lot an admin has taken off until the admin does
+func testModeration(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	lot := saveAuctionLot(t, store, auction.ID, "Lot")
+	moderatedAt := sql.NullTime{Time: time.Now().Truncate(time.Second), Valid: true}
+
+	if err := store.SetAuctionModerated(ctx, auction.ID, moderatedAt); err != nil {
+		t.Fatalf("set auction moderated: %v", err)
+	}
+	if err := store.SetAuctionLotModerated(ctx, lot.ID, moderatedAt); err != nil {
+		t.Fatalf("set auction lot moderated: %v", err)
+	}
+
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); got.IsActive || !got.IsModerated() || !got.ModeratedAt.Time.Equal(moderatedAt.Time) {
+		t.Errorf("got the auction active %t and moderated at %v, want archived and moderated at %v", got.IsActive, got.ModeratedAt, moderatedAt.Time)
+	}
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); got.IsActive || !got.IsModerated() {
+		t.Errorf("got the lot active %t and moderated at %v, want deactivated and moderated", got.IsActive, got.ModeratedAt)
+	}
+
+	if err := store.SetAuctionActiveStatus(ctx, auction.ID, true); !errors.Is(err, storage.ErrModerated) {
+		t.Errorf("got %v reinstating the moderated auction, want ErrModerated", err)
+	}
+	if err := store.SetAuctionLotActiveStatus(ctx, lot.ID, true); !errors.Is(err, storage.ErrModerated) {
+		t.Errorf("got %v reinstating the moderated lot, want ErrModerated", err)
+	}
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); got.IsActive {
+		t.Errorf("the owner has reinstated the moderated auction")
+	}
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); got.IsActive {
+		t.Errorf("the owner has reinstated the moderated lot")
+	}
+
+	if err := store.SetAuctionModerated(ctx, auction.ID, sql.NullTime{}); err != nil {
+		t.Fatalf("lift the auction moderation: %v", err)
+	}
+	if err := store.SetAuctionLotModerated(ctx, lot.ID, sql.NullTime{}); err != nil {
+		t.Fatalf("lift the auction lot moderation: %v", err)
+	}
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); !got.IsActive || got.IsModerated() {
+		t.Errorf("got the auction active %t and moderated %t after the admin reinstated it", got.IsActive, got.IsModerated())
+	}
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); !got.IsActive || got.IsModerated() {
+		t.Errorf("got the lot active %t and moderated %t after the admin reinstated it", got.IsActive, got.IsModerated())
+	}
+
+	// the owner is in charge again
+	for _, isActive := range []bool{false, true} {
+		if err := store.SetAuctionActiveStatus(ctx, auction.ID, isActive); err != nil {
+			t.Errorf("set auction active status %t after the moderation: %v", isActive, err)
+		}
+		if err := store.SetAuctionLotActiveStatus(ctx, lot.ID, isActive); err != nil {
+			t.Errorf("set auction lot active status %t after the moderation: %v", isActive, err)
+		}
+	}
+}
+
+func testAuctionLots(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	categories := seedCategories(t, store)
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	other := saveAuction(t, store, owner.ID, "Other")
+
+	first := saveAuctionLot(t, store, auction.ID, "First lot")
+	second := saveAuctionLot(t, store, auction.ID, "Second lot")
+	saveAuctionLot(t, store, other.ID, "Other lot")
+	if first.ID == 0 || first.ID == second.ID {
+		t.Fatalf("got auction lot ids %d and %d, want distinct non-zero ids", first.ID, second.ID)
+	}
+
+	lot, err := store.GetAuctionLotByID(ctx, first.ID)
+	if err != nil || lot == nil {
+		t.Fatalf("get auction lot by id: %v, %v", lot, err)
+	}
+	if lot.AuctionID != auction.ID || lot.Name != "First lot" || !lot.IsActive || lot.IsClosed || lot.DeletedAt.Valid ||
+		!lot.MinimalBid.Equal(decimal.NewFromInt(10)) || !lot.ReservePrice.Equal(decimal.NewFromInt(50)) || !lot.BinPrice.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("got auction lot %+v, want the saved one", lot)
+	}
+
+	lots, err := store.GetAuctionLotsByAuctionID(ctx, auction.ID)
+	if err != nil {
+		t.Fatalf("get auction lots by auction id: %v", err)
+	}
+	ids := make([]int64, len(lots))
+	for i, lot := range lots {
+		ids[i] = lot.ID
+	}
+	if !sameIds(ids, first.ID, second.ID) {
+		t.Errorf("got the auction lots %v, want %d and %d", ids, first.ID, second.ID)
+	}
+
+	startsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	updated, err := store.UpdateAuctionLot(ctx, first.ID, &types.AuctionLotUpdateRequest{
+		Name:         "First lot renamed",
+		Description:  "A new description",
+		CategoryId:   categories[0].ID,
+		MinimalBid:   decimal.NewFromInt(20),
+		ReservePrice: decimal.NewFromInt(60),
+		BinPrice:     decimal.NewFromInt(200),
+		StartsAt:     sql.NullTime{Time: startsAt, Valid: true},
+	})
+	if err != nil || updated == nil {
+		t.Fatalf("update auction lot: %v, %v", updated, err)
+	}
+
+	lot, _ = store.GetAuctionLotByID(ctx, first.ID)
+	if lot.Name != "First lot renamed" || lot.Description != "A new description" || lot.AuctionID != auction.ID ||
+		!lot.MinimalBid.Equal(decimal.NewFromInt(20)) || !lot.ReservePrice.Equal(decimal.NewFromInt(60)) || !lot.BinPrice.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("got auction lot %+v after the update, want the new values", lot)
+	}
+	if !lot.StartsAt.Valid || !lot.StartsAt.Time.Equal(startsAt) || lot.EndsAt.Valid {
+		t.Errorf("got the lot schedule %v - %v, want %v and no end", lot.StartsAt, lot.EndsAt, startsAt)
+	}
+}
+
+// testAuctionLotUpdateOnceBid checks that a lot with bids keeps its prices and never ends earlier than it does
+func testAuctionLotUpdateOnceBid(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	categories := seedCategories(t, store)
+	owner := saveUser(t, store, "owner@example.com")
+	bidder := saveUser(t, store, "bidder@example.com")
+	auctionEndsAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	auction, err := store.SaveAuction(ctx, &types.Auction{
+		OwnerId:  owner.ID,
+		Name:     "Auction",
+		IsActive: true,
+		EndsAt:   sql.NullTime{Time: auctionEndsAt, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("save auction: %v", err)
+	}
+	lot := saveAuctionLot(t, store, auction.ID, "Lot")
+
+	update := func(minimalBid int64, endsAt sql.NullTime) (*types.AuctionLot, error) {
+		return store.UpdateAuctionLot(ctx, lot.ID, &types.AuctionLotUpdateRequest{
+			Name:         "Lot",
+			CategoryId:   categories[0].ID,
+			MinimalBid:   decimal.NewFromInt(minimalBid),
+			ReservePrice: lot.ReservePrice,
+			BinPrice:     lot.BinPrice,
+			EndsAt:       endsAt,
+		})
+	}
+	at := func(d time.Duration) sql.NullTime {
+		return sql.NullTime{Time: auctionEndsAt.Add(d), Valid: true}
+	}
+
+	// the lot runs later than the auction, like it does after a soft close extension
+	if _, err = update(10, at(time.Hour)); err != nil {
+		t.Fatalf("update auction lot: %v", err)
+	}
+	if _, err = store.PlaceBid(ctx, &types.Bid{AuctionLotID: lot.ID, UserID: bidder.ID, Value: decimal.NewFromInt(10)}); err != nil {
+		t.Fatalf("place bid: %v", err)
+	}
+
+	if _, err = update(5, at(time.Hour)); !errors.Is(err, storage.ErrLotPricesLocked) {
+		t.Errorf("got %v changing the minimal bid of a lot with bids, want ErrLotPricesLocked", err)
+	}
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); !got.MinimalBid.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("got the minimal bid %s after the refused update, want 10", got.MinimalBid)
+	}
+
+	endsAtUpdates := []struct {
+		name   string
+		endsAt sql.NullTime
+		want   sql.NullTime
+	}{
+		{"an empty end", sql.NullTime{}, at(time.Hour)},
+		{"an earlier end", at(30 * time.Minute), at(time.Hour)},
+		{"a later end", at(2 * time.Hour), at(2 * time.Hour)},
+	}
+	for _, endsAtUpdate := range endsAtUpdates {
+		updated, err := update(10, endsAtUpdate.endsAt)
+		if err != nil {
+			t.Fatalf("update auction lot with %s: %v", endsAtUpdate.name, err)
+		}
+		if !updated.EndsAt.Valid || !updated.EndsAt.Time.Equal(endsAtUpdate.want.Time) {
+			t.Errorf("got the lot end %v after the update with %s, want %v", updated.EndsAt, endsAtUpdate.name, endsAtUpdate.want)
+		}
+	}
+}
+
+func testAuctionLotActiveStatus(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	lot := saveAuctionLot(t, store, auction.ID, "Lot")
+	other := saveAuctionLot(t, store, auction.ID, "Other lot")
+
+	for _, isActive := range []bool{false, true, false} {
+		if err := store.SetAuctionLotActiveStatus(ctx, lot.ID, isActive); err != nil {
+			t.Fatalf("set auction lot active status %t: %v", isActive, err)
+		}
+
+		got, _ := store.GetAuctionLotByID(ctx, lot.ID)
+		if got.IsActive != isActive {
+			t.Errorf("got the lot active %t, want %t", got.IsActive, isActive)
+		}
+	}
+
+	if got, _ := store.GetAuctionLotByID(ctx, other.ID); !got.IsActive {
+		t.Errorf("the status of the other lot has changed")
+	}
+	if got, _ := store.GetAuctionByID(ctx, auction.ID); !got.IsActive {
+		t.Errorf("the status of the auction has changed along with its lot")
+	}
+}
+
+func testAuctionLotCount(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	empty := saveAuction(t, store, owner.ID, "Empty")
+
+	for i := 1; i <= 3; i++ {
+		saveAuctionLot(t, store, auction.ID, "Lot")
+
+		if count, err := store.GetAuctionLotCount(ctx, auction.ID); err != nil || count != i {
+			t.Errorf("got %d lots, %v, want %d", count, err, i)
+		}
+	}
+
+	if count, err := store.GetAuctionLotCount(ctx, empty.ID); err != nil || count != 0 {
+		t.Errorf("got %d lots of the empty auction, %v, want 0", count, err)
+	}
+}
+
+func testAuctionLotCategory(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	categories := seedCategories(t, store)
+	owner := saveUser(t, store, "owner@example.com")
+	auction := saveAuction(t, store, owner.ID, "Auction")
+	lot := saveAuctionLot(t, store, auction.ID, "Lot")
+
+	if got, _ := store.GetAuctionLotByID(ctx, lot.ID); got.CategoryId != 0 {
+		t.Errorf("got category %d of the new lot, want none", got.CategoryId)
+	}
+
+	// the first update sets the category, the next ones replace it
+	for _, category := range []types.Category{categories[0], categories[1], categories[0]} {
+		_, err := store.UpdateAuctionLot(ctx, lot.ID, &types.AuctionLotUpdateRequest{
+			Name:       "Lot",
+			CategoryId: category.ID,
+		})
+		if err != nil {
+			t.Fatalf("update auction lot category to %d: %v", category.ID, err)
+		}
+
+		got, _ := store.GetAuctionLotByID(ctx, lot.ID)
+		if got.CategoryId != category.ID {
+			t.Errorf("got category %d, want %d", got.CategoryId, category.ID)
+		}
+
+		lots, _ := store.GetAuctionLotsByAuctionID(ctx, auction.ID)
+		if len(lots) != 1 || lots[0].CategoryId != category.ID {
+			t.Errorf("got the auction lots %+v, want the one lot of category %d", lots, category.ID)
+		}
+	}
+}
+
+func testNotFound(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	const missing = 424242
+
+	if user, err := store.GetUserByID(ctx, missing); err != nil || user != nil {
+		t.Errorf("GetUserByID: got %v, %v, want nil", user, err)
+	}
+	if user, err := store.GetUserByEmail(ctx, "missing@example.com"); err != nil || user != nil {
+		t.Errorf("GetUserByEmail: got %v, %v, want nil", user, err)
+	}
+	if _, err := store.UpdateUser(ctx, missing, types.UserUpdateRequest{FullName: "Nobody"}); err == nil {
+		t.Errorf("UpdateUser: updated a missing user")
+	}
+	if auction, err := store.GetAuctionByID(ctx, missing); err != nil || auction != nil {
+		t.Errorf("GetAuctionByID: got %v, %v, want nil", auction, err)
+	}
+	if ownerId, err := store.GetOwnerIDByAuctionID(ctx, missing); err != nil || ownerId != 0 {
+		t.Errorf("GetOwnerIDByAuctionID: got %d, %v, want 0", ownerId, err)
+	}
+	if auctions, err := store.GetAuctionsByOwnerId(ctx, missing); err != nil || len(auctions) != 0 {
+		t.Errorf("GetAuctionsByOwnerId: got %v, %v, want none", auctions, err)
+	}
+	if lot, err := store.GetAuctionLotByID(ctx, missing); err != nil || lot != nil {
+		t.Errorf("GetAuctionLotByID: got %v, %v, want nil", lot, err)
+	}
+	if lots, err := store.GetAuctionLotsByAuctionID(ctx, missing); err != nil || len(lots) != 0 {
+		t.Errorf("GetAuctionLotsByAuctionID: got %v, %v, want none", lots, err)
+	}
+	if count, err := store.GetAuctionLotCount(ctx, missing); err != nil || count != 0 {
+		t.Errorf("GetAuctionLotCount: got %d, %v, want 0", count, err)
+	}
+	if bid, err := store.GetHighestBid(ctx, missing); err != nil || bid != nil {
+		t.Errorf("GetHighestBid: got %v, %v, want nil", bid, err)
+	}
+	if maxBid, err := store.GetMaxBid(ctx, missing, missing); err != nil || maxBid != nil {
+		t.Errorf("GetMaxBid: got %v, %v, want nil", maxBid, err)
+	}
+	if winner, err := store.GetAuctionLotWinner(ctx, missing); err != nil || winner != nil {
+		t.Errorf("GetAuctionLotWinner: got %v, %v, want nil", winner, err)
+	}
+	if token, err := store.GetPasswordResetToken(ctx, "missing"); err != nil || token != nil {
+		t.Errorf("GetPasswordResetToken: got %v, %v, want nil", token, err)
+	}
+	if twoFactor, err := store.GetTwoFactor(ctx, missing); err != nil || twoFactor != nil {
+		t.Errorf("GetTwoFactor: got %v, %v, want nil", twoFactor, err)
+	}
+	if challenge, err := store.GetLoginChallenge(ctx, "missing"); err != nil || challenge != nil {
+		t.Errorf("GetLoginChallenge: got %v, %v, want nil", challenge, err)
+	}
+	if token, err := store.GetAPITokenByHash(ctx, "missing"); err != nil || token != nil {
+		t.Errorf("GetAPITokenByHash: got %v, %v, want nil", token, err)
+	}
+	if user, err := store.GetUserByExternalIdentity(ctx, "https://issuer.example.com", "missing"); err != nil || user != nil {
+		t.Errorf("GetUserByExternalIdentity: got %v, %v, want nil", user, err)
+	}
+}
+
+func testSeedData(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	config := seed.DefaultConfig
+	config.Users = 5
+	config.Auctions = 3
+	config.LotsPerAuction = 2
+	config.Now = time.Now()
+
+	data, err := seed.Generate(config)
+	if err != nil {
+		t.Fatalf("generate seed data: %v", err)
+	}
+	if err = store.SeedData(ctx, data); err != nil {
+		t.Fatalf("seed data: %v", err)
+	}
+
+	if count, err := store.CountUsers(ctx); err != nil || count != int64(config.Users) {
+		t.Errorf("got %d users, %v, want %d", count, err, config.Users)
+	}
+	admin, err := store.GetUserByEmail(ctx, seed.AdminEmail)
+	if err != nil || admin == nil || !admin.IsAdmin() {
+		t.Errorf("got the seeded admin %v, %v, want an admin", admin, err)
+	}
+	if auctions, err := store.GetAuctions(ctx); err != nil || len(auctions) != config.Auctions {
+		t.Errorf("got %d auctions, %v, want %d", len(auctions), err, config.Auctions)
+	}
+	for _, lot := range data.AuctionLots {
+		got, err := store.GetAuctionLotByID(ctx, lot.ID)
+		if err != nil || got == nil || got.AuctionID != lot.AuctionID || got.CategoryId != lot.CategoryId {
+			t.Errorf("got the seeded lot %+v, %v, want %+v", got, err, lot)
+		}
+	}
+
+	// the ids go on after the seeded ones
+	user := saveUser(t, store, "after-seed@example.com")
+	if user.ID <= int64(config.Users) {
+		t.Errorf("got user id %d after the seed, want more than %d", user.ID, config.Users)
+	}
+
+	if err = store.SeedData(ctx, data); !errors.Is(err, storage.ErrSeedNotEmpty) {
+		t.Errorf("got %v seeding the storage again, want ErrSeedNotEmpty", err)
+	}
+}
+
+func auctionIds(auctions []types.Auction) []int64 {
+	ids := make([]int64, len(auctions))
+	for i, auction := range auctions {
+		ids[i] = auction.ID
+	}
+	return ids
+}
+
+// sameIds reports whether ids holds exactly the want ids in any order
+func sameIds(ids []int64, want ...int64) bool {
+	ids = slices.Clone(ids)
+	want = slices.Clone(want)
+	slices.Sort(ids)
+	slices.Sort(want)
+	return slices.Equal(ids, want)
+}
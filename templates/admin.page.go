@@ -0,0 +1,109 @@
+package templates
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+)
+
+type AdminConsole struct {
+	RecentAuctions []types.Auction
+	RecentBids     []types.Bid
+	AuditEntries   []types.AuditEntry
+}
+
+type AdminPageHandler struct {
+	console *AdminConsole
+}
+
+type AdminAuctionPageHandler struct {
+	auction *types.Auction
+	lots    []types.AuctionLot
+}
+
+func NewAdminPageHandler(console *AdminConsole) *AdminPageHandler {
+	return &AdminPageHandler{
+		console: console,
+	}
+}
+
+func NewAdminAuctionPageHandler(auction *types.Auction, lots []types.AuctionLot) *AdminAuctionPageHandler {
+	return &AdminAuctionPageHandler{
+		auction: auction,
+		lots:    lots,
+	}
+}
+
+func (h *AdminPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	handler := templ.Handler(newAdminPage(re.Context(), adminPage(h.console)))
+	handler.ServeHTTP(w, re)
+}
+
+func (h *AdminAuctionPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	handler := templ.Handler(newAdminPage(re.Context(), adminAuctionPage(h.auction, h.lots)))
+	handler.ServeHTTP(w, re)
+}
+
+func newAdminPage(ctx context.Context, page templ.Component) templ.Component {
+	hxBoosted, err := utils.ExtractValueFromContext[bool](ctx, "hxBoosted")
+	if err != nil {
+		hxBoosted = false
+	}
+
+	if hxBoosted {
+		return page
+	}
+
+	isAuthorized, err := utils.ExtractValueFromContext[bool](ctx, "isAuthorized")
+	if err != nil {
+		isAuthorized = false
+	}
+
+	builder := NewHTMLPageBuilder(root)
+	builder.AppendComponent(mainHeader(isAuthorized))
+	builder.AppendComponent(page)
+	builder.AppendComponent(mainFooter())
+
+	return builder.Build()
+}
+
+func NewAdminUserResultsHandler(users []types.User) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: adminUserResults(users),
+	}
+}
+
+func NewAdminUserRowHandler(user *types.User) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: adminUserRow(user),
+	}
+}
+
+func NewAdminAuctionRowHandler(auction *types.Auction) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: adminAuctionRow(auction),
+	}
+}
+
+func NewAdminAuctionLotRowHandler(lot *types.AuctionLot) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: adminAuctionLotRow(lot),
+	}
+}
+
+func auditActionLabel(action types.AuditAction) string {
+	switch action {
+	case types.AuditUserSuspended:
+		return "Suspended user"
+	case types.AuditUserUnsuspended:
+		return "Unsuspended user"
+	case types.AuditAuctionArchived:
+		return "Archived auction"
+	case types.AuditAuctionLotDeactivated:
+		return "Deactivated lot"
+	default:
+		return string(action)
+	}
+}
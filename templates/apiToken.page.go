@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"database/sql"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"strings"
+)
+
+var scopeDescriptions = map[types.Scope]string{
+	types.AuctionsWriteScope: "Create and manage your auctions and lots",
+	types.BidsWriteScope:     "Place bids and answer offers",
+}
+
+var apiTokenLifetimeOptions = []struct {
+	value string
+	name  string
+}{
+	{value: "30", name: "In 30 days"},
+	{value: "90", name: "In 90 days"},
+	{value: "365", name: "In a year"},
+	{value: "never", name: "Never"},
+}
+
+func formatScopes(scopes []types.Scope) string {
+	if len(scopes) == 0 {
+		return "Read only"
+	}
+
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ", ")
+}
+
+func formatAPITokenTime(t sql.NullTime, fallback string) string {
+	if !t.Valid {
+		return fallback
+	}
+	return t.Time.Format("02 Jan 2006")
+}
+
+// NewAPITokensHandler shows newToken once right after it is created, pass "" otherwise
+func NewAPITokensHandler(tokens []types.APIToken, newToken string) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: apiTokens(tokens, newToken, map[string]string{"expires-in": "90"}, make(map[string]string)),
+	}
+}
+
+func NewAPITokensErrorBadRequestHandler(tokens []types.APIToken, values, errors map[string]string) *utils.TemplateHandler {
+	if values == nil {
+		values = make(map[string]string)
+	}
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: apiTokens(tokens, "", values, errors),
+	}
+}
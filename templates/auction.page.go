@@ -6,6 +6,8 @@ import (
 	"github.com/artemsmotritel/oktion/types"
 	"github.com/artemsmotritel/oktion/utils"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type CreateAuctionPageHandler struct {
@@ -136,3 +138,47 @@ func NewAuctionEditFormErrorBadRequestHandler(auction *types.Auction, errors map
 		Template: createAuctionForm(false, auction, errors),
 	}
 }
+
+func NewBidIncrementsFormHandler(auctionId int64, increments types.BidIncrements) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: bidIncrementsForm(auctionId, increments, nil),
+	}
+}
+
+func NewBidIncrementsFormErrorBadRequestHandler(auctionId int64, increments types.BidIncrements, errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: bidIncrementsForm(auctionId, increments, errors),
+	}
+}
+
+func NewManagerTwoFactorFormHandler(auctionId int64, required bool) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: managerTwoFactorForm(auctionId, required),
+	}
+}
+
+func NewSoftCloseFormHandler(auctionId int64, window time.Duration) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: softCloseForm(auctionId, window, nil, nil),
+	}
+}
+
+func NewSoftCloseFormErrorBadRequestHandler(auctionId int64, window time.Duration, values, errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: softCloseForm(auctionId, window, values, errors),
+	}
+}
+
+// formatSoftCloseMinutes shows the window in minutes, with zero being shown as an empty input
+func formatSoftCloseMinutes(window time.Duration) string {
+	if window <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(window.Minutes(), 'f', -1, 64)
+}
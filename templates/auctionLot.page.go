@@ -5,7 +5,10 @@ import (
 	"github.com/a-h/templ"
 	"github.com/artemsmotritel/oktion/types"
 	"github.com/artemsmotritel/oktion/utils"
+	"github.com/shopspring/decimal"
+	"io"
 	"net/http"
+	"time"
 )
 
 func NewAuctionLotListItemHandler(auctionLot *types.AuctionLot) *utils.TemplateHandler {
@@ -68,3 +71,127 @@ func (a *AuctionLotEditPageHandler) newAuctionLotEditPage(ctx context.Context) t
 
 	return builder.Build()
 }
+
+// AuctionLotBidding is everything the auction lot page needs to render the bidding section
+type AuctionLotBidding struct {
+	Auction    *types.Auction
+	Lot        *types.AuctionLot
+	HighestBid *types.Bid
+	Bids       []types.Bid
+	ViewerID   int64
+	// ViewerMaxBid is the viewer's hidden maximum, nil if there is none
+	ViewerMaxBid *types.MaxBid
+	// Winner is nil until the lot is closed with a winner
+	Winner *types.AuctionLotWinner
+}
+
+func (b *AuctionLotBidding) IsOwnLot() bool {
+	return b.ViewerID != 0 && b.ViewerID == b.Auction.OwnerId
+}
+
+func (b *AuctionLotBidding) NextMinimalBid() decimal.Decimal {
+	return b.Auction.BidIncrements.NextMinimalBid(b.Lot.MinimalBid, b.HighestBid)
+}
+
+func (b *AuctionLotBidding) AcceptsBids() bool {
+	return b.Auction.IsActive && b.Lot.IsActive && !b.Lot.IsClosed && !b.Auction.IsClosed && !b.Auction.DeletedAt.Valid && !b.Lot.DeletedAt.Valid &&
+		b.Lot.IsWithinSchedule(b.Auction, time.Now())
+}
+
+// IsBeforeStart reports whether the lot is scheduled to start later
+func (b *AuctionLotBidding) IsBeforeStart() bool {
+	startsAt := b.Lot.EffectiveStartsAt(b.Auction)
+	return startsAt.Valid && time.Now().Before(startsAt.Time)
+}
+
+func (b *AuctionLotBidding) IsBuyNowAvailable() bool {
+	return b.AcceptsBids() && !b.IsOwnLot() && b.Lot.IsBuyNowAvailable(b.HighestBid)
+}
+
+func (b *AuctionLotBidding) IsViewerHighestBidder() bool {
+	return b.HighestBid != nil && b.ViewerID != 0 && b.HighestBid.UserID == b.ViewerID
+}
+
+// IsReserveMet must be used instead of the lot reserve price, which is never shown to the bidders
+func (b *AuctionLotBidding) IsReserveMet() bool {
+	return b.Lot.IsReserveMet(b.HighestBid)
+}
+
+func (b *AuctionLotBidding) IsViewerWinner() bool {
+	return b.Winner != nil && b.ViewerID != 0 && b.Winner.UserID == b.ViewerID
+}
+
+type AuctionLotPageHandler struct {
+	bidding *AuctionLotBidding
+}
+
+func NewAuctionLotPageHandler(bidding *AuctionLotBidding) *AuctionLotPageHandler {
+	return &AuctionLotPageHandler{
+		bidding: bidding,
+	}
+}
+
+func (a *AuctionLotPageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler := templ.Handler(a.newAuctionLotPage(r.Context()))
+	handler.ServeHTTP(w, r)
+}
+
+func (a *AuctionLotPageHandler) newAuctionLotPage(ctx context.Context) templ.Component {
+	hxBoosted, err := utils.ExtractValueFromContext[bool](ctx, "hxBoosted")
+	if err != nil {
+		hxBoosted = false
+	}
+
+	if hxBoosted {
+		return auctionLotPage(a.bidding)
+	}
+
+	isAuthorized, err := utils.ExtractValueFromContext[bool](ctx, "isAuthorized")
+	if err != nil {
+		isAuthorized = false
+	}
+
+	builder := NewHTMLPageBuilder(root)
+	builder.AppendComponent(mainHeader(isAuthorized))
+	builder.AppendComponent(auctionLotPage(a.bidding))
+	builder.AppendComponent(mainFooter())
+
+	return builder.Build()
+}
+
+func NewAuctionLotBidSectionHandler(bidding *AuctionLotBidding) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: auctionLotBidSection(bidding, nil, nil),
+	}
+}
+
+func NewAuctionLotBidSectionErrorBadRequestHandler(bidding *AuctionLotBidding, values map[string]string, errors map[string]string) *utils.TemplateHandler {
+	if values == nil {
+		values = make(map[string]string)
+	}
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: auctionLotBidSection(bidding, values, errors),
+	}
+}
+
+// RenderAuctionLotPrice renders the lot price fragment that is swapped on the lot page by the AuctionLotPriceEvent
+func RenderAuctionLotPrice(ctx context.Context, w io.Writer, auction *types.Auction, lot *types.AuctionLot, highestBid *types.Bid) error {
+	return auctionLotPrice(auction, lot, highestBid).Render(ctx, w)
+}
+
+// AuctionLotPriceEvent is the name of the server-sent event that carries the fresh lot price fragment
+func AuctionLotPriceEvent(auctionLotId int64) string {
+	return "lot-" + utils.IdToString(auctionLotId) + "-price"
+}
+
+// AuctionLotStatusEvent is the name of the server-sent event that makes the lot page reload the bid section
+func AuctionLotStatusEvent(auctionLotId int64) string {
+	return "lot-" + utils.IdToString(auctionLotId) + "-status"
+}
+
+func formatScheduleTime(t time.Time) string {
+	return t.In(time.Local).Format("on Jan 2, 2006 at 15:04")
+}
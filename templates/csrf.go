@@ -0,0 +1,22 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/artemsmotritel/oktion/utils"
+)
+
+// csrfHeaders is put into hx-headers of the page body, so that htmx sends the CSRF token with every request
+func csrfHeaders(ctx context.Context) string {
+	token, err := utils.ExtractValueFromContext[string](ctx, "csrfToken")
+	if err != nil {
+		return "{}"
+	}
+
+	headers, err := json.Marshal(map[string]string{"X-CSRF-Token": token})
+	if err != nil {
+		return "{}"
+	}
+
+	return string(headers)
+}
@@ -0,0 +1,34 @@
+package templates
+
+import (
+	"github.com/a-h/templ"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+)
+
+type VerifyEmailPageHandler struct {
+	verified bool
+}
+
+func NewVerifyEmailPageHandler(verified bool) *VerifyEmailPageHandler {
+	return &VerifyEmailPageHandler{
+		verified: verified,
+	}
+}
+
+func (h *VerifyEmailPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	page := verifyEmailInvalid()
+	if h.verified {
+		page = verifyEmailDone()
+	}
+
+	handler := templ.Handler(newAccountPage(re.Context(), page))
+	handler.ServeHTTP(w, re)
+}
+
+// NewEmailVerificationNoticeHandler renders the notice with the default text for an empty message
+func NewEmailVerificationNoticeHandler(message string) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: emailVerificationNotice(message),
+	}
+}
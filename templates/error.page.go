@@ -16,6 +16,9 @@ const (
 	Unauthorized        ErrorCode = 3
 	InternalServerError ErrorCode = 4
 	StatusConflict      ErrorCode = 5
+	InvalidCSRFToken    ErrorCode = 6
+	EmailNotVerified    ErrorCode = 7
+	TwoFactorRequired   ErrorCode = 8
 )
 
 type ErrorPageHandler struct {
@@ -37,6 +40,12 @@ func NewErrorPageWithMessageHandler(errorCode ErrorCode, message string) *ErrorP
 		template = internal()
 	case StatusConflict:
 		template = statusConflict(message)
+	case InvalidCSRFToken:
+		template = invalidCSRFToken()
+	case EmailNotVerified:
+		template = emailNotVerified()
+	case TwoFactorRequired:
+		template = twoFactorRequired()
 	default:
 		panic(fmt.Sprintf("unsupported error code was provided: %d", errorCode))
 	}
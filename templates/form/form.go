@@ -27,6 +27,7 @@ const (
 	EmailInputType    fieldInputType = "email"
 	PhoneInputType    fieldInputType = "tel"
 	NumberInputType   fieldInputType = "number"
+	DateTimeInputType fieldInputType = "datetime-local"
 )
 
 type Field struct {
@@ -8,29 +8,33 @@ import (
 )
 
 type LoginPageHandler struct {
+	identityProviderName string
 }
 
-func NewLoginPageHandler() *LoginPageHandler {
-	return &LoginPageHandler{}
+// NewLoginPageHandler offers to log in with the OpenID provider unless its name is empty
+func NewLoginPageHandler(identityProviderName string) *LoginPageHandler {
+	return &LoginPageHandler{
+		identityProviderName: identityProviderName,
+	}
 }
 
 func (r *LoginPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
-	handler := templ.Handler(newLoginPage(re.Context()))
+	handler := templ.Handler(newLoginPage(re.Context(), r.identityProviderName))
 	handler.ServeHTTP(w, re)
 }
 
-func newLoginPage(ctx context.Context) templ.Component {
+func newLoginPage(ctx context.Context, identityProviderName string) templ.Component {
 	isHTMXRequest, err := utils.ExtractValueFromContext[bool](ctx, "hxBoosted")
 	if err != nil {
 		isHTMXRequest = false
 	}
 
 	if isHTMXRequest {
-		return login()
+		return login(identityProviderName)
 	}
 
 	builder := NewHTMLPageBuilder(root)
-	builder.AppendComponent(login())
+	builder.AppendComponent(login(identityProviderName))
 
 	return builder.Build()
 }
@@ -86,3 +90,37 @@ func NewLoginErrorBadRequestHandler(values map[string]string, errors map[string]
 		Template: loginForm(values, errors),
 	}
 }
+
+// NewTwoFactorLoginFormHandler asks for the second factor of a login in place of the login form
+func NewTwoFactorLoginFormHandler(errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: twoFactorLoginForm(errors),
+	}
+}
+
+// AccountPageHandler renders a page without the site header, see newAccountPage
+type AccountPageHandler struct {
+	page templ.Component
+}
+
+func (h *AccountPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	handler := templ.Handler(newAccountPage(re.Context(), h.page))
+	handler.ServeHTTP(w, re)
+}
+
+// NewTwoFactorLoginPageHandler asks for the second factor of a login that hasn't started on the login page
+func NewTwoFactorLoginPageHandler() *AccountPageHandler {
+	return &AccountPageHandler{
+		page: twoFactorLogin(),
+	}
+}
+
+// NewIdentityProviderLoginFailedHandler explains why the login with the OpenID provider didn't work
+func NewIdentityProviderLoginFailedHandler(message string) *AccountPageHandler {
+	return &AccountPageHandler{
+		page: identityProviderLoginFailed(message),
+	}
+}
@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"context"
+	"github.com/a-h/templ"
+	"github.com/artemsmotritel/oktion/utils"
+	"net/http"
+)
+
+// newAccountPage renders the account pages the same way as the login page, without the site header
+func newAccountPage(ctx context.Context, page templ.Component) templ.Component {
+	isHTMXRequest, err := utils.ExtractValueFromContext[bool](ctx, "hxBoosted")
+	if err != nil {
+		isHTMXRequest = false
+	}
+
+	if isHTMXRequest {
+		return page
+	}
+
+	builder := NewHTMLPageBuilder(root)
+	builder.AppendComponent(page)
+
+	return builder.Build()
+}
+
+type ForgotPasswordPageHandler struct {
+}
+
+func NewForgotPasswordPageHandler() *ForgotPasswordPageHandler {
+	return &ForgotPasswordPageHandler{}
+}
+
+func (h *ForgotPasswordPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	handler := templ.Handler(newAccountPage(re.Context(), forgotPassword()))
+	handler.ServeHTTP(w, re)
+}
+
+type ResetPasswordPageHandler struct {
+	token string
+	valid bool
+}
+
+// NewResetPasswordPageHandler shows the new password form for a usable token and explains what happened otherwise
+func NewResetPasswordPageHandler(token string, valid bool) *ResetPasswordPageHandler {
+	return &ResetPasswordPageHandler{
+		token: token,
+		valid: valid,
+	}
+}
+
+func (h *ResetPasswordPageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
+	page := resetPasswordInvalid()
+	if h.valid {
+		page = resetPassword(h.token)
+	}
+
+	handler := templ.Handler(newAccountPage(re.Context(), page))
+	handler.ServeHTTP(w, re)
+}
+
+func NewForgotPasswordErrorBadRequestHandler(values map[string]string, errors map[string]string) *utils.TemplateHandler {
+	if values == nil {
+		values = make(map[string]string)
+	}
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: forgotPasswordForm(values, errors),
+	}
+}
+
+func NewForgotPasswordSentHandler(email string) *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: forgotPasswordSent(email),
+	}
+}
+
+func NewResetPasswordErrorBadRequestHandler(token string, errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: resetPasswordForm(token, errors),
+	}
+}
+
+func NewResetPasswordDoneHandler() *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: resetPasswordDone(),
+	}
+}
+
+func NewResetPasswordInvalidHandler() *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: resetPasswordInvalid(),
+	}
+}
@@ -16,10 +16,13 @@ type ProfileMenuItem struct {
 type ProfilePageHandler struct {
 	menuItems []ProfileMenuItem
 	user      *types.User
+	twoFactor templ.Component
+	apiTokens []types.APIToken
 }
 
-func NewProfilePageHandler(user *types.User) *ProfilePageHandler {
-	return &ProfilePageHandler{
+// NewProfilePageHandler offers two-factor authentication to sellers, twoFactor is nil for a user who hasn't set it up
+func NewProfilePageHandler(user *types.User, twoFactor *types.TwoFactor, recoveryCodesLeft int, apiTokens []types.APIToken) *ProfilePageHandler {
+	h := &ProfilePageHandler{
 		menuItems: []ProfileMenuItem{
 			{
 				Name: "Your auctions",
@@ -34,8 +37,24 @@ func NewProfilePageHandler(user *types.User) *ProfilePageHandler {
 				Link: "/my-bids",
 			},
 		},
-		user: user,
+		user:      user,
+		apiTokens: apiTokens,
 	}
+
+	if user.IsAdmin() {
+		h.menuItems = append(h.menuItems, ProfileMenuItem{
+			Name: "Admin console",
+			Link: "/admin",
+		})
+	}
+
+	if twoFactor != nil && twoFactor.IsEnabled() {
+		h.twoFactor = twoFactorEnabled(nil, recoveryCodesLeft, nil)
+	} else if user.HasRole(types.SellerRole) {
+		h.twoFactor = twoFactorDisabled()
+	}
+
+	return h
 }
 
 func (h *ProfilePageHandler) ServeHTTP(w http.ResponseWriter, re *http.Request) {
@@ -50,12 +69,12 @@ func (h *ProfilePageHandler) newProfilePage(ctx context.Context) templ.Component
 	}
 
 	if isHTMXRequest {
-		return profile(h.menuItems, h.user)
+		return profile(h.menuItems, h.user, h.twoFactor, h.apiTokens)
 	}
 
 	builder := NewHTMLPageBuilder(root)
 	builder.AppendComponent(mainHeader(ctx.Value("isAuthorized").(bool)))
-	builder.AppendComponent(profile(h.menuItems, h.user))
+	builder.AppendComponent(profile(h.menuItems, h.user, h.twoFactor, h.apiTokens))
 	builder.AppendComponent(mainFooter())
 
 	return builder.Build()
@@ -75,3 +94,49 @@ func NewProfileFormHandler(user *types.User) *utils.TemplateHandler {
 		Template: profileEditForm(user, nil),
 	}
 }
+
+func NewTwoFactorDisabledHandler() *utils.TemplateHandler {
+	return &utils.TemplateHandler{
+		Template: twoFactorDisabled(),
+	}
+}
+
+func NewTwoFactorSetupHandler(qrCodeSVG, secret string, errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: twoFactorSetup(qrCodeSVG, secret, errors),
+	}
+}
+
+// NewTwoFactorEnabledHandler shows the recovery codes once right after the enrolment, and the count of the unused ones afterwards
+func NewTwoFactorEnabledHandler(recoveryCodes []string, recoveryCodesLeft int, errors map[string]string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: twoFactorEnabled(recoveryCodes, recoveryCodesLeft, errors),
+	}
+}
+
+func NewChangeEmailFormHandler(values map[string]string, errors map[string]string, message string) *utils.TemplateHandler {
+	if values == nil {
+		values = make(map[string]string)
+	}
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: changeEmailForm(values, errors, message),
+	}
+}
+
+func NewChangePasswordFormHandler(errors map[string]string, message string) *utils.TemplateHandler {
+	if errors == nil {
+		errors = make(map[string]string)
+	}
+	return &utils.TemplateHandler{
+		Template: changePasswordForm(errors, message),
+	}
+}
@@ -0,0 +1,145 @@
+// Package throttle slows down password guessing. Every failed login of an account or from an IP address
+// doubles the time the next login has to wait, until the key is locked out for a while.
+// The counters are kept in the storage, so a restart doesn't reset them.
+package throttle
+
+import (
+	"context"
+	"github.com/artemsmotritel/oktion/storage"
+	"github.com/artemsmotritel/oktion/types"
+	"net"
+	"strings"
+	"time"
+)
+
+// Policy is how many failures a key may have before it is slowed down and locked out
+type Policy struct {
+	// FreeFailures are the failures, e.g. typos, that don't slow the key down
+	FreeFailures int
+	// BaseDelay is the wait after the first failure past FreeFailures, every next failure doubles it up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutFailures locks the key out for LockoutDuration, counting the free failures
+	LockoutFailures int
+	LockoutDuration time.Duration
+	// ResetAfter is how long the key has to go without failures to start over
+	ResetAfter time.Duration
+}
+
+var (
+	// DefaultAccountPolicy guards a single account against guessing its password
+	DefaultAccountPolicy = Policy{
+		FreeFailures:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutFailures: 10,
+		LockoutDuration: 15 * time.Minute,
+		ResetAfter:      time.Hour,
+	}
+	// DefaultIPPolicy guards against trying many accounts from one address.
+	// It allows more failures, since the users behind a NAT share the address
+	DefaultIPPolicy = Policy{
+		FreeFailures:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutFailures: 100,
+		LockoutDuration: time.Hour,
+		ResetAfter:      time.Hour,
+	}
+)
+
+// Delay is how long the key has to wait after its last failure
+func (p Policy) Delay(failures int) time.Duration {
+	if failures >= p.LockoutFailures {
+		return p.LockoutDuration
+	}
+
+	if failures <= p.FreeFailures {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, p.MaxDelay)
+}
+
+func (p Policy) retryAfter(throttle *types.LoginThrottle, now time.Time) time.Duration {
+	if throttle == nil || !throttle.LastFailureAt.Add(p.ResetAfter).After(now) {
+		return 0
+	}
+
+	return max(throttle.LastFailureAt.Add(p.Delay(throttle.Failures)).Sub(now), 0)
+}
+
+type LoginLimiter struct {
+	store         storage.LoginThrottleStore
+	accountPolicy Policy
+	ipPolicy      Policy
+}
+
+func NewLoginLimiter(store storage.LoginThrottleStore, accountPolicy, ipPolicy Policy) *LoginLimiter {
+	return &LoginLimiter{
+		store:         store,
+		accountPolicy: accountPolicy,
+		ipPolicy:      ipPolicy,
+	}
+}
+
+// accountKey is made of the email as typed, whether the account exists or not,
+// so that the limiter answers the same for the emails that don't exist
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipKey accepts the http.Request RemoteAddr, with or without the port
+func ipKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// RetryAfter returns how long the login has to wait, zero lets it through
+func (l *LoginLimiter) RetryAfter(ctx context.Context, remoteAddr, email string, now time.Time) (time.Duration, error) {
+	ipThrottle, err := l.store.GetLoginThrottle(ctx, ipKey(remoteAddr))
+	if err != nil {
+		return 0, err
+	}
+
+	accountThrottle, err := l.store.GetLoginThrottle(ctx, accountKey(email))
+	if err != nil {
+		return 0, err
+	}
+
+	return max(l.ipPolicy.retryAfter(ipThrottle, now), l.accountPolicy.retryAfter(accountThrottle, now)), nil
+}
+
+func (l *LoginLimiter) RecordFailure(ctx context.Context, remoteAddr, email string, now time.Time) error {
+	if _, err := l.store.RecordLoginFailure(ctx, ipKey(remoteAddr), now, l.ipPolicy.ResetAfter); err != nil {
+		return err
+	}
+
+	_, err := l.store.RecordLoginFailure(ctx, accountKey(email), now, l.accountPolicy.ResetAfter)
+	return err
+}
+
+// RecordSuccess starts the account over. The IP address isn't, otherwise logging into
+// an own account in between would let an attacker guess the passwords of others without limit
+func (l *LoginLimiter) RecordSuccess(ctx context.Context, email string, now time.Time) error {
+	if err := l.store.DeleteLoginThrottle(ctx, accountKey(email)); err != nil {
+		return err
+	}
+
+	// logins are rare enough to clean up the forgotten counters on the way
+	return l.store.DeleteStaleLoginThrottles(ctx, now.Add(-max(l.accountPolicy.ResetAfter, l.ipPolicy.ResetAfter)))
+}
+
+// Unlock starts the account over, e.g. once its password has been reset
+func (l *LoginLimiter) Unlock(ctx context.Context, email string) error {
+	return l.store.DeleteLoginThrottle(ctx, accountKey(email))
+}
@@ -0,0 +1,32 @@
+package totp
+
+import (
+	"fmt"
+	"rsc.io/qr"
+	"strings"
+)
+
+// quietZone is the blank margin around the code the scanners need, in modules
+const quietZone = 4
+
+// QRCodeSVG renders the text as an SVG QR code that scales with the element it is put in
+func QRCodeSVG(text string) (string, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", err
+	}
+
+	size := code.Size + 2*quietZone
+
+	var path strings.Builder
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges" role="img" aria-label="QR code">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="%s"/></svg>`, size, size, path.String()), nil
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Scope is what an API token may do on top of reading
+type Scope string
+
+const (
+	AuctionsWriteScope Scope = "auctions:write"
+	BidsWriteScope     Scope = "bids:write"
+)
+
+var AllScopes = []Scope{AuctionsWriteScope, BidsWriteScope}
+
+func ParseScope(value string) (Scope, error) {
+	for _, scope := range AllScopes {
+		if string(scope) == value {
+			return scope, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown scope: %s", value)
+}
+
+// APIToken lets the scripts of the user act on their behalf with the Authorization: Bearer header.
+// Only the hash of the token is kept, the token itself is shown once when it is created
+type APIToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userId"`
+	Name      string    `json:"name"`
+	TokenHash string    `json:"-"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	// ExpiresAt is invalid for a token that never expires
+	ExpiresAt  sql.NullTime `json:"expiresAt"`
+	LastUsedAt sql.NullTime `json:"lastUsedAt"`
+}
+
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt.Valid && !now.Before(t.ExpiresAt.Time)
+}
+
+func (t *APIToken) HasScope(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+func CopyAPIToken(t *APIToken) *APIToken {
+	return &APIToken{
+		ID:         t.ID,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		TokenHash:  t.TokenHash,
+		Scopes:     slices.Clone(t.Scopes),
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+	}
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"database/sql"
 	"errors"
+	"github.com/artemsmotritel/oktion/utils"
 	"net/url"
 	"time"
 )
@@ -38,6 +39,22 @@ func (request *AuctionCreateRequest) private() (bool, error) {
 	return isPrivate, nil
 }
 
+func (request *AuctionCreateRequest) startsAt() (sql.NullTime, error) {
+	startsAt, err := utils.StringToNullTime(request.Get("startsAt"))
+	if err != nil {
+		return sql.NullTime{}, errors.New("the auction start time is not a valid date")
+	}
+	return startsAt, nil
+}
+
+func (request *AuctionCreateRequest) endsAt() (sql.NullTime, error) {
+	endsAt, err := utils.StringToNullTime(request.Get("endsAt"))
+	if err != nil {
+		return sql.NullTime{}, errors.New("the auction end time is not a valid date")
+	}
+	return endsAt, nil
+}
+
 type Auction struct {
 	ID          int64        `json:"id,omitempty"`
 	OwnerId     int64        `json:"ownerId,omitempty"`
@@ -48,6 +65,36 @@ type Auction struct {
 	CreatedAt   time.Time    `json:"createdAt"`
 	UpdatedAt   time.Time    `json:"updatedAt"`
 	DeletedAt   sql.NullTime `json:"-"`
+	// BidIncrements is loaded only when a single auction is requested
+	BidIncrements BidIncrements `json:"bidIncrements,omitempty"`
+	StartsAt      sql.NullTime  `json:"startsAt"`
+	EndsAt        sql.NullTime  `json:"endsAt"`
+	// IsStarted is set by the scheduler once it has opened the auction at StartsAt
+	IsStarted bool `json:"-"`
+	IsClosed  bool `json:"isClosed,omitempty"`
+	// SoftCloseWindow is the anti-sniping window: a bid placed closer than this to the lot end
+	// moves the end to the bid time plus the window. Zero disables it
+	SoftCloseWindow time.Duration `json:"softCloseWindow,omitempty"`
+	// RequireManagerTwoFactor keeps everyone but the owner from managing the auction without two-factor authentication
+	RequireManagerTwoFactor bool `json:"requireManagerTwoFactor,omitempty"`
+	// ModeratedAt is set while an admin keeps the auction archived, only an admin can reinstate it then
+	ModeratedAt sql.NullTime `json:"moderatedAt"`
+}
+
+func (a *Auction) Status() utils.Status {
+	return utils.Status{
+		IsActive: a.IsActive,
+		IsClosed: a.IsClosed,
+	}
+}
+
+func (a *Auction) IsModerated() bool {
+	return a.ModeratedAt.Valid
+}
+
+// IsScheduled reports whether the auction is waiting for the scheduler to open it
+func (a *Auction) IsScheduled(now time.Time) bool {
+	return a.StartsAt.Valid && a.StartsAt.Time.After(now) && !a.IsClosed
 }
 
 func CreateAuction(id int64, ownerId int64, name string, description string, isPrivate bool) *Auction {
@@ -69,6 +116,15 @@ func CopyAuction(auction *Auction) Auction {
 	newAuction.CreatedAt = auction.CreatedAt
 	newAuction.UpdatedAt = auction.UpdatedAt
 	newAuction.DeletedAt = auction.DeletedAt
+	newAuction.IsActive = auction.IsActive
+	newAuction.BidIncrements = CopyBidIncrements(auction.BidIncrements)
+	newAuction.StartsAt = auction.StartsAt
+	newAuction.EndsAt = auction.EndsAt
+	newAuction.IsStarted = auction.IsStarted
+	newAuction.IsClosed = auction.IsClosed
+	newAuction.SoftCloseWindow = auction.SoftCloseWindow
+	newAuction.RequireManagerTwoFactor = auction.RequireManagerTwoFactor
+	newAuction.ModeratedAt = auction.ModeratedAt
 
 	return *newAuction
 }
@@ -91,12 +147,28 @@ func MapAuctionCreateRequest(values url.Values, ownerId int64) (*Auction, error)
 		return nil, err
 	}
 
+	startsAt, err := request.startsAt()
+	if err != nil {
+		return nil, err
+	}
+
+	endsAt, err := request.endsAt()
+	if err != nil {
+		return nil, err
+	}
+
+	if startsAt.Valid && endsAt.Valid && !endsAt.Time.After(startsAt.Time) {
+		return nil, errors.New("the auction must end after it starts")
+	}
+
 	auction := &Auction{
 		IsActive:    false,
 		OwnerId:     ownerId,
 		Name:        name,
 		Description: description,
 		IsPrivate:   isPrivate,
+		StartsAt:    startsAt,
+		EndsAt:      endsAt,
 	}
 
 	return auction, nil
@@ -107,6 +179,10 @@ type AuctionUpdateRequest struct {
 	Name        string
 	Description string
 	IsPrivate   bool
+	StartsAt    sql.NullTime
+	EndsAt      sql.NullTime
+	StartsAtStr string
+	EndsAtStr   string
 }
 
 func NewAuctionUpdateRequest(values url.Values, id int64) AuctionUpdateRequest {
@@ -114,6 +190,21 @@ func NewAuctionUpdateRequest(values url.Values, id int64) AuctionUpdateRequest {
 		Name:        values.Get("name"),
 		Description: values.Get("description"),
 		IsPrivate:   values.Get("private") == "on",
+		StartsAtStr: values.Get("startsAt"),
+		EndsAtStr:   values.Get("endsAt"),
 		ID:          id,
 	}
 }
+
+type SoftCloseUpdateRequest struct {
+	AuctionID  int64
+	MinutesStr string
+	Window     time.Duration
+}
+
+func NewSoftCloseUpdateRequest(values url.Values, auctionId int64) *SoftCloseUpdateRequest {
+	return &SoftCloseUpdateRequest{
+		AuctionID:  auctionId,
+		MinutesStr: values.Get("softCloseMinutes"),
+	}
+}
@@ -7,6 +7,19 @@ import (
 	"time"
 )
 
+// AuctionLotOutcome tells how a closed auction lot has ended, it is empty while the lot is open
+type AuctionLotOutcome string
+
+const (
+	AuctionLotOutcomeNone          AuctionLotOutcome = ""
+	AuctionLotOutcomeSold          AuctionLotOutcome = "sold"
+	AuctionLotOutcomeNoBids        AuctionLotOutcome = "no_bids"
+	AuctionLotOutcomeReserveNotMet AuctionLotOutcome = "reserve_not_met"
+	// AuctionLotOutcomeOffered means that the seller has offered the lot with the reserve not met to the highest bidder
+	AuctionLotOutcomeOffered       AuctionLotOutcome = "offered"
+	AuctionLotOutcomeOfferDeclined AuctionLotOutcome = "offer_declined"
+)
+
 type AuctionLot struct {
 	ID           int64
 	AuctionID    int64
@@ -14,20 +27,145 @@ type AuctionLot struct {
 	Description  string
 	CategoryId   int64
 	IsActive     bool
+	IsClosed     bool
+	Outcome      AuctionLotOutcome
 	MinimalBid   decimal.Decimal
 	ReservePrice decimal.Decimal
 	BinPrice     decimal.Decimal
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    sql.NullTime
+	// StartsAt and EndsAt override the auction schedule for this lot when they are valid
+	StartsAt  sql.NullTime
+	EndsAt    sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt sql.NullTime
+	// ModeratedAt is set while an admin keeps the lot deactivated, only an admin can reinstate it then
+	ModeratedAt sql.NullTime
 }
 
 func CopyAuctionLot(auctionLot *AuctionLot) *AuctionLot {
 	return &AuctionLot{
-		ID:        auctionLot.ID,
-		AuctionID: auctionLot.AuctionID,
-		Name:      auctionLot.Name,
+		ID:           auctionLot.ID,
+		AuctionID:    auctionLot.AuctionID,
+		Name:         auctionLot.Name,
+		Description:  auctionLot.Description,
+		CategoryId:   auctionLot.CategoryId,
+		IsActive:     auctionLot.IsActive,
+		IsClosed:     auctionLot.IsClosed,
+		Outcome:      auctionLot.Outcome,
+		MinimalBid:   auctionLot.MinimalBid,
+		ReservePrice: auctionLot.ReservePrice,
+		BinPrice:     auctionLot.BinPrice,
+		StartsAt:     auctionLot.StartsAt,
+		EndsAt:       auctionLot.EndsAt,
+		CreatedAt:    auctionLot.CreatedAt,
+		UpdatedAt:    auctionLot.UpdatedAt,
+		DeletedAt:    auctionLot.DeletedAt,
+		ModeratedAt:  auctionLot.ModeratedAt,
+	}
+}
+
+func (lot *AuctionLot) IsModerated() bool {
+	return lot.ModeratedAt.Valid
+}
+
+// IsBuyNowAvailable reports whether the lot can be bought for its BinPrice.
+// The option turns off for good once the bids reach the BinPrice
+func (lot *AuctionLot) IsBuyNowAvailable(highestBid *Bid) bool {
+	if !lot.BinPrice.IsPositive() {
+		return false
+	}
+
+	return highestBid == nil || highestBid.Value.LessThan(lot.BinPrice)
+}
+
+// EffectiveStartsAt returns the lot start time, falling back to the auction one
+func (lot *AuctionLot) EffectiveStartsAt(auction *Auction) sql.NullTime {
+	if lot.StartsAt.Valid {
+		return lot.StartsAt
 	}
+	return auction.StartsAt
+}
+
+// EffectiveEndsAt returns the lot end time, falling back to the auction one
+func (lot *AuctionLot) EffectiveEndsAt(auction *Auction) sql.NullTime {
+	if lot.EndsAt.Valid {
+		return lot.EndsAt
+	}
+	return auction.EndsAt
+}
+
+// IsWithinSchedule reports whether the bidding on the lot is open at the time according to the schedule
+func (lot *AuctionLot) IsWithinSchedule(auction *Auction, now time.Time) bool {
+	if startsAt := lot.EffectiveStartsAt(auction); startsAt.Valid && now.Before(startsAt.Time) {
+		return false
+	}
+	if endsAt := lot.EffectiveEndsAt(auction); endsAt.Valid && !now.Before(endsAt.Time) {
+		return false
+	}
+	return true
+}
+
+// SoftCloseEndsAt returns the lot end time extended by the auction soft close window for a bid placed at the time.
+// It returns false when the bid doesn't fall into the window
+func (lot *AuctionLot) SoftCloseEndsAt(auction *Auction, bidAt time.Time) (time.Time, bool) {
+	endsAt := lot.EffectiveEndsAt(auction)
+	if auction.SoftCloseWindow <= 0 || !endsAt.Valid {
+		return time.Time{}, false
+	}
+
+	extended := bidAt.Add(auction.SoftCloseWindow)
+	if !extended.After(endsAt.Time) {
+		return time.Time{}, false
+	}
+
+	return extended, true
+}
+
+// ChangesPrices reports whether the update sets other minimal bid, reserve or buy now prices on the lot
+func (lot *AuctionLot) ChangesPrices(request *AuctionLotUpdateRequest) bool {
+	return !lot.MinimalBid.Equal(request.MinimalBid) || !lot.ReservePrice.Equal(request.ReservePrice) || !lot.BinPrice.Equal(request.BinPrice)
+}
+
+// KeptEndsAt returns the end time an update may set on a lot that has bids: the requested one,
+// unless the lot would end earlier than it does now, which would undo the soft close extensions under the bidders
+func (lot *AuctionLot) KeptEndsAt(auction *Auction, requested sql.NullTime) sql.NullTime {
+	endsAt := lot.EffectiveEndsAt(auction)
+	requestedEndsAt := requested
+	if !requestedEndsAt.Valid {
+		requestedEndsAt = auction.EndsAt
+	}
+
+	if !endsAt.Valid || !requestedEndsAt.Valid || !requestedEndsAt.Time.Before(endsAt.Time) {
+		return requested
+	}
+
+	return lot.EndsAt
+}
+
+func (lot *AuctionLot) HasReservePrice() bool {
+	return lot.ReservePrice.IsPositive()
+}
+
+// IsReserveMet reports whether the highest bid, which may be nil, is enough to sell the lot
+func (lot *AuctionLot) IsReserveMet(highestBid *Bid) bool {
+	if highestBid == nil {
+		return false
+	}
+
+	return !lot.HasReservePrice() || highestBid.Value.GreaterThanOrEqual(lot.ReservePrice)
+}
+
+// ClosingOutcome is the outcome the lot ends with when it is closed with the highest bid, which may be nil
+func (lot *AuctionLot) ClosingOutcome(highestBid *Bid) AuctionLotOutcome {
+	if highestBid == nil {
+		return AuctionLotOutcomeNoBids
+	}
+
+	if !lot.IsReserveMet(highestBid) {
+		return AuctionLotOutcomeReserveNotMet
+	}
+
+	return AuctionLotOutcomeSold
 }
 
 type AuctionLotUpdateRequest struct {
@@ -43,6 +181,10 @@ type AuctionLotUpdateRequest struct {
 	MinimalBidStr   string
 	ReservePriceStr string
 	BinPriceStr     string
+	StartsAt        sql.NullTime
+	EndsAt          sql.NullTime
+	StartsAtStr     string
+	EndsAtStr       string
 }
 
 func NewAuctionLotUpdateRequest(values url.Values, lotId, auctionId int64) (*AuctionLotUpdateRequest, error) {
@@ -55,5 +197,7 @@ func NewAuctionLotUpdateRequest(values url.Values, lotId, auctionId int64) (*Auc
 		MinimalBidStr:   values.Get("minimalBid"),
 		ReservePriceStr: values.Get("reservePrice"),
 		BinPriceStr:     values.Get("binPrice"),
+		StartsAtStr:     values.Get("startsAt"),
+		EndsAtStr:       values.Get("endsAt"),
 	}, nil
 }
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// AuctionLotWinner is the user who has won a closed lot with the BidID bid
+type AuctionLotWinner struct {
+	AuctionLotID int64
+	UserID       int64
+	BidID        int64
+	Price        decimal.Decimal
+	WonAt        time.Time
+}
+
+func CopyAuctionLotWinner(winner *AuctionLotWinner) *AuctionLotWinner {
+	return &AuctionLotWinner{
+		AuctionLotID: winner.AuctionLotID,
+		UserID:       winner.UserID,
+		BidID:        winner.BidID,
+		Price:        winner.Price,
+		WonAt:        winner.WonAt,
+	}
+}
@@ -0,0 +1,35 @@
+package types
+
+import "time"
+
+type AuditAction string
+
+const (
+	AuditUserSuspended         AuditAction = "user.suspended"
+	AuditUserUnsuspended       AuditAction = "user.unsuspended"
+	AuditAuctionArchived       AuditAction = "auction.archived"
+	AuditAuctionReinstated     AuditAction = "auction.reinstated"
+	AuditAuctionLotDeactivated AuditAction = "auction_lot.deactivated"
+	AuditAuctionLotReinstated  AuditAction = "auction_lot.reinstated"
+)
+
+// AuditEntry records an action an admin has taken, TargetID is the id of the user, auction or lot the action names
+type AuditEntry struct {
+	ID        int64
+	ActorID   int64
+	Action    AuditAction
+	TargetID  int64
+	Reason    string
+	CreatedAt time.Time
+}
+
+func CopyAuditEntry(entry *AuditEntry) *AuditEntry {
+	return &AuditEntry{
+		ID:        entry.ID,
+		ActorID:   entry.ActorID,
+		Action:    entry.Action,
+		TargetID:  entry.TargetID,
+		Reason:    entry.Reason,
+		CreatedAt: entry.CreatedAt,
+	}
+}
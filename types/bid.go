@@ -0,0 +1,40 @@
+package types
+
+import (
+	"github.com/shopspring/decimal"
+	"net/url"
+	"time"
+)
+
+type Bid struct {
+	ID           int64
+	AuctionLotID int64
+	UserID       int64
+	Value        decimal.Decimal
+	CreatedAt    time.Time
+}
+
+func CopyBid(bid *Bid) *Bid {
+	return &Bid{
+		ID:           bid.ID,
+		AuctionLotID: bid.AuctionLotID,
+		UserID:       bid.UserID,
+		Value:        bid.Value,
+		CreatedAt:    bid.CreatedAt,
+	}
+}
+
+type BidCreateRequest struct {
+	AuctionLotID int64
+	UserID       int64
+	Value        decimal.Decimal
+	ValueStr     string
+}
+
+func NewBidCreateRequest(values url.Values, auctionLotId, userId int64) *BidCreateRequest {
+	return &BidCreateRequest{
+		AuctionLotID: auctionLotId,
+		UserID:       userId,
+		ValueStr:     values.Get("value"),
+	}
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"github.com/shopspring/decimal"
+	"net/url"
+	"slices"
+)
+
+// MinimalBidIncrement is used when the auction bid increment ladder doesn't cover the current price
+var MinimalBidIncrement = decimal.New(1, -2)
+
+// BidIncrement is a step of the auction bid increment ladder.
+// The Amount applies to current prices starting with From (inclusive) up to the From of the next step
+type BidIncrement struct {
+	From   decimal.Decimal
+	Amount decimal.Decimal
+}
+
+// BidIncrements is an auction bid increment ladder sorted by BidIncrement.From
+type BidIncrements []BidIncrement
+
+func (increments BidIncrements) Sort() {
+	slices.SortFunc(increments, func(a, b BidIncrement) int {
+		return a.From.Compare(b.From)
+	})
+}
+
+// For returns the increment that must be added to the current price
+func (increments BidIncrements) For(price decimal.Decimal) decimal.Decimal {
+	amount := MinimalBidIncrement
+
+	for _, step := range increments {
+		if price.LessThan(step.From) {
+			break
+		}
+		amount = step.Amount
+	}
+
+	return amount
+}
+
+// NextMinimalBid returns the smallest bid a lot accepts given its minimal bid and the current highest bid, which may be nil
+func (increments BidIncrements) NextMinimalBid(minimalBid decimal.Decimal, highestBid *Bid) decimal.Decimal {
+	if highestBid == nil {
+		if minimalBid.IsPositive() {
+			return minimalBid
+		}
+		return MinimalBidIncrement
+	}
+
+	return highestBid.Value.Add(increments.For(highestBid.Value))
+}
+
+func CopyBidIncrements(increments BidIncrements) BidIncrements {
+	if increments == nil {
+		return nil
+	}
+
+	return slices.Clone(increments)
+}
+
+type BidIncrementsUpdateRequest struct {
+	AuctionID  int64
+	FromStrs   []string
+	AmountStrs []string
+	Increments BidIncrements
+}
+
+func NewBidIncrementsUpdateRequest(values url.Values, auctionId int64) *BidIncrementsUpdateRequest {
+	return &BidIncrementsUpdateRequest{
+		AuctionID:  auctionId,
+		FromStrs:   values["from"],
+		AmountStrs: values["amount"],
+	}
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// EmailVerificationToken is identified by the hash of the emailed token.
+// It verifies only the Email it was sent to, so a token sent before an email change can't verify the new address
+type EmailVerificationToken struct {
+	ID        string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func CopyEmailVerificationToken(token *EmailVerificationToken) *EmailVerificationToken {
+	return &EmailVerificationToken{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		Email:     token.Email,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+	}
+}
@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// ExternalIdentity links the account of an OpenID provider, known by its issuer and subject, to a user
+type ExternalIdentity struct {
+	Issuer  string
+	Subject string
+	UserID  int64
+	// Email is the one the provider vouched for when the identity was linked
+	Email     string
+	CreatedAt time.Time
+}
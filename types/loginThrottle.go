@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+// LoginThrottle counts the recent failed logins of an account or an IP address, see throttle.LoginLimiter
+type LoginThrottle struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+}
+
+func CopyLoginThrottle(t *LoginThrottle) *LoginThrottle {
+	return &LoginThrottle{
+		Key:           t.Key,
+		Failures:      t.Failures,
+		LastFailureAt: t.LastFailureAt,
+	}
+}
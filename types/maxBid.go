@@ -0,0 +1,43 @@
+package types
+
+import (
+	"github.com/shopspring/decimal"
+	"net/url"
+	"time"
+)
+
+// MaxBid is a hidden maximum a user is ready to pay for a lot.
+// The proxy bidding engine bids on the user behalf up to the Amount.
+// A user has at most one MaxBid per lot, CreatedAt is updated every time the Amount is raised
+type MaxBid struct {
+	ID           int64
+	AuctionLotID int64
+	UserID       int64
+	Amount       decimal.Decimal
+	CreatedAt    time.Time
+}
+
+func CopyMaxBid(maxBid *MaxBid) *MaxBid {
+	return &MaxBid{
+		ID:           maxBid.ID,
+		AuctionLotID: maxBid.AuctionLotID,
+		UserID:       maxBid.UserID,
+		Amount:       maxBid.Amount,
+		CreatedAt:    maxBid.CreatedAt,
+	}
+}
+
+type MaxBidCreateRequest struct {
+	AuctionLotID int64
+	UserID       int64
+	Amount       decimal.Decimal
+	AmountStr    string
+}
+
+func NewMaxBidCreateRequest(values url.Values, auctionLotId, userId int64) *MaxBidCreateRequest {
+	return &MaxBidCreateRequest{
+		AuctionLotID: auctionLotId,
+		UserID:       userId,
+		AmountStr:    values.Get("maxAmount"),
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PasswordResetToken is identified by the hash of the emailed token, the token itself is never stored
+type PasswordResetToken struct {
+	ID        string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+func CopyPasswordResetToken(token *PasswordResetToken) *PasswordResetToken {
+	return &PasswordResetToken{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		CreatedAt: token.CreatedAt,
+		ExpiresAt: token.ExpiresAt,
+		UsedAt:    token.UsedAt,
+	}
+}
+
+// IsUsable tells whether the token can still reset the password
+func (t *PasswordResetToken) IsUsable(now time.Time) bool {
+	return !t.UsedAt.Valid && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,26 @@
+package types
+
+import "fmt"
+
+type Role string
+
+const (
+	BidderRole Role = "bidder"
+	SellerRole Role = "seller"
+	AdminRole  Role = "admin"
+)
+
+// DefaultRoles are given to every new user, so that anyone can both bid and sell until an admin decides otherwise
+var DefaultRoles = []Role{BidderRole, SellerRole}
+
+var AllRoles = []Role{BidderRole, SellerRole, AdminRole}
+
+func ParseRole(value string) (Role, error) {
+	for _, role := range AllRoles {
+		if string(role) == value {
+			return role, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown role: %s", value)
+}
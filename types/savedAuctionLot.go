@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// SavedAuctionLot is an auction lot the user has added to their favourites
+type SavedAuctionLot struct {
+	UserID       int64
+	AuctionLotID int64
+	CreatedAt    time.Time
+}
@@ -0,0 +1,26 @@
+package types
+
+import "time"
+
+// Session is a logged-in browser. Its ID is the hash of the token kept in the session cookie, never the token itself
+type Session struct {
+	ID         string
+	UserID     int64
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastSeenAt time.Time
+}
+
+func CopySession(session *Session) *Session {
+	return &Session{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		CreatedAt:  session.CreatedAt,
+		ExpiresAt:  session.ExpiresAt,
+		LastSeenAt: session.LastSeenAt,
+	}
+}
+
+func (s *Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
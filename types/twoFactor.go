@@ -0,0 +1,57 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TwoFactor is the TOTP enrolment of a user, it protects the logins only once EnabledAt is set
+type TwoFactor struct {
+	UserID int64
+	Secret string
+	// EnabledAt is invalid while the user hasn't confirmed the enrolment with a code
+	EnabledAt sql.NullTime
+	// LastUsedStep is the time step of the last accepted code, a code is never accepted twice
+	LastUsedStep int64
+}
+
+func (t *TwoFactor) IsEnabled() bool {
+	return t.EnabledAt.Valid
+}
+
+func CopyTwoFactor(t *TwoFactor) *TwoFactor {
+	return &TwoFactor{
+		UserID:       t.UserID,
+		Secret:       t.Secret,
+		EnabledAt:    t.EnabledAt,
+		LastUsedStep: t.LastUsedStep,
+	}
+}
+
+// RecoveryCode replaces a TOTP code once, only its hash is kept
+type RecoveryCode struct {
+	UserID   int64
+	CodeHash string
+	UsedAt   sql.NullTime
+}
+
+// LoginChallenge is a login waiting for the second factor, identified by the hash of the token in its cookie
+type LoginChallenge struct {
+	ID        string
+	UserID    int64
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+func (c *LoginChallenge) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+func CopyLoginChallenge(c *LoginChallenge) *LoginChallenge {
+	return &LoginChallenge{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		ExpiresAt: c.ExpiresAt,
+		Attempts:  c.Attempts,
+	}
+}
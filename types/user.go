@@ -1,6 +1,11 @@
 package types
 
-import "net/url"
+import (
+	"database/sql"
+	"net/url"
+	"slices"
+	"strings"
+)
 
 type User struct {
 	ID       int64  `json:"id,omitempty"`
@@ -8,6 +13,36 @@ type User struct {
 	Password string `json:"-"`
 	Phone    string `json:"phone,omitempty"`
 	Email    string
+	Roles    []Role `json:"roles"`
+	// SuspendedAt is set while an admin keeps the user from logging in
+	SuspendedAt sql.NullTime `json:"suspendedAt"`
+	// EmailVerifiedAt is invalid until the user follows the link emailed to Email
+	EmailVerifiedAt sql.NullTime `json:"emailVerifiedAt"`
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt.Valid
+}
+
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt.Valid
+}
+
+// HasRole treats admins as holding every role
+func (u *User) HasRole(role Role) bool {
+	return slices.Contains(u.Roles, role) || slices.Contains(u.Roles, AdminRole)
+}
+
+func (u *User) IsAdmin() bool {
+	return slices.Contains(u.Roles, AdminRole)
+}
+
+// UsersPage is a single page of the user list, Total counts the users on all pages
+type UsersPage struct {
+	Users    []User `json:"users"`
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+	Total    int64  `json:"total"`
 }
 
 type UserUpdateRequest struct {
@@ -36,5 +71,37 @@ func CreateUser(id int64, fullName string, email string, password string) *User
 }
 
 func CopyUser(user *User) *User {
-	return CreateUser(user.ID, user.FullName, user.Email, user.Password)
+	u := CreateUser(user.ID, user.FullName, user.Email, user.Password)
+	u.Phone = user.Phone
+	u.Roles = slices.Clone(user.Roles)
+	u.SuspendedAt = user.SuspendedAt
+	u.EmailVerifiedAt = user.EmailVerifiedAt
+	return u
+}
+
+// Redacted hides the contact details of the user, leaving just enough of them to tell the users apart
+func (u User) Redacted() User {
+	u.Password = ""
+	u.Email = redactEmail(u.Email)
+	u.Phone = redactPhone(u.Phone)
+	return u
+}
+
+func redactEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		return strings.Repeat("*", len(email))
+	}
+
+	return local[:1] + "***@" + domain
+}
+
+func redactPhone(phone string) string {
+	const visibleDigits = 2
+
+	if len(phone) <= visibleDigits {
+		return strings.Repeat("*", len(phone))
+	}
+
+	return strings.Repeat("*", len(phone)-visibleDigits) + phone[len(phone)-visibleDigits:]
 }
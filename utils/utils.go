@@ -2,11 +2,13 @@ package utils
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"github.com/a-h/templ"
 	"github.com/shopspring/decimal"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 var EmptyMap = map[string]string{}
@@ -73,3 +75,29 @@ func StringToDecimal(input string) (decimal.Decimal, error) {
 
 	return decimal.NewFromString(input)
 }
+
+// DateTimeLocalLayout is the value format of the datetime-local HTML input
+const DateTimeLocalLayout = "2006-01-02T15:04"
+
+// StringToNullTime converts a datetime-local input in the server time zone into sql.NullTime{}, which is invalid for an empty string
+func StringToNullTime(input string) (sql.NullTime, error) {
+	if input == "" {
+		return sql.NullTime{}, nil
+	}
+
+	t, err := time.ParseInLocation(DateTimeLocalLayout, input, time.Local)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// NullTimeToString formats the time for the datetime-local HTML input, with "" being the value for an invalid time
+func NullTimeToString(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+
+	return t.Time.In(time.Local).Format(DateTimeLocalLayout)
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"github.com/artemsmotritel/oktion/types"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const maxAPITokenNameLength = 100
+
+// APITokenLifetimes are the expiry options of the form, with 0 standing for a token that never expires
+var APITokenLifetimes = map[string]time.Duration{
+	"30":    30 * 24 * time.Hour,
+	"90":    90 * 24 * time.Hour,
+	"365":   365 * 24 * time.Hour,
+	"never": 0,
+}
+
+type CreateAPITokenValidator struct {
+	Name      string
+	Scopes    []types.Scope
+	Lifetime  time.Duration
+	Errors    map[string]string
+	expiresIn string
+}
+
+func NewCreateAPITokenValidator() *CreateAPITokenValidator {
+	return &CreateAPITokenValidator{}
+}
+
+// Validate accepts a token without scopes, such a token may only read
+func (v *CreateAPITokenValidator) Validate(values url.Values) bool {
+	v.Errors = make(map[string]string)
+	v.Name = strings.TrimSpace(values.Get("name"))
+
+	if v.Name == "" {
+		v.Errors["name"] = "Name the token after the script that will use it"
+	} else if len(v.Name) > maxAPITokenNameLength {
+		v.Errors["name"] = "The name is too long"
+	}
+
+	v.Scopes = make([]types.Scope, 0, len(values["scope"]))
+	for _, value := range values["scope"] {
+		scope, err := types.ParseScope(value)
+		if err != nil {
+			v.Errors["scope"] = "Unknown permission"
+			break
+		}
+		v.Scopes = append(v.Scopes, scope)
+	}
+
+	v.expiresIn = values.Get("expires-in")
+	lifetime, ok := APITokenLifetimes[v.expiresIn]
+	if !ok {
+		v.Errors["expires-in"] = "Choose when the token expires"
+	}
+	v.Lifetime = lifetime
+
+	return len(v.Errors) == 0
+}
+
+// Values keeps the checked scopes under their own names, so that the form can check them again
+func (v *CreateAPITokenValidator) Values() map[string]string {
+	values := map[string]string{
+		"name":       v.Name,
+		"expires-in": v.expiresIn,
+	}
+	for _, scope := range v.Scopes {
+		values[string(scope)] = "on"
+	}
+	return values
+}
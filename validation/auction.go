@@ -2,8 +2,14 @@ package validation
 
 import (
 	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"strconv"
+	"time"
 )
 
+// MaxSoftCloseWindow keeps the lots from being extended for hours by a single bid
+const MaxSoftCloseWindow = time.Hour
+
 type AuctionUpdateValidator struct {
 	Errors  map[string]string
 	Request types.AuctionUpdateRequest
@@ -25,5 +31,56 @@ func (v *AuctionUpdateValidator) Validate() (bool, error) {
 		v.Errors["description"] = "Auction Description is required"
 	}
 
+	if startsAt, err := utils.StringToNullTime(v.Request.StartsAtStr); err != nil {
+		v.Errors["startsAt"] = "Start time must be a valid date"
+	} else {
+		v.Request.StartsAt = startsAt
+	}
+
+	if endsAt, err := utils.StringToNullTime(v.Request.EndsAtStr); err != nil {
+		v.Errors["endsAt"] = "End time must be a valid date"
+	} else if endsAt.Valid && v.Request.StartsAt.Valid && !endsAt.Time.After(v.Request.StartsAt.Time) {
+		v.Errors["endsAt"] = "The auction must end after it starts"
+	} else {
+		v.Request.EndsAt = endsAt
+	}
+
+	return len(v.Errors) == 0, nil
+}
+
+type SoftCloseUpdateValidator struct {
+	Errors  map[string]string
+	Request *types.SoftCloseUpdateRequest
+}
+
+func NewSoftCloseUpdateValidator(request *types.SoftCloseUpdateRequest) *SoftCloseUpdateValidator {
+	return &SoftCloseUpdateValidator{
+		Errors:  make(map[string]string),
+		Request: request,
+	}
+}
+
+// Validate parses the window minutes into Request.Window, with an empty value disabling the soft close
+func (v *SoftCloseUpdateValidator) Validate() (bool, error) {
+	if v.Request.MinutesStr == "" {
+		v.Request.Window = 0
+		return true, nil
+	}
+
+	minutes, err := strconv.ParseFloat(v.Request.MinutesStr, 64)
+	if err != nil {
+		v.Errors["softCloseMinutes"] = "The window must be a number of minutes"
+		return false, nil
+	}
+
+	window := time.Duration(minutes * float64(time.Minute)).Round(time.Second)
+	if window < 0 {
+		v.Errors["softCloseMinutes"] = "The window must be no less than zero"
+	} else if window > MaxSoftCloseWindow {
+		v.Errors["softCloseMinutes"] = "The window must be no longer than " + strconv.Itoa(int(MaxSoftCloseWindow.Minutes())) + " minutes"
+	} else {
+		v.Request.Window = window
+	}
+
 	return len(v.Errors) == 0, nil
 }
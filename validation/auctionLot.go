@@ -52,6 +52,20 @@ func (v *AuctionLotUpdateValidator) Validate() (bool, error) {
 		v.Request.BinPrice = binPrice
 	}
 
+	if startsAt, err := utils.StringToNullTime(v.Request.StartsAtStr); err != nil {
+		v.Errors["startsAt"] = "Start time must be a valid date"
+	} else {
+		v.Request.StartsAt = startsAt
+	}
+
+	if endsAt, err := utils.StringToNullTime(v.Request.EndsAtStr); err != nil {
+		v.Errors["endsAt"] = "End time must be a valid date"
+	} else if endsAt.Valid && v.Request.StartsAt.Valid && !endsAt.Time.After(v.Request.StartsAt.Time) {
+		v.Errors["endsAt"] = "The lot must end after it starts"
+	} else {
+		v.Request.EndsAt = endsAt
+	}
+
 	if v.Request.CategoryIdStr == "" {
 		v.Errors["category"] = "Category is required"
 	} else if categoryId, err := strconv.ParseInt(v.Request.CategoryIdStr, 10, 64); err != nil {
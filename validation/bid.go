@@ -0,0 +1,77 @@
+package validation
+
+import (
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"github.com/shopspring/decimal"
+)
+
+type BidValidator struct {
+	Errors  map[string]string
+	Request *types.BidCreateRequest
+}
+
+func NewBidValidator(request *types.BidCreateRequest) *BidValidator {
+	return &BidValidator{
+		Errors:  make(map[string]string),
+		Request: request,
+	}
+}
+
+// Validate checks the bid value against the next minimal bid calculated from the lot minimal bid,
+// the current highest bid (which may be nil) and the auction bid increment ladder.
+// Whether the lot accepts bids at all is up to the caller to check
+func (v *BidValidator) Validate(lot *types.AuctionLot, highestBid *types.Bid, increments types.BidIncrements) (bool, error) {
+	nextMinimalBid := increments.NextMinimalBid(lot.MinimalBid, highestBid)
+
+	if v.Request.ValueStr == "" {
+		v.Errors["value"] = "Enter your bid"
+	} else if value, err := utils.StringToDecimal(v.Request.ValueStr); err != nil {
+		v.Errors["value"] = "Bid must be a number"
+	} else if value.Compare(decimal.Zero) <= 0 {
+		v.Errors["value"] = "Bid must be greater than zero"
+	} else if value.LessThan(nextMinimalBid) {
+		v.Errors["value"] = "Bid must be no less than " + nextMinimalBid.StringFixedBank(2)
+	} else {
+		v.Request.Value = value
+	}
+
+	return len(v.Errors) == 0, nil
+}
+
+type MaxBidValidator struct {
+	Errors  map[string]string
+	Request *types.MaxBidCreateRequest
+}
+
+func NewMaxBidValidator(request *types.MaxBidCreateRequest) *MaxBidValidator {
+	return &MaxBidValidator{
+		Errors:  make(map[string]string),
+		Request: request,
+	}
+}
+
+// Validate checks that the maximum bid raises the user's current maximum (which may be nil)
+// and is enough to outbid the current price, or to exceed it if the user is already leading
+func (v *MaxBidValidator) Validate(lot *types.AuctionLot, highestBid *types.Bid, increments types.BidIncrements, currentMaxBid *types.MaxBid) (bool, error) {
+	lowerBound := increments.NextMinimalBid(lot.MinimalBid, highestBid)
+	isLeading := highestBid != nil && highestBid.UserID == v.Request.UserID
+
+	if v.Request.AmountStr == "" {
+		v.Errors["maxAmount"] = "Enter your maximum bid"
+	} else if amount, err := utils.StringToDecimal(v.Request.AmountStr); err != nil {
+		v.Errors["maxAmount"] = "Maximum bid must be a number"
+	} else if amount.Compare(decimal.Zero) <= 0 {
+		v.Errors["maxAmount"] = "Maximum bid must be greater than zero"
+	} else if currentMaxBid != nil && amount.LessThanOrEqual(currentMaxBid.Amount) {
+		v.Errors["maxAmount"] = "Maximum bid must be greater than your current maximum of " + currentMaxBid.Amount.StringFixedBank(2)
+	} else if isLeading && amount.LessThanOrEqual(highestBid.Value) {
+		v.Errors["maxAmount"] = "Maximum bid must be greater than your current bid of " + highestBid.Value.StringFixedBank(2)
+	} else if !isLeading && amount.LessThan(lowerBound) {
+		v.Errors["maxAmount"] = "Maximum bid must be no less than " + lowerBound.StringFixedBank(2)
+	} else {
+		v.Request.Amount = amount
+	}
+
+	return len(v.Errors) == 0, nil
+}
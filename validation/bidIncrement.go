@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"fmt"
+	"github.com/artemsmotritel/oktion/types"
+	"github.com/artemsmotritel/oktion/utils"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+type BidIncrementsUpdateValidator struct {
+	Errors  map[string]string
+	Request *types.BidIncrementsUpdateRequest
+}
+
+func NewBidIncrementsUpdateValidator(request *types.BidIncrementsUpdateRequest) *BidIncrementsUpdateValidator {
+	return &BidIncrementsUpdateValidator{
+		Errors:  make(map[string]string),
+		Request: request,
+	}
+}
+
+// Validate parses the ladder steps into Request.Increments, skipping the rows that were left empty
+func (v *BidIncrementsUpdateValidator) Validate() (bool, error) {
+	if len(v.Request.FromStrs) != len(v.Request.AmountStrs) {
+		v.Errors["increments"] = "Every step needs both a price and an increment"
+		return false, nil
+	}
+
+	increments := make(types.BidIncrements, 0, len(v.Request.FromStrs))
+	seen := make(map[string]bool)
+
+	for i := range v.Request.FromStrs {
+		fromStr := strings.TrimSpace(v.Request.FromStrs[i])
+		amountStr := strings.TrimSpace(v.Request.AmountStrs[i])
+		row := i + 1
+
+		if fromStr == "" && amountStr == "" {
+			continue
+		}
+
+		from, err := utils.StringToDecimal(fromStr)
+		if err != nil || from.LessThan(decimal.Zero) {
+			v.Errors["increments"] = fmt.Sprintf("Step %d: the price must be a number no less than zero", row)
+			break
+		}
+
+		amount, err := utils.StringToDecimal(amountStr)
+		if err != nil || !amount.IsPositive() {
+			v.Errors["increments"] = fmt.Sprintf("Step %d: the increment must be a number greater than zero", row)
+			break
+		}
+
+		key := from.String()
+		if seen[key] {
+			v.Errors["increments"] = fmt.Sprintf("Step %d: there is already a step starting at %s", row, from.StringFixedBank(2))
+			break
+		}
+		seen[key] = true
+
+		increments = append(increments, types.BidIncrement{
+			From:   from,
+			Amount: amount,
+		})
+	}
+
+	increments.Sort()
+	v.Request.Increments = increments
+
+	return len(v.Errors) == 0, nil
+}
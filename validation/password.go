@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"github.com/alexedwards/argon2id"
+	"github.com/artemsmotritel/oktion/types"
+	"net/url"
+)
+
+type ForgotPasswordValidator struct {
+	Email  string
+	Errors map[string]string
+}
+
+func NewForgotPasswordValidator() *ForgotPasswordValidator {
+	return &ForgotPasswordValidator{}
+}
+
+// Validate checks only the email format, whether the account exists must not show in the response
+func (v *ForgotPasswordValidator) Validate(values url.Values) bool {
+	v.Errors = make(map[string]string)
+	v.Email = values.Get("email")
+
+	if valid, message := validateEmail(v.Email); !valid {
+		v.Errors["email"] = message
+	}
+
+	return len(v.Errors) == 0
+}
+
+func (v *ForgotPasswordValidator) Values() map[string]string {
+	return map[string]string{
+		"email": v.Email,
+	}
+}
+
+type PasswordResetValidator struct {
+	Password        string
+	ConfirmPassword string
+	Errors          map[string]string
+}
+
+func NewPasswordResetValidator() *PasswordResetValidator {
+	return &PasswordResetValidator{}
+}
+
+func (v *PasswordResetValidator) Validate(values url.Values) bool {
+	v.Errors = make(map[string]string)
+	v.Password = values.Get("password")
+	v.ConfirmPassword = values.Get("confirm-password")
+
+	if v.Password == "" {
+		v.Errors["password"] = "Enter a password"
+	}
+
+	if v.Password != "" && v.Password != v.ConfirmPassword {
+		v.Errors["confirm-password"] = "Your passwords don't match"
+	}
+
+	return len(v.Errors) == 0
+}
+
+type ChangePasswordValidator struct {
+	CurrentPassword string
+	Password        string
+	ConfirmPassword string
+	Errors          map[string]string
+}
+
+func NewChangePasswordValidator() *ChangePasswordValidator {
+	return &ChangePasswordValidator{}
+}
+
+// Validate checks the current password against the hash of the user
+func (v *ChangePasswordValidator) Validate(values url.Values, user *types.User) (bool, error) {
+	v.Errors = make(map[string]string)
+	v.CurrentPassword = values.Get("current-password")
+	v.Password = values.Get("password")
+	v.ConfirmPassword = values.Get("confirm-password")
+
+	if err := validateCurrentPassword(v.CurrentPassword, user, v.Errors); err != nil {
+		return false, err
+	}
+
+	if v.Password == "" {
+		v.Errors["password"] = "Enter a new password"
+	} else if v.Password == v.CurrentPassword {
+		v.Errors["password"] = "The new password must differ from the current one"
+	}
+
+	if v.Password != "" && v.Password != v.ConfirmPassword {
+		v.Errors["confirm-password"] = "Your passwords don't match"
+	}
+
+	return len(v.Errors) == 0, nil
+}
+
+// validateCurrentPassword is for the changes of the credentials, which a session left open mustn't be enough for
+func validateCurrentPassword(password string, user *types.User, errors map[string]string) error {
+	if password == "" {
+		errors["current-password"] = "Enter your current password"
+		return nil
+	}
+
+	isSame, err := argon2id.ComparePasswordAndHash(password, user.Password)
+	if err != nil {
+		return err
+	}
+
+	if !isSame {
+		errors["current-password"] = "Wrong password"
+	}
+
+	return nil
+}
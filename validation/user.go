@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"context"
 	"errors"
 	"github.com/alexedwards/argon2id"
 	"github.com/artemsmotritel/oktion/types"
@@ -33,10 +34,10 @@ func NewSignUpValidator() *SignUpValidator {
 }
 
 type UserIdentityProvider interface {
-	GetUserByEmail(string) (*types.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*types.User, error)
 }
 
-func (u *LoginValidator) Validate(values url.Values, identityProvider UserIdentityProvider) (bool, error) {
+func (u *LoginValidator) Validate(ctx context.Context, values url.Values, identityProvider UserIdentityProvider) (bool, error) {
 	if identityProvider == nil {
 		return false, errors.New("user identity provider is nil")
 	}
@@ -51,7 +52,7 @@ func (u *LoginValidator) Validate(values url.Values, identityProvider UserIdenti
 	if valid, message := validateEmail(u.Email); !valid {
 		u.Errors["email"] = message
 	} else {
-		user, err = identityProvider.GetUserByEmail(u.Email)
+		user, err = identityProvider.GetUserByEmail(ctx, u.Email)
 		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return false, err
 		}
@@ -71,6 +72,8 @@ func (u *LoginValidator) Validate(values url.Values, identityProvider UserIdenti
 		}
 		if !isSame {
 			u.Errors["password"] = "Invalid email or password"
+		} else if user.IsSuspended() {
+			u.Errors["email"] = "This account has been suspended"
 		}
 	}
 
@@ -92,7 +95,7 @@ func (u *SignUpValidator) Values() map[string]string {
 	}
 }
 
-func (u *SignUpValidator) Validate(values url.Values, identityProvider UserIdentityProvider) (bool, error) {
+func (u *SignUpValidator) Validate(ctx context.Context, values url.Values, identityProvider UserIdentityProvider) (bool, error) {
 	if identityProvider == nil {
 		return false, errors.New("user identity provider is nil")
 	}
@@ -106,7 +109,7 @@ func (u *SignUpValidator) Validate(values url.Values, identityProvider UserIdent
 	if valid, message := validateEmail(u.Email); !valid {
 		u.Errors["email"] = message
 	} else {
-		user, err = identityProvider.GetUserByEmail(u.Email)
+		user, err = identityProvider.GetUserByEmail(ctx, u.Email)
 		if err != nil {
 			return false, err
 		}
@@ -132,6 +135,7 @@ func MapUserCreateRequestToUser(request *SignUpValidator) *types.User {
 		FullName: request.FullName,
 		Email:    request.Email,
 		Password: request.Password,
+		Roles:    types.DefaultRoles,
 	}
 }
 
@@ -156,6 +160,52 @@ func IsEmailValid(email string) bool {
 	return err == nil
 }
 
+type ChangeEmailValidator struct {
+	Email           string
+	CurrentPassword string
+	Errors          map[string]string
+}
+
+func NewChangeEmailValidator() *ChangeEmailValidator {
+	return &ChangeEmailValidator{}
+}
+
+func (v *ChangeEmailValidator) Validate(ctx context.Context, values url.Values, user *types.User, identityProvider UserIdentityProvider) (bool, error) {
+	if identityProvider == nil {
+		return false, errors.New("user identity provider is nil")
+	}
+
+	v.Errors = make(map[string]string)
+	v.Email = strings.TrimSpace(values.Get("email"))
+	v.CurrentPassword = values.Get("current-password")
+
+	if valid, message := validateEmail(v.Email); !valid {
+		v.Errors["email"] = message
+	} else if strings.EqualFold(v.Email, user.Email) {
+		v.Errors["email"] = "This is your current email"
+	} else {
+		existing, err := identityProvider.GetUserByEmail(ctx, v.Email)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return false, err
+		}
+		if existing != nil {
+			v.Errors["email"] = "This email is already taken"
+		}
+	}
+
+	if err := validateCurrentPassword(v.CurrentPassword, user, v.Errors); err != nil {
+		return false, err
+	}
+
+	return len(v.Errors) == 0, nil
+}
+
+func (v *ChangeEmailValidator) Values() map[string]string {
+	return map[string]string{
+		"email": v.Email,
+	}
+}
+
 type UserUpdateValidator struct {
 	Errors  map[string]string
 	Request types.UserUpdateRequest
@@ -169,7 +219,7 @@ func NewUserUpdateValidator(update types.UserUpdateRequest) UserUpdateValidator
 }
 
 func (v *UserUpdateValidator) Validate() (bool, error) {
-	// intentionally skip email update for now
+	// the email is changed separately, see ChangeEmailValidator
 
 	if v.Request.Phone == "" {
 		v.Errors["phone"] = "Enter your phone number"